@@ -0,0 +1,43 @@
+package main
+
+import (
+	"deep-research/internal/config"
+	"errors"
+	"flag"
+	"strings"
+)
+
+// configFlags are the configuration flags shared by the run, resume and
+// serve commands. They take precedence over the config file and env vars.
+type configFlags struct {
+	path      string
+	profile   string
+	overrides map[string]string
+	noCache   bool
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	f := &configFlags{overrides: make(map[string]string)}
+	fs.StringVar(&f.path, "config", "", "YAML or TOML config file (overrides DEEP_RESEARCH_CONFIG)")
+	fs.StringVar(&f.profile, "profile", "", "config profile to apply, e.g. cheap or thorough")
+	fs.Func("set", "override a setting by its env var name, e.g. BUDGET_MAX_SUBTOPICS=2 (repeatable)", func(value string) error {
+		key, val, ok := strings.Cut(value, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return errors.New("expected KEY=VALUE")
+		}
+		f.overrides[key] = val
+		return nil
+	})
+	fs.BoolVar(&f.noCache, "no-cache", false, "do not read or write cached search results and summaries")
+	return f
+}
+
+// options returns the config loading options selected by the flags. A nil
+// configFlags selects the defaults.
+func (f *configFlags) options() config.Options {
+	if f == nil {
+		return config.Options{}
+	}
+	return config.Options{Path: f.path, Profile: f.profile, Overrides: f.overrides}
+}
@@ -3,16 +3,20 @@ package main
 import (
 	"bufio"
 	"context"
+	"deep-research/internal/cache"
 	"deep-research/internal/config"
 	"deep-research/internal/llm"
+	"deep-research/internal/research"
+	"deep-research/internal/tools"
+	"deep-research/internal/transport"
 	"deep-research/internal/workflows"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
-	"github.com/instructor-ai/instructor-go/pkg/instructor"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -28,45 +32,12 @@ const (
 	welcomeMessage = "Chat with GPT (use 'ctrl-c' to quit)"
 )
 
-type ChatSessionState struct {
-	conversation            []openai.ChatCompletionMessage
-	researchConversation    []openai.ChatCompletionMessage
-	researchBrief           string
-	compressedResearchNotes []string
-}
-
-type WorkflowManager struct {
-	clarifyWithUser          workflows.ChatSessionWorkflow
-	researchBriefGeneration  workflows.ChatSessionWorkflow
-	webResearch              workflows.ChatSessionWorkflow
-	researchReportGeneration workflows.ChatSessionWorkflow
-}
-
 type ChatSession struct {
-	client                 *openai.Client
-	structuredOutputClient *instructor.InstructorOpenAI
-	logger                 *slog.Logger
-	ctx                    context.Context
-	cancel                 context.CancelFunc
-	state                  *ChatSessionState
-	workflows              *WorkflowManager
-	getUserMessage         func() (string, bool)
-}
-
-func (cs *ChatSession) addUserMessage(message string) error {
-	if len(message) == 0 {
-		return fmt.Errorf("user message cannot be empty")
-	}
-
-	cs.state.conversation = append(cs.state.conversation, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
-		Content: message,
-	})
-
-	cs.logger.Debug("User message added to conversation",
-		"message_length", len(message),
-		"conversation_length", len(cs.state.conversation))
-	return nil
+	session        *research.Session
+	logger         *slog.Logger
+	ctx            context.Context
+	cancel         context.CancelFunc
+	getUserMessage func() (string, bool)
 }
 
 func (cs *ChatSession) processUserInput() bool {
@@ -77,7 +48,7 @@ func (cs *ChatSession) processUserInput() bool {
 		return false
 	}
 
-	if err := cs.addUserMessage(userInput); err != nil {
+	if err := cs.session.AddUserMessage(userInput); err != nil {
 		cs.logger.Error("Failed to add user message", "error", err)
 		fmt.Printf("Error processing your messages: %v\nPlease try again.\n", err)
 		// Continue conversation despite error
@@ -87,18 +58,44 @@ func (cs *ChatSession) processUserInput() bool {
 	return true
 }
 
+// Run clarifies the research scope with the user, then runs the research
+// stages and prints the report.
 func (cs *ChatSession) Run() error {
+	cs.Scope()
+
+	report, err := cs.session.Research(cs.ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(gptResponseColor, report)
+	fmt.Print(cs.session.Usage())
+
+	cs.logger.Debug("Chat session ended")
+	return nil
+}
+
+// Scope chats with the user until the research scope is clear, the user
+// ends the input or clarifying fails.
+func (cs *ChatSession) Scope() {
 	fmt.Println(welcomeMessage)
+	fmt.Printf("Session %s (resume with 'deep-research resume %s')\n", cs.session.ID, cs.session.ID)
 	cs.logger.Debug("Starting chat session")
 
-	for {
+	// A resumed session repeats the last question it asked the user
+	if conversation := cs.session.State.Conversation; len(conversation) > 0 && !cs.session.State.ScopingComplete() {
+		if last := conversation[len(conversation)-1]; last.Role == openai.ChatMessageRoleAssistant {
+			fmt.Printf(gptResponseColor, last.Content)
+		}
+	}
+
+	for !cs.session.State.ScopingComplete() {
 		if !cs.processUserInput() {
 			cs.logger.Debug("User terminated input, ending session")
 			break
 		}
 
 		// Workflow 1: Clarify with research scope with user
-		resp, cont, err := cs.workflows.clarifyWithUser.Execute(cs.ctx)
+		resp, cont, err := cs.session.Clarify(cs.ctx)
 		if err != nil {
 			cs.logger.Error("Failed to execute clarify with user workflow", "error", err)
 			fmt.Printf("Error generating response: %v. Please try again.\n", err)
@@ -109,60 +106,111 @@ func (cs *ChatSession) Run() error {
 			break
 		}
 	}
+}
+
+// sessionFactory creates research sessions sharing the same configuration,
+// LLM and search clients and checkpoint store.
+type sessionFactory struct {
+	cfg            *config.Config
+	providers      *llm.StageProviders
+	searchProvider tools.SearchProvider
+	localSearch    tools.SearchProvider
+	responseCache  *cache.Cache
+	prompts        *workflows.Prompts
+	store          *research.Store
+}
 
-	// Workflow 2: Generate research brief based on scoping interactions
-	resp, _, err := cs.workflows.researchBriefGeneration.Execute(cs.ctx)
+// newSessionFactory loads the configuration selected by flags and
+// initializes the clients shared by every session. A nil flags uses the
+// config file and env vars only.
+func newSessionFactory(ctx context.Context, flags *configFlags) (*sessionFactory, error) {
+	cfg, err := config.Load(flags.options())
 	if err != nil {
-		cs.logger.Error("Failed to execute research brief generation workflow", "error", err)
-		return fmt.Errorf("error generating response: %v", err)
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if flags != nil && flags.noCache {
+		cfg.Cache.Disabled = true
 	}
-	cs.state.researchBrief = resp.(string)
-	cs.state.researchConversation = append(cs.state.researchConversation, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
-		Content: resp.(string),
-	})
-
-	// Workflow 3: Generate web search for information
-	for {
-		_, cont, err := cs.workflows.webResearch.Execute(cs.ctx)
+	if cfg.Cassette.Path != "" {
+		cassette, err := transport.NewCassette(cfg.Cassette.Path, cfg.Cassette.Mode, nil)
 		if err != nil {
-			cs.logger.Error("Failed to execute web research workflow", "error", err)
-			break
-		}
-		if !cont {
-			break
+			return nil, fmt.Errorf("failed to open cassette: %w", err)
 		}
+		cfg.Transport = cassette
+		// Cached responses would never reach the cassette
+		cfg.Cache.Disabled = true
 	}
-
-	// Workflow 4: Write research report based on all available information
-	resp, _, err = cs.workflows.researchReportGeneration.Execute(cs.ctx)
+	responseCache, err := cache.New(cfg.Cache)
 	if err != nil {
-		cs.logger.Error("Failed to execute research report generation workflow", "error", err)
-		return fmt.Errorf("error generating response: %v", err)
+		return nil, fmt.Errorf("failed to open response cache: %w", err)
 	}
-	fmt.Printf(gptResponseColor, resp)
-
-	cs.logger.Debug("Chat session ended")
-	return nil
-}
 
-func NewChatSession(ctx context.Context) (*ChatSession, error) {
-	cfg, err := config.LoadConfig()
+	providers, err := llm.InitializeStageProviders(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load configuration: %w", err)
+		return nil, fmt.Errorf("failed to initialize LLM client: %w", err)
 	}
 
-	client, structuredOutputClient, err := llm.InitializeClients(cfg)
+	searchProvider, err := tools.NewSearchProvider(cfg, responseCache)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize LLM client: %w", err)
+		return nil, fmt.Errorf("failed to initialize search provider: %w", err)
+	}
+	localSearch, err := tools.NewLocalSearch(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to index local documents: %w", err)
+	}
+	prompts, err := workflows.LoadPrompts(cfg.PromptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompts: %w", err)
 	}
 
-	sessionCtx, cancel := context.WithCancel(ctx)
+	return &sessionFactory{
+		cfg:            cfg,
+		providers:      providers,
+		searchProvider: searchProvider,
+		localSearch:    localSearch,
+		responseCache:  responseCache,
+		prompts:        prompts,
+		store:          research.NewStore(cfg.SessionDir),
+	}, nil
+}
+
+func (f *sessionFactory) New(logger *slog.Logger) (*research.Session, error) {
+	return research.NewSession(f.cfg, f.providers, f.searchProvider, f.localSearch, f.responseCache, f.prompts, f.store, logger)
+}
+
+func (f *sessionFactory) Resume(id string, logger *slog.Logger) (*research.Session, error) {
+	return research.ResumeSession(id, f.cfg, f.providers, f.searchProvider, f.localSearch, f.responseCache, f.prompts, f.store, logger)
+}
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+func newLogger(output io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{
 		Level:     slog.LevelInfo,
 		AddSource: true,
 	}))
+}
+
+// NewChatSession creates a chat session for a new research session, or for
+// the checkpointed session resumeID when it is not empty, configured by flags.
+func NewChatSession(ctx context.Context, logOutput io.Writer, resumeID string, flags *configFlags) (*ChatSession, error) {
+	factory, err := newSessionFactory(ctx, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := newLogger(logOutput)
+	var researchSession *research.Session
+	if resumeID != "" {
+		researchSession, err = factory.Resume(resumeID, logger)
+	} else {
+		researchSession, err = factory.New(logger)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	researchSession.OnProgress = progressPrinter(os.Stdout)
+
+	sessionCtx, cancel := context.WithCancel(ctx)
 
 	scanner := bufio.NewScanner(os.Stdin)
 	getUserMessage := func() (string, bool) {
@@ -190,26 +238,11 @@ func NewChatSession(ctx context.Context) (*ChatSession, error) {
 		}
 	}
 
-	state := &ChatSessionState{
-		conversation:            make([]openai.ChatCompletionMessage, 0),
-		researchConversation:    make([]openai.ChatCompletionMessage, 0),
-		researchBrief:           "",
-		compressedResearchNotes: make([]string, 0),
-	}
-
 	session := &ChatSession{
-		client:                 client,
-		structuredOutputClient: structuredOutputClient,
-		logger:                 logger,
-		ctx:                    sessionCtx,
-		cancel:                 cancel,
-		state:                  state,
-		workflows: &WorkflowManager{
-			clarifyWithUser:          workflows.NewClarifyWithUser(&state.conversation, structuredOutputClient, logger),
-			researchBriefGeneration:  workflows.NewResearchBriefGeneration(&state.conversation, structuredOutputClient, logger),
-			webResearch:              workflows.NewWebResearch(&state.researchConversation, &state.compressedResearchNotes, client, structuredOutputClient, logger),
-			researchReportGeneration: workflows.NewResearchReportGeneration(&state.researchBrief, &state.compressedResearchNotes, structuredOutputClient, logger),
-		},
+		session:        researchSession,
+		logger:         logger,
+		ctx:            sessionCtx,
+		cancel:         cancel,
 		getUserMessage: getUserMessage,
 	}
 
@@ -250,7 +283,24 @@ func main() {
 	ctx, cleanup := setupGracefulShutdown()
 	defer cleanup()
 
-	chatSession, err := NewChatSession(ctx)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			code := runCommand(ctx, os.Args[2:])
+			cleanup()
+			os.Exit(code)
+		case "resume":
+			code := resumeCommand(ctx, os.Args[2:])
+			cleanup()
+			os.Exit(code)
+		case "serve":
+			code := serveCommand(ctx, os.Args[2:])
+			cleanup()
+			os.Exit(code)
+		}
+	}
+
+	chatSession, err := NewChatSession(ctx, os.Stdout, "", nil)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to initialize application: %v", err)
 		os.Exit(1)
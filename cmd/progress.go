@@ -0,0 +1,53 @@
+package main
+
+import (
+	"deep-research/internal/research"
+	"deep-research/internal/workflows"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// progressColor applies grey color to progress updates in the terminal
+const progressColor = "\u001b[90m%s %s\u001b[0m\n"
+
+// stageLabels describes each research stage in the progress view. Stages
+// without a label, such as clarification, are not shown.
+var stageLabels = map[string]string{
+	research.StageResearchBrief:  "Generating research brief",
+	research.StageResearchPlan:   "Planning research subtopics",
+	research.StageWebResearch:    "Researching the web",
+	research.StageResearchReport: "Writing research report",
+	research.StageCompleted:      "Research complete",
+}
+
+// progressPrinter returns a reporter that renders progress events as they
+// happen, one line per event.
+func progressPrinter(w io.Writer) workflows.ProgressReporter {
+	var mu sync.Mutex
+	return func(event workflows.ProgressEvent) {
+		var marker, message string
+		switch event.Type {
+		case workflows.EventStageEntered:
+			label, ok := stageLabels[event.Stage]
+			if !ok {
+				return
+			}
+			marker, message = "==>", label
+		case workflows.EventReflection:
+			marker, message = "  ~", event.Message
+		case workflows.EventBudgetExhausted:
+			marker, message = "  !", event.Message
+		default:
+			marker, message = "  -", event.Message
+		}
+		// Events from concurrent sub-researchers are tagged with their subtopic
+		if subtopic, ok := event.Data["subtopic"].(string); ok && event.Type != workflows.EventStageEntered {
+			message = fmt.Sprintf("[%s] %s", subtopic, message)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, progressColor, marker, message)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"deep-research/internal/research"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// resumeCommand implements `deep-research resume <session-id>`, which
+// continues a session from its last checkpoint. A session that was still
+// clarifying the research scope goes back to the interactive chat; the
+// remaining stages then run without interaction, as with the run command,
+// and the report is written as --out and --format request.
+func resumeCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("resume", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	out := fs.String("out", "", "file to write the report to (defaults to stdout)")
+	format := addFormatFlag(fs)
+	quiet := fs.Bool("quiet", false, "do not print research progress to stderr")
+	flags := addConfigFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deep-research resume [--out report.md] [--format md|html|json|org|asciidoc] [--quiet] [--config file] [--profile name] [--set KEY=VALUE] [--no-cache] <session-id>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "resume: exactly one session id is required")
+		fs.Usage()
+		return exitUsage
+	}
+	reportFormat, err := resolveFormat(*format, *out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resume: %v\n", err)
+		return exitUsage
+	}
+
+	chatSession, err := NewChatSession(ctx, os.Stderr, fs.Arg(0), flags)
+	if errors.Is(err, research.ErrSessionNotFound) {
+		fmt.Fprintf(os.Stderr, "resume: %v\n", err)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize application: %v\n", err)
+		return exitInitFailed
+	}
+	defer func() {
+		if closeErr := chatSession.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Error during shutdown: %v\n", closeErr)
+		}
+	}()
+
+	if !chatSession.session.State.ScopingComplete() {
+		// Without --out or --format the report is printed to the chat
+		if *out == "" && *format == "" {
+			if err := chatSession.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "Application error: %v\n", err)
+				var se *research.StageError
+				if errors.As(err, &se) {
+					return exitCodeForStage(se.Stage)
+				}
+				return exitInitFailed
+			}
+			return exitOK
+		}
+		chatSession.Scope()
+	}
+
+	chatSession.session.OnProgress = progressPrinter(os.Stderr)
+	if *quiet {
+		chatSession.session.OnProgress = nil
+	}
+	return researchAndWrite(chatSession, *out, reportFormat, *quiet)
+}
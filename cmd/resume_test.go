@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"deep-research/internal/fakeapi"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scriptResearch scripts a clarification question, then a single-subtopic
+// research run about solar panels.
+func scriptResearch(chat *fakeapi.OpenAI, exa *fakeapi.Exa) {
+	chat.On(fakeapi.Schema("ClarifyWithUserOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"need_clarification": true, "question": "Which kind of panels?"}},
+		fakeapi.ChatReply{JSON: map[string]any{"need_clarification": false, "verification": "Researching rooftop panels."}})
+	chat.On(fakeapi.Schema("ResearchBriefGenerationOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"research_brief": "Research solar"}})
+	chat.On(fakeapi.Schema("ResearchSupervisorOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"subtopics": []map[string]string{{"title": "solar", "brief": "Research solar"}}}})
+	chat.On(fakeapi.Schema("SummarizedResearchOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"summary": "A summary.", "key_excerpts": "An excerpt."}})
+	chat.On(fakeapi.Schema("ResearchReportGenerationOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{
+			"report":    "# Report\n\nFindings [1].",
+			"citations": []map[string]int{{"marker": 1, "source_id": 1}},
+		}})
+	chat.On(fakeapi.Offers("search_tool"),
+		fakeapi.ChatReply{ToolCalls: []fakeapi.ToolCall{{Name: "search_tool", Arguments: map[string]string{"query": "solar 1"}}}},
+		fakeapi.ChatReply{Content: "Research complete."})
+	exa.On(fakeapi.AnyQuery(), fakeapi.SearchResult{Title: "solar 1", URL: "https://example.com/solar-1", Text: "Facts about solar panels."})
+}
+
+// setStdin replaces stdin with input for the rest of the test.
+func setStdin(t *testing.T, input string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+func TestResumeUnscopedSessionWritesReport(t *testing.T) {
+	chat := fakeapi.NewOpenAI()
+	defer chat.Close()
+	exa := fakeapi.NewExa()
+	defer exa.Close()
+	scriptResearch(chat, exa)
+
+	for key, value := range map[string]string{
+		"OPENAI_API_KEY":            "test-key",
+		"OPENAI_BASE_URL":           chat.URL(),
+		"EXA_API_KEY":               "test-key",
+		"EXA_ENDPOINT":              exa.URL(),
+		"DEEP_RESEARCH_SESSION_DIR": t.TempDir(),
+		"DEEP_RESEARCH_CONFIG":      "",
+		"DEEP_RESEARCH_PROFILE":     "",
+		"CACHE_DISABLED":            "true",
+		"RETRY_MAX_RETRIES":         "0",
+	} {
+		t.Setenv(key, value)
+	}
+
+	// Leave a session waiting for the answer to its clarification question
+	ctx := context.Background()
+	factory, err := newSessionFactory(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := factory.New(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.AddUserMessage("How do solar panels work?"); err != nil {
+		t.Fatal(err)
+	}
+	if _, cont, err := session.Clarify(ctx); err != nil || !cont {
+		t.Fatalf("Clarify() = %v, %v, want a question", cont, err)
+	}
+
+	setStdin(t, "Rooftop panels\n")
+	out := filepath.Join(t.TempDir(), "report.out")
+	if code := resumeCommand(ctx, []string{"--out", out, "--format", "html", "--quiet", session.ID}); code != exitOK {
+		t.Fatalf("resume exited with %d, want %d", code, exitOK)
+	}
+
+	report, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("report was not written to --out: %v", err)
+	}
+	if !strings.HasPrefix(string(report), "<!DOCTYPE html>") || !strings.Contains(string(report), "Findings") {
+		t.Errorf("report = %q, want the HTML report", report)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"deep-research/internal/export"
+	"deep-research/internal/research"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exit codes reported by the non-interactive run command. Each research stage
+// has its own code so scripts can tell where a run failed.
+const (
+	exitOK             = 0
+	exitInitFailed     = 1
+	exitUsage          = 2
+	exitBriefFailed    = 3
+	exitResearchFailed = 4
+	exitReportFailed   = 5
+	exitOutputFailed   = 6
+)
+
+// exitCodeForStage maps the stage a run failed in to its exit code.
+func exitCodeForStage(stage string) int {
+	switch stage {
+	case research.StageResearchBrief:
+		return exitBriefFailed
+	case research.StageResearchPlan, research.StageWebResearch:
+		return exitResearchFailed
+	case research.StageResearchReport:
+		return exitReportFailed
+	default:
+		return exitInitFailed
+	}
+}
+
+// runCommand implements `deep-research run`, which researches a single query
+// without user interaction. Clarification is skipped and the query is used
+// as-is to generate the research brief.
+func runCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	query := fs.String("query", "", "research question to investigate (required)")
+	out := fs.String("out", "", "file to write the report to (defaults to stdout)")
+	format := addFormatFlag(fs)
+	quiet := fs.Bool("quiet", false, "do not print research progress to stderr")
+	flags := addConfigFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deep-research run --query \"...\" [--out report.md] [--format md|html|json|org|asciidoc] [--quiet] [--config file] [--profile name] [--set KEY=VALUE] [--no-cache]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if strings.TrimSpace(*query) == "" {
+		fmt.Fprintln(os.Stderr, "run: --query is required")
+		fs.Usage()
+		return exitUsage
+	}
+	reportFormat, err := resolveFormat(*format, *out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run: %v\n", err)
+		return exitUsage
+	}
+
+	// Keep stdout free for the report by sending logs and progress to stderr
+	chatSession, err := NewChatSession(ctx, os.Stderr, "", flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize application: %v\n", err)
+		return exitInitFailed
+	}
+	chatSession.session.OnProgress = progressPrinter(os.Stderr)
+	if *quiet {
+		chatSession.session.OnProgress = nil
+	}
+	defer func() {
+		if closeErr := chatSession.Close(); closeErr != nil {
+			fmt.Fprintf(os.Stderr, "Error during shutdown: %v\n", closeErr)
+		}
+	}()
+
+	if err := chatSession.session.AddUserMessage(*query); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid query: %v\n", err)
+		return exitUsage
+	}
+	chatSession.session.SkipClarification()
+
+	fmt.Fprintf(os.Stderr, "Session %s (resume with 'deep-research resume %s')\n", chatSession.session.ID, chatSession.session.ID)
+	return researchAndWrite(chatSession, *out, reportFormat, *quiet)
+}
+
+// addFormatFlag registers the --format flag selecting the report format.
+func addFormatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", "", fmt.Sprintf("report format, one of %s (defaults to the extension of --out, or md)", strings.Join(export.Formats, ", ")))
+}
+
+// resolveFormat returns the report format named by the --format flag, or the
+// one matching the extension of the output file when the flag is not set.
+func resolveFormat(format, out string) (string, error) {
+	if format == "" {
+		return export.FormatForPath(out), nil
+	}
+	return export.ParseFormat(format)
+}
+
+// researchAndWrite runs the research stages of the chat session and writes
+// the report to out in format, returning the exit code for the outcome.
+// Unless quiet, a usage and cost summary is printed to stderr.
+func researchAndWrite(chatSession *ChatSession, out, format string, quiet bool) int {
+	_, err := chatSession.session.Research(chatSession.ctx)
+	if !quiet {
+		fmt.Fprint(os.Stderr, chatSession.session.Usage())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Application error: %v\n", err)
+		var se *research.StageError
+		if errors.As(err, &se) {
+			return exitCodeForStage(se.Stage)
+		}
+		return exitInitFailed
+	}
+
+	if err := writeReport(out, format, reportDocument(chatSession.session)); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write report: %v\n", err)
+		return exitOutputFailed
+	}
+
+	chatSession.logger.Debug("Research run completed successfully")
+	return exitOK
+}
+
+// reportDocument collects the report of a finished session with its brief
+// and metadata for export.
+func reportDocument(session *research.Session) export.Document {
+	summary := session.Usage()
+	return export.Document{
+		Report:        session.State.Report,
+		ResearchBrief: session.State.ResearchBrief,
+		Metadata: export.Metadata{
+			SessionID:     session.ID,
+			Query:         session.Query(),
+			PromptVersion: session.State.PromptVersion,
+			GeneratedAt:   session.State.CompletedAt,
+			Usage:         &summary,
+		},
+	}
+}
+
+// writeReport writes doc in format to path, or to stdout when path is empty
+// or "-".
+func writeReport(path, format string, doc export.Document) (err error) {
+	var w io.Writer = os.Stdout
+	if path != "" && path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := f.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+		w = f
+	}
+
+	return export.Write(w, format, doc)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"deep-research/internal/server"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// shutdownTimeout bounds how long the server waits for in-flight requests
+// after receiving a shutdown signal.
+const shutdownTimeout = 10 * time.Second
+
+// serveCommand implements `deep-research serve`, which exposes research jobs
+// over a REST API.
+func serveCommand(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	jobTTL := fs.Duration("job-ttl", server.DefaultJobRetention.TTL, "how long finished jobs are kept, 0 keeps them until --max-jobs is reached")
+	maxJobs := fs.Int("max-jobs", server.DefaultJobRetention.MaxFinished, "how many finished jobs are kept, 0 for no limit")
+	flags := addConfigFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deep-research serve [--addr :8080] [--job-ttl 24h] [--max-jobs 100] [--config file] [--profile name] [--set KEY=VALUE] [--no-cache]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	logger := newLogger(os.Stderr)
+	factory, err := newSessionFactory(ctx, flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize application: %v\n", err)
+		return exitInitFailed
+	}
+
+	retention := server.JobRetention{TTL: *jobTTL, MaxFinished: *maxJobs}
+	jobs := server.NewJobManager(ctx, factory.New, retention, logger)
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server.New(jobs, logger),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		logger.Info("Starting API server", "addr", *addr)
+		errChan <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("API server failed", "error", err)
+			return exitInitFailed
+		}
+	case <-ctx.Done():
+		// Running jobs are cancelled through ctx, wait for them to wind down
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to shut down API server", "error", err)
+		}
+		jobs.Wait()
+	}
+
+	logger.Info("API server stopped")
+	return exitOK
+}
@@ -3,9 +3,15 @@ module deep-research
 go 1.25.0
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/instructor-ai/instructor-go v0.0.0-20250813135554-db90e80ba8cd
 	github.com/invopop/jsonschema v0.13.0
+	github.com/ledongthuc/pdf v0.0.0-20260907135840-6c8c28e0e8a0
+	github.com/liushuangls/go-anthropic/v2 v2.15.2
 	github.com/sashabaranov/go-openai v1.41.1
+	golang.org/x/net v0.43.0
+	google.golang.org/genai v1.19.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -31,7 +37,6 @@ require (
 	github.com/googleapis/gax-go/v2 v2.15.0 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
-	github.com/liushuangls/go-anthropic/v2 v2.15.2 // indirect
 	github.com/mailru/easyjson v0.9.0 // indirect
 	github.com/wk8/go-ordered-map/v2 v2.1.8 // indirect
 	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
@@ -40,12 +45,9 @@ require (
 	go.opentelemetry.io/otel/metric v1.37.0 // indirect
 	go.opentelemetry.io/otel/trace v1.37.0 // indirect
 	golang.org/x/crypto v0.41.0 // indirect
-	golang.org/x/net v0.43.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/text v0.28.0 // indirect
-	google.golang.org/genai v1.19.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250811230008-5f3141c8851a // indirect
 	google.golang.org/grpc v1.74.2 // indirect
 	google.golang.org/protobuf v1.36.7 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
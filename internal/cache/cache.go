@@ -0,0 +1,227 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"deep-research/internal/config"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const entrySuffix = ".json"
+
+// Cache is an on-disk cache for the responses of search providers and
+// models, so repeated research does not pay for identical calls twice.
+// Entries expire after a TTL, and the least recently used entries are
+// evicted once the cache grows past its size limit. A nil *Cache is a
+// disabled cache: lookups miss and stores are ignored. It is safe for
+// concurrent use.
+type Cache struct {
+	dir      string
+	ttl      time.Duration
+	maxBytes int64
+	// now is the clock used for expiry and eviction, replaced in tests
+	now func() time.Time
+
+	mu sync.Mutex
+	// size is the total size of the entries, kept up to date between scans
+	size int64
+}
+
+type entry struct {
+	Key       string          `json:"key"`
+	CreatedAt time.Time       `json:"created_at"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// New opens the cache configured by cfg, creating its directory if needed.
+// It returns a nil cache when caching is disabled.
+func New(cfg config.CacheConfig) (*Cache, error) {
+	if cfg.Disabled {
+		return nil, nil
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	c := &Cache{
+		dir:      cfg.Dir,
+		ttl:      cfg.TTL,
+		maxBytes: int64(cfg.MaxSizeMB) << 20,
+		now:      time.Now,
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.scan(); err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	return c, nil
+}
+
+// Key builds a cache key from its parts.
+func Key(parts ...string) string {
+	return strings.Join(parts, "\x00")
+}
+
+// Get decodes the unexpired entry stored under key into v. It reports
+// whether the entry was found.
+func (c *Cache) Get(key string, v any) bool {
+	if c == nil {
+		return false
+	}
+
+	path := c.path(key)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var e entry
+	// Hash collisions are not worth handling beyond a key comparison
+	if err := json.Unmarshal(raw, &e); err != nil || e.Key != key {
+		return false
+	}
+	if c.ttl > 0 && c.now().Sub(e.CreatedAt) > c.ttl {
+		c.remove(path)
+		return false
+	}
+	if err := json.Unmarshal(e.Value, v); err != nil {
+		return false
+	}
+
+	// The modification time records the last use for eviction
+	now := c.now()
+	_ = os.Chtimes(path, now, now)
+	return true
+}
+
+// Set stores v under key, evicting the least recently used entries if the
+// cache grows past its size limit.
+func (c *Cache) Set(key string, v any) error {
+	if c == nil {
+		return nil
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	now := c.now()
+	raw, err := json.Marshal(entry{Key: key, CreatedAt: now.UTC(), Value: value})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	var replaced int64
+	if info, err := os.Stat(path); err == nil {
+		replaced = info.Size()
+	}
+	// Write to a temporary file first so readers never see a partial entry
+	tmp, err := os.CreateTemp(c.dir, "entry-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	// The modification time records the last use for eviction
+	_ = os.Chtimes(path, now, now)
+
+	c.size += int64(len(raw)) - replaced
+	if c.maxBytes > 0 && c.size > c.maxBytes {
+		return c.evict()
+	}
+	return nil
+}
+
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+entrySuffix)
+}
+
+func (c *Cache) remove(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if info, err := os.Stat(path); err == nil && os.Remove(path) == nil {
+		c.size -= info.Size()
+	}
+}
+
+type cachedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// scan recomputes the cache size from disk, which also picks up entries
+// written by other processes. It must be called with mu held.
+func (c *Cache) scan() ([]cachedFile, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []cachedFile
+	c.size = 0
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), entrySuffix) {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			// Removed by another process since the directory was read
+			continue
+		}
+		files = append(files, cachedFile{
+			path:    filepath.Join(c.dir, dirEntry.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		c.size += info.Size()
+	}
+	return files, nil
+}
+
+// evict removes the least recently used entries until the cache is back
+// under 90% of its size limit, so it is not evicting on every store. It must
+// be called with mu held.
+func (c *Cache) evict() error {
+	files, err := c.scan()
+	if err != nil {
+		return fmt.Errorf("failed to evict cache entries: %w", err)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	target := c.maxBytes * 9 / 10
+	for _, file := range files {
+		if c.size <= target {
+			break
+		}
+		if err := os.Remove(file.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to evict cache entry: %w", err)
+		}
+		c.size -= file.size
+	}
+	return nil
+}
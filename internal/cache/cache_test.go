@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"deep-research/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClock is a manually advanced clock.
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time { return c.t }
+
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestCache(t *testing.T, cfg config.CacheConfig) (*Cache, *testClock) {
+	t.Helper()
+	cfg.Dir = filepath.Join(t.TempDir(), "cache")
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	clock := &testClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c.now = clock.now
+	return c, clock
+}
+
+func TestCacheGetSet(t *testing.T) {
+	c, _ := newTestCache(t, config.CacheConfig{})
+	type value struct{ Text string }
+
+	var got value
+	if c.Get("key", &got) {
+		t.Fatal("Get() hit an empty cache")
+	}
+	if err := c.Set("key", value{Text: "hello"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if !c.Get("key", &got) || got.Text != "hello" {
+		t.Errorf("Get() = %+v, want the stored value", got)
+	}
+	if err := c.Set("key", value{Text: "replaced"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if !c.Get("key", &got) || got.Text != "replaced" {
+		t.Errorf("Get() = %+v, want the replaced value", got)
+	}
+}
+
+func TestDisabledCache(t *testing.T) {
+	c, err := New(config.CacheConfig{Disabled: true})
+	if err != nil || c != nil {
+		t.Fatalf("New() = %v, %v, want a nil cache", c, err)
+	}
+	if err := c.Set("key", "value"); err != nil {
+		t.Errorf("Set() error = %v", err)
+	}
+	var got string
+	if c.Get("key", &got) {
+		t.Error("Get() hit a disabled cache")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		elapsed time.Duration
+		wantHit bool
+	}{
+		{name: "fresh", ttl: time.Hour, elapsed: 59 * time.Minute, wantHit: true},
+		{name: "expired", ttl: time.Hour, elapsed: 61 * time.Minute},
+		{name: "no ttl", elapsed: 365 * 24 * time.Hour, wantHit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, clock := newTestCache(t, config.CacheConfig{TTL: tt.ttl})
+			if err := c.Set("key", "value"); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			clock.advance(tt.elapsed)
+
+			var got string
+			if hit := c.Get("key", &got); hit != tt.wantHit {
+				t.Fatalf("Get() = %v, want %v", hit, tt.wantHit)
+			}
+			_, err := os.Stat(c.path("key"))
+			if exists := err == nil; exists != tt.wantHit {
+				t.Errorf("entry exists = %v, want expired entries removed", exists)
+			}
+		})
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c, clock := newTestCache(t, config.CacheConfig{})
+	value := strings.Repeat("x", 1000)
+	set := func(key string) {
+		t.Helper()
+		clock.advance(time.Minute)
+		if err := c.Set(key, value); err != nil {
+			t.Fatalf("Set(%s) error = %v", key, err)
+		}
+	}
+
+	set("a")
+	info, err := os.Stat(c.path("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Room for three entries and a half
+	c.maxBytes = info.Size()*3 + info.Size()/2
+	set("b")
+	set("c")
+
+	// Reading a makes b the least recently used entry
+	clock.advance(time.Minute)
+	var got string
+	if !c.Get("a", &got) {
+		t.Fatal("Get(a) missed before eviction")
+	}
+	set("d")
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if hit := c.Get(key, &got); hit != want {
+			t.Errorf("Get(%s) = %v, want %v", key, hit, want)
+		}
+	}
+	if c.size > c.maxBytes*9/10 {
+		t.Errorf("size = %d after eviction, want at most %d", c.size, c.maxBytes*9/10)
+	}
+}
+
+func TestCacheIgnoresCorruptEntries(t *testing.T) {
+	c, _ := newTestCache(t, config.CacheConfig{})
+	raw := `{"key":"key","created_at":"2025-01-01T00:00:00Z","value":"value"}`
+
+	tests := map[string]string{
+		"not json":      "garbage",
+		"partial":       raw[:len(raw)/2],
+		"other key":     strings.Replace(raw, `"key":"key"`, `"key":"other"`, 1),
+		"invalid value": strings.Replace(raw, `"value":"value"`, `"value":42`, 1),
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := os.WriteFile(c.path("key"), []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if c.Get("key", &got) {
+				t.Fatalf("Get() hit a corrupt entry: %q", got)
+			}
+			if err := c.Set("key", "value"); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if !c.Get("key", &got) || got != "value" {
+				t.Errorf("Get() = %q after overwriting the corrupt entry", got)
+			}
+		})
+	}
+}
+
+func TestNewIgnoresLeftoverTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "entry-123.tmp"), []byte(strings.Repeat("x", 4096)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "entry"+entrySuffix), []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(config.CacheConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if c.size != 2 {
+		t.Errorf("size = %d, want only the entries counted", c.size)
+	}
+}
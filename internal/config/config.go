@@ -1,17 +1,167 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sashabaranov/go-openai"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	OpenAIKey          string `json:"-"`
-	ExaKey             string `json:"-"`
-	ExaEndpoint        string `json:"-"`
-	ExaNumSearchResult int    `json:"-"`
+	OpenAIKey     string `json:"-"`
+	OpenAIBaseURL string `json:"-"`
+	ExaKey        string `json:"-"`
+
+	// Credentials for the additional LLM providers that can be selected per stage
+	AnthropicKey            string `json:"-"`
+	GeminiKey               string `json:"-"`
+	OpenAICompatibleBaseURL string `json:"-"`
+	OpenAICompatibleKey     string `json:"-"`
+	ExaEndpoint             string `json:"-"`
+
+	// SearchProvider selects the backend used by the search tool
+	// (exa, tavily, brave, searxng or local)
+	SearchProvider   string `json:"-"`
+	SearchNumResults int    `json:"-"`
+	TavilyKey        string `json:"-"`
+	TavilyEndpoint   string `json:"-"`
+	BraveKey         string `json:"-"`
+	BraveEndpoint    string `json:"-"`
+	SearXNGEndpoint  string `json:"-"`
+	LocalCorpusDir   string `json:"-"`
+
+	// FetchMaxChars is the number of characters of a fetched page kept for
+	// summarization
+	FetchMaxChars int `json:"-"`
+
+	// FetchAllowPrivate lets fetched URLs resolve to loopback, link-local and
+	// private network addresses, for local testing
+	FetchAllowPrivate bool `json:"-"`
+
+	// SessionDir is where session checkpoints are stored for resuming
+	SessionDir string `json:"-"`
+
+	// PromptsDir holds <prompt>.tmpl files overriding the built-in prompts
+	PromptsDir string `json:"-"`
+
+	// Cache configures the on-disk cache of search results and summaries
+	Cache CacheConfig `json:"-"`
+
+	// Retry controls how failed LLM and search calls are retried
+	Retry RetryPolicy `json:"-"`
+
+	// Limits caps the load sent to each LLM and search provider, by provider name
+	Limits map[string]ProviderLimits `json:"-"`
+
+	// Cassette records or replays every outgoing HTTP call when its path is set
+	Cassette CassetteConfig `json:"-"`
+
+	// Transport is the base transport of LLM, search and fetch clients; nil
+	// uses the default transport
+	Transport http.RoundTripper `json:"-"`
+
+	// Profile is the name of the profile applied, if any
+	Profile string `json:"profile,omitempty"`
+
+	// Models configures the model used by each stage of the research pipeline
+	Models StageModels `json:"models"`
+
+	// Budget limits the work done by the web research loop
+	Budget ResearchBudget `json:"budget"`
+
+	// Prices maps model names to their token prices for cost accounting
+	Prices map[string]ModelPrice `json:"prices"`
+}
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	Input       float64 `json:"input" yaml:"input"`
+	CachedInput float64 `json:"cached_input" yaml:"cached_input"`
+	// CacheWriteInput is the price of input written to the prompt cache,
+	// which Anthropic bills above the input price
+	CacheWriteInput float64 `json:"cache_write_input" yaml:"cache_write_input"`
+	Output          float64 `json:"output" yaml:"output"`
+}
+
+// ResearchBudget holds the hard limits of the web research loop. Search,
+// iteration and token limits are shared by all sub-researchers of a session.
+// A zero value disables the corresponding limit.
+type ResearchBudget struct {
+	// MaxSubtopics caps the number of sub-researchers the supervisor starts;
+	// 1 researches the brief with a single agent
+	MaxSubtopics   int           `json:"max_subtopics" yaml:"max_subtopics"`
+	MaxSearchCalls int           `json:"max_search_calls" yaml:"max_search_calls"`
+	MaxIterations  int           `json:"max_iterations" yaml:"max_iterations"`
+	MaxTokens      int           `json:"max_tokens" yaml:"max_tokens"`
+	MaxDuration    time.Duration `json:"max_duration" yaml:"max_duration"`
+}
+
+// CacheConfig configures the on-disk response cache.
+type CacheConfig struct {
+	Dir       string        `yaml:"dir"`
+	TTL       time.Duration `yaml:"ttl"`
+	MaxSizeMB int           `yaml:"max_size_mb"`
+	Disabled  bool          `yaml:"disabled"`
+}
+
+// CassetteConfig selects the HTTP cassette used to record a session, or to
+// replay it offline. Mode is record or replay.
+type CassetteConfig struct {
+	Path string
+	Mode string
+}
+
+// RetryPolicy controls how calls to LLM and search providers are retried.
+// After BreakerThreshold consecutive failed calls a provider is not called
+// again for BreakerCooldown; a zero threshold disables the circuit breaker.
+type RetryPolicy struct {
+	MaxRetries       int           `yaml:"max_retries"`
+	BaseDelay        time.Duration `yaml:"base_delay"`
+	MaxDelay         time.Duration `yaml:"max_delay"`
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
+}
+
+// ProviderLimits caps the load sent to a single provider. A zero value
+// disables the corresponding limit.
+type ProviderLimits struct {
+	MaxConcurrent     int `yaml:"max_concurrency"`
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+}
+
+// limitedProviders lists the providers whose limits can be configured.
+var limitedProviders = []string{"openai", "anthropic", "gemini", "openai-compatible", "exa", "tavily", "brave", "searxng"}
+
+// StageConfig holds the model settings for a single pipeline stage. Zero
+// values leave the corresponding request parameter unset.
+type StageConfig struct {
+	// Provider is the LLM backend (openai, anthropic, gemini or openai-compatible)
+	Provider        string  `json:"provider" yaml:"provider"`
+	Model           string  `json:"model" yaml:"model"`
+	Temperature     float32 `json:"temperature" yaml:"temperature"`
+	ReasoningEffort string  `json:"reasoning_effort" yaml:"reasoning_effort"`
+	MaxTokens       int     `json:"max_tokens" yaml:"max_tokens"`
+}
+
+// StageModels holds the model settings for every pipeline stage.
+type StageModels struct {
+	Clarify    StageConfig `json:"clarify" yaml:"clarify"`
+	Brief      StageConfig `json:"brief" yaml:"brief"`
+	Supervisor StageConfig `json:"supervisor" yaml:"supervisor"`
+	Research   StageConfig `json:"research" yaml:"research"`
+	Summarizer StageConfig `json:"summarizer" yaml:"summarizer"`
+	Report     StageConfig `json:"report" yaml:"report"`
 }
 
 type ConfigError struct {
@@ -24,37 +174,483 @@ func (e *ConfigError) Error() string {
 	return fmt.Sprintf("configuration error for field '%s': %s", e.Field, e.Message)
 }
 
+func defaultStageModels() StageModels {
+	return StageModels{
+		Clarify:    StageConfig{Provider: "openai", Model: openai.GPT5},
+		Brief:      StageConfig{Provider: "openai", Model: openai.GPT5},
+		Supervisor: StageConfig{Provider: "openai", Model: openai.GPT5},
+		Research:   StageConfig{Provider: "openai", Model: openai.GPT5},
+		Summarizer: StageConfig{Provider: "openai", Model: openai.GPT4o},
+		Report:     StageConfig{Provider: "openai", Model: openai.GPT5},
+	}
+}
+
+func defaultResearchBudget() ResearchBudget {
+	return ResearchBudget{
+		MaxSubtopics:   4,
+		MaxSearchCalls: 20,
+		MaxIterations:  48,
+		MaxTokens:      1200000,
+		MaxDuration:    10 * time.Minute,
+	}
+}
+
+// defaultModelPrices lists the list prices of commonly used models. Prices
+// from the config file are added to, or replace, these entries.
+func defaultModelPrices() map[string]ModelPrice {
+	return map[string]ModelPrice{
+		"gpt-5":            {Input: 1.25, CachedInput: 0.125, Output: 10},
+		"gpt-5-mini":       {Input: 0.25, CachedInput: 0.025, Output: 2},
+		"gpt-5-nano":       {Input: 0.05, CachedInput: 0.005, Output: 0.4},
+		"gpt-4.1":          {Input: 2, CachedInput: 0.5, Output: 8},
+		"gpt-4.1-mini":     {Input: 0.4, CachedInput: 0.1, Output: 1.6},
+		"gpt-4o":           {Input: 2.5, CachedInput: 1.25, Output: 10},
+		"gpt-4o-mini":      {Input: 0.15, CachedInput: 0.075, Output: 0.6},
+		"o3":               {Input: 2, CachedInput: 0.5, Output: 8},
+		"o4-mini":          {Input: 1.1, CachedInput: 0.275, Output: 4.4},
+		"claude-opus-4":    {Input: 15, CachedInput: 1.5, CacheWriteInput: 18.75, Output: 75},
+		"claude-sonnet-4":  {Input: 3, CachedInput: 0.3, CacheWriteInput: 3.75, Output: 15},
+		"claude-3-5-haiku": {Input: 0.8, CachedInput: 0.08, CacheWriteInput: 1, Output: 4},
+		"gemini-2.5-pro":   {Input: 1.25, CachedInput: 0.31, Output: 10},
+		"gemini-2.5-flash": {Input: 0.3, CachedInput: 0.075, Output: 2.5},
+		"gemini-2.0-flash": {Input: 0.1, CachedInput: 0.025, Output: 0.4},
+	}
+}
+
+// builtinProfiles are the profiles available without a config file, in the
+// config file layout. Profiles of the same name in the config file replace
+// them.
+const builtinProfiles = `
+cheap:
+  models:
+    clarify: {provider: openai, model: gpt-5-mini}
+    brief: {provider: openai, model: gpt-5-mini}
+    supervisor: {provider: openai, model: gpt-5-mini}
+    research: {provider: openai, model: gpt-5-mini}
+    summarizer: {provider: openai, model: gpt-5-nano}
+    report: {provider: openai, model: gpt-5-mini}
+  budget:
+    max_subtopics: 2
+    max_search_calls: 6
+    max_iterations: 12
+    max_tokens: 200000
+    max_duration: 5m
+thorough:
+  models:
+    clarify: {provider: openai, model: gpt-5}
+    brief: {provider: openai, model: gpt-5}
+    supervisor: {provider: openai, model: gpt-5, reasoning_effort: high}
+    research: {provider: openai, model: gpt-5, reasoning_effort: high}
+    summarizer: {provider: openai, model: gpt-5-mini}
+    report: {provider: openai, model: gpt-5, reasoning_effort: high}
+  budget:
+    max_subtopics: 6
+    max_search_calls: 60
+    max_iterations: 144
+    max_tokens: 6000000
+    max_duration: 30m
+`
+
+// Options selects the config file and profile, and overrides individual
+// settings, typically from command line flags. The zero value loads the
+// config file named by DEEP_RESEARCH_CONFIG, if any, and env vars.
+type Options struct {
+	// Path is the YAML or TOML config file, overriding DEEP_RESEARCH_CONFIG
+	Path string
+	// Profile overrides DEEP_RESEARCH_PROFILE and the profile of the config file
+	Profile string
+	// Overrides maps env var names to values that take precedence over the
+	// environment, e.g. BUDGET_MAX_SUBTOPICS=2
+	Overrides map[string]string
+}
+
+// settings holds everything that can be set in the config file or a
+// profile. Env vars and overrides are applied on top of it.
+type settings struct {
+	Endpoints         endpointSettings          `yaml:"endpoints"`
+	Search            searchSettings            `yaml:"search"`
+	FetchMaxChars     int                       `yaml:"fetch_max_chars"`
+	FetchAllowPrivate bool                      `yaml:"fetch_allow_private"`
+	SessionDir        string                    `yaml:"session_dir"`
+	PromptsDir        string                    `yaml:"prompts_dir"`
+	Cache             CacheConfig               `yaml:"cache"`
+	Retry             RetryPolicy               `yaml:"retry"`
+	Limits            map[string]ProviderLimits `yaml:"limits"`
+	Models            StageModels               `yaml:"models"`
+	Budget            ResearchBudget            `yaml:"budget"`
+	Prices            map[string]ModelPrice     `yaml:"prices"`
+}
+
+type endpointSettings struct {
+	OpenAI           string `yaml:"openai"`
+	OpenAICompatible string `yaml:"openai_compatible"`
+	Exa              string `yaml:"exa"`
+	SearXNG          string `yaml:"searxng"`
+}
+
+type searchSettings struct {
+	Provider       string `yaml:"provider"`
+	NumResults     int    `yaml:"num_results"`
+	LocalCorpusDir string `yaml:"local_corpus_dir"`
+}
+
+// fileConfig is the layout of the optional config file: settings, the
+// profile used by default and additional named profiles.
+type fileConfig struct {
+	Settings settings             `yaml:",inline"`
+	Profile  string               `yaml:"profile"`
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+func defaultSettings() settings {
+	return settings{
+		Endpoints: endpointSettings{
+			OpenAI:           "https://api.openai.com/v1",
+			OpenAICompatible: "http://localhost:11434/v1",
+			Exa:              "https://api.exa.ai/search",
+			SearXNG:          "http://localhost:8080/search",
+		},
+		Search:        searchSettings{Provider: "exa", NumResults: 10},
+		FetchMaxChars: 20000,
+		SessionDir:    defaultDataDir("sessions"),
+		Cache: CacheConfig{
+			Dir:       defaultDataDir("cache"),
+			TTL:       24 * time.Hour,
+			MaxSizeMB: 256,
+		},
+		Retry: RetryPolicy{
+			MaxRetries:       4,
+			BaseDelay:        time.Second,
+			MaxDelay:         30 * time.Second,
+			BreakerThreshold: 5,
+			BreakerCooldown:  30 * time.Second,
+		},
+		Limits: make(map[string]ProviderLimits),
+		Models: defaultStageModels(),
+		Budget: defaultResearchBudget(),
+		Prices: defaultModelPrices(),
+	}
+}
+
+// LoadConfig loads the configuration from the config file named by
+// DEEP_RESEARCH_CONFIG and env vars.
 func LoadConfig() (*Config, error) {
-	config := &Config{
-		OpenAIKey:          GetString("OPENAI_API_KEY", ""),
-		ExaKey:             GetString("EXA_API_KEY", ""),
-		ExaEndpoint:        "https://api.exa.ai/search",
-		ExaNumSearchResult: 10,
+	return Load(Options{})
+}
+
+// Load resolves the configuration from defaults, then the config file, then
+// the selected profile, then env vars, then opts.Overrides, and validates
+// the result. Every problem is reported as a *ConfigError.
+func Load(opts Options) (*Config, error) {
+	env := newEnv(opts.Overrides)
+	s := defaultSettings()
+	profiles := make(map[string]yaml.Node)
+	if err := yaml.Unmarshal([]byte(builtinProfiles), &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse built-in profiles: %w", err)
+	}
+
+	path := opts.Path
+	if path == "" {
+		path = env.String("DEEP_RESEARCH_CONFIG", "")
+	}
+	defaultProfile := ""
+	if path != "" {
+		fc, err := loadConfigFile(path, s)
+		if err != nil {
+			return nil, err
+		}
+		s = fc.Settings
+		defaultProfile = fc.Profile
+		for name, profile := range fc.Profiles {
+			profiles[name] = profile
+		}
+	}
+
+	profile := opts.Profile
+	if profile == "" {
+		profile = env.String("DEEP_RESEARCH_PROFILE", defaultProfile)
+	}
+	if profile != "" {
+		if err := applyProfile(&s, profiles, profile); err != nil {
+			return nil, err
+		}
 	}
 
+	config := &Config{
+		OpenAIKey:               env.String("OPENAI_API_KEY", ""),
+		OpenAIBaseURL:           env.String("OPENAI_BASE_URL", s.Endpoints.OpenAI),
+		ExaKey:                  env.String("EXA_API_KEY", ""),
+		AnthropicKey:            env.String("ANTHROPIC_API_KEY", ""),
+		GeminiKey:               env.String("GEMINI_API_KEY", ""),
+		OpenAICompatibleBaseURL: env.String("OPENAI_COMPATIBLE_BASE_URL", s.Endpoints.OpenAICompatible),
+		OpenAICompatibleKey:     env.String("OPENAI_COMPATIBLE_API_KEY", ""),
+		ExaEndpoint:             env.String("EXA_ENDPOINT", s.Endpoints.Exa),
+		SearchProvider:          strings.ToLower(env.String("SEARCH_PROVIDER", s.Search.Provider)),
+		SearchNumResults:        env.Int("SEARCH_NUM_RESULTS", s.Search.NumResults),
+		TavilyKey:               env.String("TAVILY_API_KEY", ""),
+		TavilyEndpoint:          "https://api.tavily.com/search",
+		BraveKey:                env.String("BRAVE_API_KEY", ""),
+		BraveEndpoint:           "https://api.search.brave.com/res/v1/web/search",
+		SearXNGEndpoint:         env.String("SEARXNG_ENDPOINT", s.Endpoints.SearXNG),
+		LocalCorpusDir:          env.String("LOCAL_CORPUS_DIR", s.Search.LocalCorpusDir),
+		FetchMaxChars:           env.Int("FETCH_MAX_CHARS", s.FetchMaxChars),
+		FetchAllowPrivate:       env.Bool("FETCH_ALLOW_PRIVATE", s.FetchAllowPrivate),
+		SessionDir:              env.String("DEEP_RESEARCH_SESSION_DIR", s.SessionDir),
+		PromptsDir:              env.String("DEEP_RESEARCH_PROMPTS_DIR", s.PromptsDir),
+		Cache: CacheConfig{
+			Dir:       env.String("CACHE_DIR", s.Cache.Dir),
+			TTL:       env.Duration("CACHE_TTL", s.Cache.TTL),
+			MaxSizeMB: env.Int("CACHE_MAX_SIZE_MB", s.Cache.MaxSizeMB),
+			Disabled:  env.Bool("CACHE_DISABLED", s.Cache.Disabled),
+		},
+		Models: StageModels{
+			Clarify:    env.stage("CLARIFY", s.Models.Clarify),
+			Brief:      env.stage("BRIEF", s.Models.Brief),
+			Supervisor: env.stage("SUPERVISOR", s.Models.Supervisor),
+			Research:   env.stage("RESEARCH", s.Models.Research),
+			Summarizer: env.stage("SUMMARIZER", s.Models.Summarizer),
+			Report:     env.stage("REPORT", s.Models.Report),
+		},
+		Budget: ResearchBudget{
+			MaxSubtopics:   env.Int("BUDGET_MAX_SUBTOPICS", s.Budget.MaxSubtopics),
+			MaxSearchCalls: env.Int("BUDGET_MAX_SEARCH_CALLS", s.Budget.MaxSearchCalls),
+			MaxIterations:  env.Int("BUDGET_MAX_ITERATIONS", s.Budget.MaxIterations),
+			MaxTokens:      env.Int("BUDGET_MAX_TOKENS", s.Budget.MaxTokens),
+			MaxDuration:    env.Duration("BUDGET_MAX_DURATION", s.Budget.MaxDuration),
+		},
+		Prices: s.Prices,
+		Retry: RetryPolicy{
+			MaxRetries:       env.Int("RETRY_MAX_RETRIES", s.Retry.MaxRetries),
+			BaseDelay:        env.Duration("RETRY_BASE_DELAY", s.Retry.BaseDelay),
+			MaxDelay:         env.Duration("RETRY_MAX_DELAY", s.Retry.MaxDelay),
+			BreakerThreshold: env.Int("CIRCUIT_BREAKER_THRESHOLD", s.Retry.BreakerThreshold),
+			BreakerCooldown:  env.Duration("CIRCUIT_BREAKER_COOLDOWN", s.Retry.BreakerCooldown),
+		},
+		Limits: env.providerLimits(s.Limits),
+		Cassette: CassetteConfig{
+			Path: env.String("DEEP_RESEARCH_CASSETTE", ""),
+			Mode: strings.ToLower(env.String("DEEP_RESEARCH_CASSETTE_MODE", "replay")),
+		},
+		Profile: profile,
+	}
+	if err := env.check(); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
-func GetEnvOrDefault[T any](key string, def T, parser func(string) (T, error)) T {
-	raw := strings.TrimSpace(os.Getenv(key))
+// defaultDataDir returns ~/.deep-research/<name>, falling back to a
+// directory relative to the working directory when there is no home directory.
+func defaultDataDir(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".deep-research", name)
+	}
+	return filepath.Join(home, ".deep-research", name)
+}
+
+// loadConfigFile reads the YAML or TOML config file at path, chosen by its
+// extension, on top of s. Settings missing from the file keep their value in
+// s, and unknown keys are rejected.
+func loadConfigFile(path string, s settings) (*fileConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &ConfigError{Field: "DEEP_RESEARCH_CONFIG", Value: path, Message: err.Error()}
+	}
+
+	format := "YAML"
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		format = "TOML"
+		var doc map[string]any
+		if err := toml.Unmarshal(raw, &doc); err != nil {
+			return nil, &ConfigError{Field: "DEEP_RESEARCH_CONFIG", Value: path, Message: fmt.Sprintf("invalid TOML: %v", err)}
+		}
+		// TOML documents are converted to YAML so both formats share the
+		// same field names and decoding rules
+		if raw, err = yaml.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("failed to convert TOML config: %w", err)
+		}
+	}
+
+	fc := fileConfig{Settings: s}
+	if err := decodeStrict(raw, &fc); err != nil {
+		return nil, &ConfigError{Field: "DEEP_RESEARCH_CONFIG", Value: path, Message: fmt.Sprintf("invalid %s: %v", format, err)}
+	}
+	return &fc, nil
+}
+
+// applyProfile applies the named profile to s.
+func applyProfile(s *settings, profiles map[string]yaml.Node, name string) error {
+	profile, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return &ConfigError{Field: "profile", Value: name, Message: fmt.Sprintf("unknown profile %q, expected one of %s", name, strings.Join(names, ", "))}
+	}
+	raw, err := yaml.Marshal(&profile)
+	if err != nil {
+		return fmt.Errorf("failed to encode profile %s: %w", name, err)
+	}
+	if err := decodeStrict(raw, s); err != nil {
+		return &ConfigError{Field: "profiles." + name, Value: name, Message: fmt.Sprintf("invalid profile: %v", err)}
+	}
+	return nil
+}
+
+// decodeStrict decodes YAML into v, rejecting keys v has no field for. An
+// empty document leaves v unchanged.
+func decodeStrict(raw []byte, v any) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// env resolves settings from overrides, then env vars. Invalid values and
+// overrides of unknown settings are reported by check.
+type env struct {
+	overrides map[string]string
+	used      map[string]bool
+	err       error
+}
+
+func newEnv(overrides map[string]string) *env {
+	return &env{overrides: overrides, used: make(map[string]bool)}
+}
+
+func (e *env) lookup(key string) string {
+	e.used[key] = true
+	if value, ok := e.overrides[key]; ok {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(os.Getenv(key))
+}
+
+// check returns the first invalid value, or an error for an override of a
+// setting that does not exist.
+func (e *env) check() error {
+	if e.err != nil {
+		return e.err
+	}
+	keys := make([]string, 0, len(e.overrides))
+	for key := range e.overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !e.used[key] {
+			return &ConfigError{Field: key, Value: e.overrides[key], Message: "unknown setting"}
+		}
+	}
+	return nil
+}
+
+func getValue[T any](e *env, key string, def T, parser func(string) (T, error)) T {
+	raw := e.lookup(key)
 	if raw == "" {
 		return def
 	}
 	val, err := parser(raw)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: invalid %s=%q, using default %v\n", key, raw, def)
+		if e.err == nil {
+			e.err = &ConfigError{Field: key, Value: raw, Message: fmt.Sprintf("invalid value %q", raw)}
+		}
 		return def
 	}
 	return val
 }
 
-func StringParser(s string) (string, error) { return s, nil }
-func IntParser(s string) (int, error)       { return strconv.Atoi(s) }
+func (e *env) String(key string, def string) string {
+	return getValue(e, key, def, StringParser)
+}
+
+func (e *env) Int(key string, def int) int {
+	return getValue(e, key, def, IntParser)
+}
 
-func GetString(key string, def string) string {
-	return GetEnvOrDefault(key, def, StringParser)
+func (e *env) Float32(key string, def float32) float32 {
+	return getValue(e, key, def, func(s string) (float32, error) {
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), err
+	})
 }
 
-func GetInt(key string, def int) int {
-	return GetEnvOrDefault(key, def, IntParser)
+func (e *env) Bool(key string, def bool) bool {
+	return getValue(e, key, def, strconv.ParseBool)
 }
+
+func (e *env) Duration(key string, def time.Duration) time.Duration {
+	return getValue(e, key, def, time.ParseDuration)
+}
+
+// providerLimits applies the <PROVIDER>_MAX_CONCURRENCY and
+// <PROVIDER>_REQUESTS_PER_MINUTE limits of every provider, e.g.
+// EXA_MAX_CONCURRENCY or OPENAI_COMPATIBLE_REQUESTS_PER_MINUTE, to def.
+func (e *env) providerLimits(def map[string]ProviderLimits) map[string]ProviderLimits {
+	limits := make(map[string]ProviderLimits, len(limitedProviders))
+	for name, limit := range def {
+		limits[name] = limit
+	}
+	for _, name := range limitedProviders {
+		prefix := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		limits[name] = ProviderLimits{
+			MaxConcurrent:     e.Int(prefix+"_MAX_CONCURRENCY", def[name].MaxConcurrent),
+			RequestsPerMinute: e.Int(prefix+"_REQUESTS_PER_MINUTE", def[name].RequestsPerMinute),
+		}
+	}
+	return limits
+}
+
+// stage applies the <PREFIX>_PROVIDER, <PREFIX>_MODEL, <PREFIX>_TEMPERATURE,
+// <PREFIX>_REASONING_EFFORT and <PREFIX>_MAX_TOKENS overrides to def.
+func (e *env) stage(prefix string, def StageConfig) StageConfig {
+	return StageConfig{
+		Provider:        strings.ToLower(e.String(prefix+"_PROVIDER", def.Provider)),
+		Model:           e.String(prefix+"_MODEL", def.Model),
+		Temperature:     e.Float32(prefix+"_TEMPERATURE", def.Temperature),
+		ReasoningEffort: e.String(prefix+"_REASONING_EFFORT", def.ReasoningEffort),
+		MaxTokens:       e.Int(prefix+"_MAX_TOKENS", def.MaxTokens),
+	}
+}
+
+// GetEnvOrDefault returns the env var key parsed with parser, or def when it
+// is unset or invalid. Invalid values are reported on stderr.
+//
+// Deprecated: Use Load, which also reads the config file and profiles and
+// reports invalid values as a *ConfigError.
+func GetEnvOrDefault[T any](key string, def T, parser func(string) (T, error)) T {
+	e := newEnv(nil)
+	val := getValue(e, key, def, parser)
+	if e.err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: invalid %s=%q, using default %v\n", key, e.lookup(key), def)
+	}
+	return val
+}
+
+// StringParser returns s unchanged.
+//
+// Deprecated: Use Load.
+func StringParser(s string) (string, error) { return s, nil }
+
+// IntParser parses s as a decimal int.
+//
+// Deprecated: Use Load.
+func IntParser(s string) (int, error) { return strconv.Atoi(s) }
+
+// GetString returns the env var key, or def when it is unset.
+//
+// Deprecated: Use Load.
+func GetString(key string, def string) string { return GetEnvOrDefault(key, def, StringParser) }
+
+// GetInt returns the env var key as an int, or def when it is unset or invalid.
+//
+// Deprecated: Use Load.
+func GetInt(key string, def int) int { return GetEnvOrDefault(key, def, IntParser) }
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// isolateEnv clears the env vars the tests depend on and sets the API keys
+// validation requires, so the tests do not pick up the developer's settings.
+func isolateEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{
+		"DEEP_RESEARCH_CONFIG", "DEEP_RESEARCH_PROFILE", "DEEP_RESEARCH_CASSETTE", "DEEP_RESEARCH_CASSETTE_MODE",
+		"SEARCH_PROVIDER", "SEARCH_NUM_RESULTS", "EXA_ENDPOINT", "OPENAI_BASE_URL",
+		"RESEARCH_PROVIDER", "RESEARCH_MODEL", "RESEARCH_TEMPERATURE", "REPORT_MODEL",
+		"BUDGET_MAX_SUBTOPICS", "BUDGET_MAX_SEARCH_CALLS", "BUDGET_MAX_DURATION", "CACHE_TTL",
+	} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("OPENAI_API_KEY", "test-openai-key")
+	t.Setenv("EXA_API_KEY", "test-exa-key")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFileFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+search:
+  provider: searxng
+  num_results: 5
+budget:
+  max_subtopics: 3
+  max_duration: 2m
+models:
+  research: {provider: openai, model: gpt-5-mini, temperature: 0.5}
+prices:
+  my-model: {input: 1, output: 2}
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `
+[search]
+provider = "searxng"
+num_results = 5
+
+[budget]
+max_subtopics = 3
+max_duration = "2m"
+
+[models.research]
+provider = "openai"
+model = "gpt-5-mini"
+temperature = 0.5
+
+[prices.my-model]
+input = 1
+output = 2
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			config, err := Load(Options{Path: writeFile(t, tt.file, tt.content)})
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if config.SearchProvider != "searxng" || config.SearchNumResults != 5 {
+				t.Errorf("search = %s/%d, want searxng/5", config.SearchProvider, config.SearchNumResults)
+			}
+			if config.Budget.MaxSubtopics != 3 || config.Budget.MaxDuration != 2*time.Minute {
+				t.Errorf("budget = %+v, want 3 subtopics and 2m", config.Budget)
+			}
+			want := StageConfig{Provider: "openai", Model: "gpt-5-mini", Temperature: 0.5}
+			if config.Models.Research != want {
+				t.Errorf("research stage = %+v, want %+v", config.Models.Research, want)
+			}
+			if price := config.Prices["my-model"]; price.Input != 1 || price.Output != 2 {
+				t.Errorf("my-model price = %+v, want input 1 and output 2", price)
+			}
+			// Settings missing from the file keep their defaults
+			if config.Budget.MaxSearchCalls != defaultResearchBudget().MaxSearchCalls {
+				t.Errorf("max search calls = %d, want the default %d", config.Budget.MaxSearchCalls, defaultResearchBudget().MaxSearchCalls)
+			}
+			if _, ok := config.Prices["gpt-5"]; !ok {
+				t.Error("default gpt-5 price was dropped")
+			}
+		})
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const file = `
+budget:
+  max_subtopics: 3
+profiles:
+  wide:
+    budget:
+      max_subtopics: 5
+`
+	tests := []struct {
+		name      string
+		file      bool
+		profile   string
+		env       string
+		overrides map[string]string
+		want      int
+	}{
+		{name: "default", want: defaultResearchBudget().MaxSubtopics},
+		{name: "file over default", file: true, want: 3},
+		{name: "profile over file", file: true, profile: "wide", want: 5},
+		{name: "env over profile", file: true, profile: "wide", env: "7", want: 7},
+		{
+			name: "override over env", file: true, profile: "wide", env: "7",
+			overrides: map[string]string{"BUDGET_MAX_SUBTOPICS": "9"}, want: 9,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			opts := Options{Profile: tt.profile, Overrides: tt.overrides}
+			if tt.file {
+				opts.Path = writeFile(t, "config.yaml", file)
+			}
+			t.Setenv("BUDGET_MAX_SUBTOPICS", tt.env)
+			config, err := Load(opts)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if config.Budget.MaxSubtopics != tt.want {
+				t.Errorf("max subtopics = %d, want %d", config.Budget.MaxSubtopics, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	const file = `
+profile: fast
+profiles:
+  fast:
+    budget:
+      max_subtopics: 1
+  cheap:
+    budget:
+      max_subtopics: 3
+`
+	tests := []struct {
+		name         string
+		file         bool
+		envProfile   string
+		optsProfile  string
+		wantProfile  string
+		wantSubtopic int
+	}{
+		{name: "none", wantSubtopic: defaultResearchBudget().MaxSubtopics},
+		{name: "built-in", optsProfile: "thorough", wantProfile: "thorough", wantSubtopic: 6},
+		{name: "built-in from env", envProfile: "cheap", wantProfile: "cheap", wantSubtopic: 2},
+		{name: "default of the file", file: true, wantProfile: "fast", wantSubtopic: 1},
+		{name: "env over the file default", file: true, envProfile: "thorough", wantProfile: "thorough", wantSubtopic: 6},
+		{name: "option over env", file: true, envProfile: "thorough", optsProfile: "fast", wantProfile: "fast", wantSubtopic: 1},
+		{name: "file replaces built-in", file: true, optsProfile: "cheap", wantProfile: "cheap", wantSubtopic: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			t.Setenv("DEEP_RESEARCH_PROFILE", tt.envProfile)
+			opts := Options{Profile: tt.optsProfile}
+			if tt.file {
+				opts.Path = writeFile(t, "config.yaml", file)
+			}
+			config, err := Load(opts)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if config.Profile != tt.wantProfile {
+				t.Errorf("profile = %q, want %q", config.Profile, tt.wantProfile)
+			}
+			if config.Budget.MaxSubtopics != tt.wantSubtopic {
+				t.Errorf("max subtopics = %d, want %d", config.Budget.MaxSubtopics, tt.wantSubtopic)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		content   string
+		profile   string
+		overrides map[string]string
+		wantField string
+	}{
+		{name: "unknown profile", profile: "nope", wantField: "profile"},
+		{name: "unknown override", overrides: map[string]string{"BUDGET_MAX_SUBTOPIC": "2"}, wantField: "BUDGET_MAX_SUBTOPIC"},
+		{name: "invalid int", overrides: map[string]string{"BUDGET_MAX_SUBTOPICS": "many"}, wantField: "BUDGET_MAX_SUBTOPICS"},
+		{name: "invalid duration", overrides: map[string]string{"CACHE_TTL": "1 day"}, wantField: "CACHE_TTL"},
+		{name: "out of range", overrides: map[string]string{"BUDGET_MAX_SUBTOPICS": "21"}, wantField: "BUDGET_MAX_SUBTOPICS"},
+		{name: "negative duration", overrides: map[string]string{"BUDGET_MAX_DURATION": "-1m"}, wantField: "BUDGET_MAX_DURATION"},
+		{name: "temperature", overrides: map[string]string{"RESEARCH_TEMPERATURE": "3"}, wantField: "RESEARCH_TEMPERATURE"},
+		{name: "unknown provider", overrides: map[string]string{"RESEARCH_PROVIDER": "acme"}, wantField: "RESEARCH_PROVIDER"},
+		{name: "missing key", overrides: map[string]string{"OPENAI_API_KEY": " "}, wantField: "OPENAI_API_KEY"},
+		{name: "invalid url", overrides: map[string]string{"EXA_ENDPOINT": "api.exa.ai"}, wantField: "EXA_ENDPOINT"},
+		{name: "unknown search provider", overrides: map[string]string{"SEARCH_PROVIDER": "bing"}, wantField: "SEARCH_PROVIDER"},
+		{name: "missing file", file: "missing.yaml", wantField: "DEEP_RESEARCH_CONFIG"},
+		{name: "unknown file key", file: "config.yaml", content: "budgets:\n  max_subtopics: 2\n", wantField: "DEEP_RESEARCH_CONFIG"},
+		{name: "invalid toml", file: "config.toml", content: "[budget\n", wantField: "DEEP_RESEARCH_CONFIG"},
+		{name: "negative price", file: "config.yaml", content: "prices:\n  gpt-5: {input: -1}\n", wantField: "prices.gpt-5"},
+		{name: "negative limit", file: "config.toml", content: "[limits.exa]\nmax_concurrency = -1\n", wantField: "limits.exa"},
+		{name: "invalid profile", file: "config.yaml", content: "profiles:\n  bad:\n    budget: {subtopics: 1}\n", profile: "bad", wantField: "profiles.bad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			opts := Options{Profile: tt.profile, Overrides: tt.overrides}
+			if tt.file != "" {
+				opts.Path = filepath.Join(t.TempDir(), tt.file)
+				if tt.content != "" {
+					opts.Path = writeFile(t, tt.file, tt.content)
+				}
+			}
+			_, err := Load(opts)
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("Load() error = %v, want a *ConfigError", err)
+			}
+			if configErr.Field != tt.wantField {
+				t.Errorf("error field = %q, want %q (%v)", configErr.Field, tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestGetEnvOrDefault(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{name: "unset", value: "", want: 7},
+		{name: "set", value: " 3 ", want: 3},
+		{name: "invalid", value: "three", want: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEEP_RESEARCH_TEST_INT", tt.value)
+			if got := GetInt("DEEP_RESEARCH_TEST_INT", 7); got != tt.want {
+				t.Errorf("GetInt() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+	t.Setenv("DEEP_RESEARCH_TEST_STRING", "value")
+	if got := GetString("DEEP_RESEARCH_TEST_STRING", "default"); got != "value" {
+		t.Errorf("GetString() = %q, want %q", got, "value")
+	}
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Validate checks that the configuration is usable before any API call is
+// made: every provider in use is known and has its API key, endpoints are
+// valid URLs and numbers are within range. It returns the first problem as a
+// *ConfigError.
+func (c *Config) Validate() error {
+	stages := []struct {
+		name  string
+		stage StageConfig
+	}{
+		{"CLARIFY", c.Models.Clarify},
+		{"BRIEF", c.Models.Brief},
+		{"SUPERVISOR", c.Models.Supervisor},
+		{"RESEARCH", c.Models.Research},
+		{"SUMMARIZER", c.Models.Summarizer},
+		{"REPORT", c.Models.Report},
+	}
+	for _, s := range stages {
+		if err := c.validateStage(s.name, s.stage); err != nil {
+			return err
+		}
+	}
+	if err := c.validateSearch(); err != nil {
+		return err
+	}
+
+	checks := []struct {
+		field string
+		value int
+		min   int
+		max   int
+	}{
+		{"SEARCH_NUM_RESULTS", c.SearchNumResults, 1, 100},
+		{"FETCH_MAX_CHARS", c.FetchMaxChars, 0, -1},
+		{"BUDGET_MAX_SUBTOPICS", c.Budget.MaxSubtopics, 0, 20},
+		{"BUDGET_MAX_SEARCH_CALLS", c.Budget.MaxSearchCalls, 0, -1},
+		{"BUDGET_MAX_ITERATIONS", c.Budget.MaxIterations, 0, -1},
+		{"BUDGET_MAX_TOKENS", c.Budget.MaxTokens, 0, -1},
+		{"CACHE_MAX_SIZE_MB", c.Cache.MaxSizeMB, 0, -1},
+		{"RETRY_MAX_RETRIES", c.Retry.MaxRetries, 0, 20},
+		{"CIRCUIT_BREAKER_THRESHOLD", c.Retry.BreakerThreshold, 0, -1},
+	}
+	for _, check := range checks {
+		if err := checkRange(check.field, check.value, check.min, check.max); err != nil {
+			return err
+		}
+	}
+
+	durations := []struct {
+		field string
+		value int64
+	}{
+		{"BUDGET_MAX_DURATION", int64(c.Budget.MaxDuration)},
+		{"CACHE_TTL", int64(c.Cache.TTL)},
+		{"RETRY_BASE_DELAY", int64(c.Retry.BaseDelay)},
+		{"RETRY_MAX_DELAY", int64(c.Retry.MaxDelay)},
+		{"CIRCUIT_BREAKER_COOLDOWN", int64(c.Retry.BreakerCooldown)},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			return &ConfigError{Field: d.field, Value: strconv.FormatInt(d.value, 10), Message: "must not be negative"}
+		}
+	}
+
+	for name, limits := range c.Limits {
+		if limits.MaxConcurrent < 0 || limits.RequestsPerMinute < 0 {
+			return &ConfigError{Field: "limits." + name, Value: fmt.Sprintf("%+v", limits), Message: "limits must not be negative"}
+		}
+	}
+	for model, price := range c.Prices {
+		if price.Input < 0 || price.CachedInput < 0 || price.CacheWriteInput < 0 || price.Output < 0 {
+			return &ConfigError{Field: "prices." + model, Value: fmt.Sprintf("%+v", price), Message: "prices must not be negative"}
+		}
+	}
+
+	if c.Cassette.Mode != "record" && c.Cassette.Mode != "replay" {
+		return &ConfigError{Field: "DEEP_RESEARCH_CASSETTE_MODE", Value: c.Cassette.Mode, Message: "expected record or replay"}
+	}
+	return nil
+}
+
+// validateStage checks the model settings of a stage, and the key and
+// endpoint of its provider.
+func (c *Config) validateStage(name string, stage StageConfig) error {
+	if stage.Model == "" {
+		return &ConfigError{Field: name + "_MODEL", Message: "a model is required"}
+	}
+	if stage.Temperature < 0 || stage.Temperature > 2 {
+		return &ConfigError{Field: name + "_TEMPERATURE", Value: fmt.Sprint(stage.Temperature), Message: "must be between 0 and 2"}
+	}
+	if stage.MaxTokens < 0 {
+		return &ConfigError{Field: name + "_MAX_TOKENS", Value: strconv.Itoa(stage.MaxTokens), Message: "must not be negative"}
+	}
+	switch stage.ReasoningEffort {
+	case "", "minimal", "low", "medium", "high":
+	default:
+		return &ConfigError{Field: name + "_REASONING_EFFORT", Value: stage.ReasoningEffort, Message: "expected minimal, low, medium or high"}
+	}
+
+	switch stage.Provider {
+	case "", "openai":
+		if err := c.requireKey("OPENAI_API_KEY", c.OpenAIKey); err != nil {
+			return err
+		}
+		return checkURL("OPENAI_BASE_URL", c.OpenAIBaseURL)
+	case "anthropic":
+		return c.requireKey("ANTHROPIC_API_KEY", c.AnthropicKey)
+	case "gemini":
+		return c.requireKey("GEMINI_API_KEY", c.GeminiKey)
+	case "openai-compatible":
+		// Local servers usually need no key
+		return checkURL("OPENAI_COMPATIBLE_BASE_URL", c.OpenAICompatibleBaseURL)
+	default:
+		return &ConfigError{
+			Field:   name + "_PROVIDER",
+			Value:   stage.Provider,
+			Message: "unknown LLM provider, expected one of openai, anthropic, gemini or openai-compatible",
+		}
+	}
+}
+
+// validateSearch checks the key and endpoint of the search provider.
+func (c *Config) validateSearch() error {
+	switch c.SearchProvider {
+	case "exa":
+		if err := c.requireKey("EXA_API_KEY", c.ExaKey); err != nil {
+			return err
+		}
+		return checkURL("EXA_ENDPOINT", c.ExaEndpoint)
+	case "tavily":
+		return c.requireKey("TAVILY_API_KEY", c.TavilyKey)
+	case "brave":
+		return c.requireKey("BRAVE_API_KEY", c.BraveKey)
+	case "searxng":
+		return checkURL("SEARXNG_ENDPOINT", c.SearXNGEndpoint)
+	case "local":
+		if c.LocalCorpusDir == "" {
+			return &ConfigError{Field: "LOCAL_CORPUS_DIR", Message: "required when SEARCH_PROVIDER is local"}
+		}
+		return nil
+	default:
+		return &ConfigError{
+			Field:   "SEARCH_PROVIDER",
+			Value:   c.SearchProvider,
+			Message: "unknown search provider, expected one of exa, tavily, brave, searxng or local",
+		}
+	}
+}
+
+// requireKey fails when an API key is missing, unless the session is
+// replayed from a cassette and no provider is called.
+func (c *Config) requireKey(field, key string) error {
+	if key != "" || (c.Cassette.Path != "" && c.Cassette.Mode == "replay") {
+		return nil
+	}
+	return &ConfigError{Field: field, Message: "API key is required"}
+}
+
+// checkURL fails unless raw is an absolute http or https URL.
+func checkURL(field, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &ConfigError{Field: field, Value: raw, Message: "must be an absolute http or https URL"}
+	}
+	return nil
+}
+
+// checkRange fails unless min <= value <= max; a negative max means no
+// upper bound.
+func checkRange(field string, value, min, max int) error {
+	if value < min || (max >= 0 && value > max) {
+		message := fmt.Sprintf("must be at least %d", min)
+		if max >= 0 {
+			message = fmt.Sprintf("must be between %d and %d", min, max)
+		}
+		return &ConfigError{Field: field, Value: strconv.Itoa(value), Message: message}
+	}
+	return nil
+}
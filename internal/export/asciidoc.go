@@ -0,0 +1,178 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeAsciiDoc renders doc as an AsciiDoc document. Citations are cross
+// references to a bibliography listing the sources.
+func writeAsciiDoc(w io.Writer, doc Document) error {
+	title, blocks := splitTitle(doc)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "= %s\n", asciiDocText(title))
+	if !doc.Metadata.GeneratedAt.IsZero() {
+		fmt.Fprintf(&sb, ":revdate: %s\n", doc.Metadata.GeneratedAt.Format("2006-01-02"))
+	}
+	if doc.Metadata.SessionID != "" {
+		fmt.Fprintf(&sb, ":session-id: %s\n", doc.Metadata.SessionID)
+	}
+	if doc.Metadata.PromptVersion != "" {
+		fmt.Fprintf(&sb, ":prompt-version: %s\n", doc.Metadata.PromptVersion)
+	}
+	sb.WriteString("\n")
+
+	r := asciiDocRenderer{shift: minHeadingLevel(blocks) - 1, cited: citedNumbers(doc)}
+	r.blocks(&sb, blocks, 0)
+
+	if len(doc.Report.Sources) > 0 {
+		sb.WriteString("[bibliography]\n== Sources\n\n")
+		for _, cited := range doc.Report.Sources {
+			fmt.Fprintf(&sb, "* [[[source-%d,%d]]] %s", cited.Number, cited.Number, asciiDocLink(cited.Source.URL, asciiDocText(sourceTitle(cited))))
+			if cited.Source.PublishedDate != "" {
+				fmt.Fprintf(&sb, ", published %s", cited.Source.PublishedDate)
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type asciiDocRenderer struct {
+	// shift is subtracted from heading levels so the top level headings of
+	// the report become level one sections
+	shift int
+	// cited holds the numbers of the cited sources
+	cited map[int]bool
+}
+
+// blocks renders blocks nested depth lists deep. Blocks inside a list item
+// are attached to it with list continuations.
+func (r asciiDocRenderer) blocks(sb *strings.Builder, blocks []block, depth int) {
+	for i, b := range blocks {
+		if depth > 0 && i > 0 && b.kind != blockList {
+			sb.WriteString("+\n")
+		}
+		switch b.kind {
+		case blockHeading:
+			fmt.Fprintf(sb, "%s %s\n", strings.Repeat("=", b.level-r.shift+1), r.inlines(parseInlines(b.text)))
+		case blockParagraph:
+			sb.WriteString(r.inlines(parseInlines(b.text)) + "\n")
+		case blockList:
+			marker := "*"
+			if b.ordered {
+				marker = "."
+			}
+			if b.ordered && b.start != 1 {
+				fmt.Fprintf(sb, "[start=%d]\n", b.start)
+			}
+			for _, item := range b.items {
+				sb.WriteString(strings.Repeat(marker, depth+1) + " ")
+				if len(item) == 0 {
+					sb.WriteString("{empty}\n")
+					continue
+				}
+				r.blocks(sb, item, depth+1)
+			}
+		case blockQuote:
+			sb.WriteString("____\n")
+			var content strings.Builder
+			r.blocks(&content, b.children, 0)
+			sb.WriteString(strings.TrimRight(content.String(), "\n") + "\n")
+			sb.WriteString("____\n")
+		case blockCode:
+			if b.lang != "" {
+				fmt.Fprintf(sb, "[source,%s]\n", b.lang)
+			}
+			sb.WriteString("----\n" + b.text + "\n----\n")
+		case blockTable:
+			sb.WriteString("[options=\"header\"]\n|===\n")
+			sb.WriteString(r.tableRow(b.header) + "\n")
+			for _, row := range b.rows {
+				sb.WriteString(r.tableRow(row))
+			}
+			sb.WriteString("|===\n")
+		case blockRule:
+			sb.WriteString("'''\n")
+		}
+		if depth == 0 {
+			sb.WriteString("\n")
+		}
+	}
+}
+
+func (r asciiDocRenderer) tableRow(cells []string) string {
+	var sb strings.Builder
+	for _, cell := range cells {
+		sb.WriteString("|" + strings.ReplaceAll(r.inlines(parseInlines(cell)), "|", "\\|") + " ")
+	}
+	return strings.TrimRight(sb.String(), " ") + "\n"
+}
+
+func (r asciiDocRenderer) inlines(inlines []inline) string {
+	var sb strings.Builder
+	for _, in := range inlines {
+		switch in.kind {
+		case inlineText:
+			sb.WriteString(asciiDocText(in.text))
+		case inlineStrong:
+			sb.WriteString("*" + r.inlines(in.children) + "*")
+		case inlineEmphasis:
+			sb.WriteString("_" + r.inlines(in.children) + "_")
+		case inlineCode:
+			// The passthrough keeps AsciiDoc markup in code literal
+			sb.WriteString("`+" + in.text + "+`")
+		case inlineLink:
+			if _, ok := safeURL(in.url); !ok {
+				sb.WriteString(r.inlines(in.children))
+				continue
+			}
+			sb.WriteString(asciiDocLink(in.url, r.inlines(in.children)))
+		case inlineCitation:
+			if r.cited[in.number] {
+				fmt.Fprintf(&sb, "<<source-%d>>", in.number)
+			} else {
+				sb.WriteString(asciiDocText(fmt.Sprintf("[%d]", in.number)))
+			}
+		case inlineBreak:
+			sb.WriteString(" +\n")
+		}
+	}
+	return sb.String()
+}
+
+// asciiDocEscaper replaces the characters of AsciiDoc inline markup with
+// character references, which are rendered as the characters without being
+// read as markup, so text taken from the web reads literally.
+var asciiDocEscaper = strings.NewReplacer(
+	"\\", "&#92;",
+	"*", "&#42;",
+	"_", "&#95;",
+	"`", "&#96;",
+	"#", "&#35;",
+	"^", "&#94;",
+	"~", "&#126;",
+	"+", "&#43;",
+	"[", "&#91;",
+	"]", "&#93;",
+	"{", "&#123;",
+	"<", "&#60;",
+)
+
+// asciiDocText escapes plain text.
+func asciiDocText(text string) string {
+	return asciiDocEscaper.Replace(text)
+}
+
+// asciiDocLink renders a link, escaping the brackets that would end its text.
+func asciiDocLink(url, text string) string {
+	text = strings.ReplaceAll(text, "]", "\\]")
+	if text == url {
+		text = ""
+	}
+	return "link:" + url + "[" + text + "]"
+}
@@ -0,0 +1,162 @@
+// Package export writes research reports as Markdown, standalone HTML, JSON,
+// Org or AsciiDoc documents.
+package export
+
+import (
+	"deep-research/internal/usage"
+	"deep-research/internal/workflows"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+	FormatOrg      = "org"
+	FormatAsciiDoc = "asciidoc"
+)
+
+// Formats lists the export formats, for usage messages.
+var Formats = []string{FormatMarkdown, FormatHTML, FormatJSON, FormatOrg, FormatAsciiDoc}
+
+// formatAliases maps alternative names and file extensions to formats.
+var formatAliases = map[string]string{
+	"md":       FormatMarkdown,
+	"markdown": FormatMarkdown,
+	"html":     FormatHTML,
+	"htm":      FormatHTML,
+	"json":     FormatJSON,
+	"org":      FormatOrg,
+	"asciidoc": FormatAsciiDoc,
+	"adoc":     FormatAsciiDoc,
+}
+
+// ParseFormat returns the format called name, accepting common aliases such
+// as markdown or adoc.
+func ParseFormat(name string) (string, error) {
+	if format, ok := formatAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected one of %s", name, strings.Join(Formats, ", "))
+}
+
+// FormatForPath guesses the format from the extension of path, falling back
+// to Markdown.
+func FormatForPath(path string) string {
+	if format, ok := formatAliases[strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))]; ok {
+		return format
+	}
+	return FormatMarkdown
+}
+
+// Document is a finished report with what is known about how it was
+// produced.
+type Document struct {
+	Report        workflows.ResearchReport
+	ResearchBrief string
+	Metadata      Metadata
+}
+
+// Metadata describes the session that produced a report.
+type Metadata struct {
+	SessionID string `json:"session_id"`
+	// Query is the first message of the user
+	Query         string `json:"query,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+	// GeneratedAt is when the report was written, unknown for sessions
+	// checkpointed before it was recorded
+	GeneratedAt time.Time `json:"generated_at,omitzero"`
+	// Usage is the token usage and estimated cost of the session
+	Usage *usage.Summary `json:"usage,omitempty"`
+}
+
+// Write renders doc in format to w.
+func Write(w io.Writer, format string, doc Document) error {
+	switch format {
+	case FormatMarkdown:
+		doc.Report.Body = dropUnsafeLinks(doc.Report.Body)
+		report := doc.Report.String()
+		if !strings.HasSuffix(report, "\n") {
+			report += "\n"
+		}
+		_, err := io.WriteString(w, report)
+		return err
+	case FormatHTML:
+		return writeHTML(w, doc)
+	case FormatJSON:
+		return writeJSON(w, doc)
+	case FormatOrg:
+		return writeOrg(w, doc)
+	case FormatAsciiDoc:
+		return writeAsciiDoc(w, doc)
+	default:
+		return fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// jsonDocument is the layout of JSON exports.
+type jsonDocument struct {
+	Title         string                  `json:"title"`
+	Report        string                  `json:"report"`
+	Sources       []workflows.CitedSource `json:"sources"`
+	ResearchBrief string                  `json:"research_brief"`
+	Metadata      Metadata                `json:"metadata"`
+}
+
+func writeJSON(w io.Writer, doc Document) error {
+	sources := make([]workflows.CitedSource, len(doc.Report.Sources))
+	for i, cited := range doc.Report.Sources {
+		// The text of a source is only an extract of the page
+		cited.Source.Text = ""
+		sources[i] = cited
+	}
+	title, _ := splitTitle(doc)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(jsonDocument{
+		Title:         title,
+		Report:        dropUnsafeLinks(doc.Report.Body),
+		Sources:       sources,
+		ResearchBrief: doc.ResearchBrief,
+		Metadata:      doc.Metadata,
+	})
+}
+
+// splitTitle returns the title of the document and the blocks of its body.
+// A report opening with a level one heading is titled by it, and the heading
+// is removed from the body; otherwise the query is the title.
+func splitTitle(doc Document) (string, []block) {
+	blocks := parseMarkdown(doc.Report.Body)
+	if len(blocks) > 0 && blocks[0].kind == blockHeading && blocks[0].level == 1 {
+		return plainText(parseInlines(blocks[0].text)), blocks[1:]
+	}
+	title := strings.Join(strings.Fields(doc.Metadata.Query), " ")
+	if title == "" {
+		title = "Research Report"
+	}
+	return title, blocks
+}
+
+// citedNumbers returns the numbers of the sources cited by the report.
+func citedNumbers(doc Document) map[int]bool {
+	cited := make(map[int]bool, len(doc.Report.Sources))
+	for _, source := range doc.Report.Sources {
+		cited[source.Number] = true
+	}
+	return cited
+}
+
+// sourceTitle is the title of a cited source, or its URL when it has none.
+func sourceTitle(cited workflows.CitedSource) string {
+	if cited.Source.Title != "" {
+		return cited.Source.Title
+	}
+	return cited.Source.URL
+}
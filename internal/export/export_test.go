@@ -0,0 +1,228 @@
+package export
+
+import (
+	"deep-research/internal/tools"
+	"deep-research/internal/workflows"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDocument is a report citing one source, with body.
+func testDocument(body string) Document {
+	return Document{
+		Report: workflows.ResearchReport{
+			Body: body,
+			Sources: []workflows.CitedSource{{Number: 1, Source: tools.Source{
+				Title:         "Solar [records]",
+				URL:           "https://example.com/records",
+				PublishedDate: "2024-05-01",
+			}}},
+		},
+		Metadata: Metadata{
+			SessionID:   "abc123",
+			Query:       "How efficient are solar panels?",
+			GeneratedAt: time.Date(2024, 6, 2, 10, 0, 0, 0, time.UTC),
+		},
+	}
+}
+
+func render(t *testing.T, format string, doc Document) string {
+	t.Helper()
+	var sb strings.Builder
+	if err := Write(&sb, format, doc); err != nil {
+		t.Fatalf("Write(%s) error = %v", format, err)
+	}
+	return sb.String()
+}
+
+func TestWrite(t *testing.T) {
+	const citation = `<sup class="citation"><a href="#source-1" title="Solar [records]">[1]</a></sup>`
+
+	tests := []struct {
+		name string
+		body string
+		// want and absent hold, by format, text the export must and must not
+		// contain
+		want   map[string][]string
+		absent map[string][]string
+	}{
+		{
+			name: "nested list",
+			body: "- one\n  - nested [1]\n- two\n",
+			want: map[string][]string{
+				FormatMarkdown: {"- one\n  - nested [1]\n- two\n"},
+				FormatHTML:     {"<ul>\n<li>one\n<ul>\n<li>nested " + citation + "</li>\n</ul>\n</li>\n<li>two</li>\n</ul>\n"},
+				FormatOrg:      {"- one\n  - nested [fn:1]\n- two\n"},
+				FormatAsciiDoc: {"* one\n** nested <<source-1>>\n* two\n"},
+			},
+		},
+		{
+			name: "loose list",
+			body: "3. first\n\n4. second\n\n   continued\n",
+			want: map[string][]string{
+				FormatMarkdown: {"3. first\n\n4. second\n\n   continued\n"},
+				FormatHTML:     {"<ol start=\"3\">\n<li>first</li>\n<li>second\n<p>continued</p>\n</li>\n</ol>\n"},
+				FormatOrg:      {"3. first\n4. second\n\n   continued\n"},
+				FormatAsciiDoc: {"[start=3]\n. first\n. second\n+\ncontinued\n"},
+			},
+		},
+		{
+			name: "fenced code keeps markers and emphasis",
+			body: "```go\nx := a[1] * b\n// [x](javascript:y)\n```\n",
+			want: map[string][]string{
+				FormatMarkdown: {"```go\nx := a[1] * b\n// [x](javascript:y)\n```\n"},
+				FormatHTML:     {"<pre><code class=\"language-go\">x := a[1] * b\n// [x](javascript:y)\n</code></pre>\n"},
+				FormatJSON:     {`x := a[1] * b\n// [x](javascript:y)`},
+				FormatOrg:      {"#+BEGIN_SRC go\nx := a[1] * b\n// [x](javascript:y)\n#+END_SRC\n"},
+				FormatAsciiDoc: {"[source,go]\n----\nx := a[1] * b\n// [x](javascript:y)\n----\n"},
+			},
+			absent: map[string][]string{
+				FormatHTML:     {"<sup", "<em>"},
+				FormatOrg:      {"a[fn:1]"},
+				FormatAsciiDoc: {"a<<source-1>>"},
+			},
+		},
+		{
+			name: "table",
+			body: "| Source | Note |\n|---|---|\n| [1] | `*x*` |\n",
+			want: map[string][]string{
+				FormatMarkdown: {"| Source | Note |\n|---|---|\n| [1] | `*x*` |\n"},
+				FormatHTML: {"<thead>\n<tr><th>Source</th><th>Note</th></tr>\n</thead>\n" +
+					"<tbody>\n<tr><td>" + citation + "</td><td><code>*x*</code></td></tr>\n</tbody>\n"},
+				FormatOrg:      {"| Source | Note |\n|---+---|\n| [fn:1] | ~*x*~ |\n"},
+				FormatAsciiDoc: {"|===\n|Source |Note\n\n|<<source-1>> |`+*x*+`\n|===\n"},
+			},
+		},
+		{
+			name: "numeric link text is not a citation",
+			body: "See [1](https://example.com/one).\n",
+			want: map[string][]string{
+				FormatMarkdown: {"See [1](https://example.com/one)."},
+				FormatHTML:     {`<p>See <a href="https://example.com/one">1</a>.</p>`},
+				FormatOrg:      {"See [[https://example.com/one][1]]."},
+				FormatAsciiDoc: {"See link:https://example.com/one[1]."},
+			},
+			absent: map[string][]string{
+				FormatHTML:     {"<sup"},
+				FormatOrg:      {"See [fn:1]"},
+				FormatAsciiDoc: {"<<source-1>>"},
+			},
+		},
+		{
+			name: "unsafe links are dropped",
+			body: "Click [here](javascript:alert(1)) or [mail](mailto:a@example.com).\n",
+			want: map[string][]string{
+				FormatMarkdown: {"Click here or [mail](mailto:a@example.com)."},
+				FormatHTML:     {`<p>Click here or <a href="mailto:a@example.com">mail</a>.</p>`},
+				FormatJSON:     {`Click here or [mail](mailto:a@example.com).`},
+				FormatOrg:      {"Click here or [[mailto:a@example.com][mail]]."},
+				FormatAsciiDoc: {"Click here or link:mailto:a@example.com[mail]."},
+			},
+			absent: map[string][]string{
+				FormatMarkdown: {"javascript:"},
+				FormatHTML:     {"javascript:"},
+				FormatJSON:     {"javascript:"},
+				FormatOrg:      {"javascript:"},
+				FormatAsciiDoc: {"javascript:"},
+			},
+		},
+		{
+			name: "citations to unknown sources stay plain",
+			body: "Known [1], unknown [7].\n",
+			want: map[string][]string{
+				FormatMarkdown: {"Known [1], unknown [7]."},
+				FormatHTML:     {"<p>Known " + citation + ", unknown [7].</p>"},
+				FormatOrg:      {"Known [fn:1], unknown [7]."},
+				FormatAsciiDoc: {"Known <<source-1>>, unknown &#91;7&#93;."},
+			},
+			absent: map[string][]string{
+				FormatHTML:     {"#source-7"},
+				FormatOrg:      {"[fn:7]"},
+				FormatAsciiDoc: {"source-7"},
+			},
+		},
+		{
+			name: "markup characters in text",
+			body: "# Solar_power & <b>\n\nUse snake_case, a+b, {attr}, <<xref>>, #tag and [[anchor]].\n",
+			want: map[string][]string{
+				FormatHTML: {
+					"<title>Solar_power &amp; &lt;b&gt;</title>",
+					"<p>Use snake_case, a+b, {attr}, &lt;&lt;xref&gt;&gt;, #tag and [[anchor]].</p>",
+				},
+				FormatJSON: {`"title": "Solar_power & <b>"`},
+				FormatAsciiDoc: {
+					"= Solar&#95;power & &#60;b>\n",
+					"Use snake&#95;case, a&#43;b, &#123;attr}, &#60;&#60;xref>>, &#35;tag and &#91;&#91;anchor&#93;&#93;.",
+					"* [[[source-1,1]]] link:https://example.com/records[Solar &#91;records&#93;], published 2024-05-01\n",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, format := range Formats {
+			t.Run(tt.name+"/"+format, func(t *testing.T) {
+				out := render(t, format, testDocument(tt.body))
+				for _, want := range tt.want[format] {
+					if !strings.Contains(out, want) {
+						t.Errorf("export does not contain %q:\n%s", want, out)
+					}
+				}
+				for _, absent := range tt.absent[format] {
+					if strings.Contains(out, absent) {
+						t.Errorf("export contains %q:\n%s", absent, out)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestWriteSources(t *testing.T) {
+	doc := testDocument("# Solar\n\nFound [1].\n")
+
+	tests := map[string][]string{
+		FormatMarkdown: {"# Solar\n\nFound [1].\n\n## Sources\n\n1. [Solar [records]](https://example.com/records), published 2024-05-01\n"},
+		FormatHTML: {
+			"<title>Solar</title>",
+			`<li id="source-1" value="1"><a href="https://example.com/records">Solar [records]</a> <span class="published">published 2024-05-01</span></li>`,
+		},
+		FormatJSON: {`"title": "Solar"`, `"url": "https://example.com/records"`, `"session_id": "abc123"`},
+		FormatOrg: {
+			"#+TITLE: Solar\n",
+			"* Sources\n\n[fn:1] [[https://example.com/records][Solar (records)]], published 2024-05-01\n",
+		},
+		FormatAsciiDoc: {"= Solar\n", "[bibliography]\n== Sources\n\n"},
+	}
+	for format, wants := range tests {
+		out := render(t, format, doc)
+		for _, want := range wants {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s export does not contain %q:\n%s", format, want, out)
+			}
+		}
+	}
+}
+
+func TestWriteGeneratedAt(t *testing.T) {
+	dates := map[string]string{
+		FormatHTML:     "Generated 2 June 2024 &middot; Session abc123",
+		FormatJSON:     `"generated_at": "2024-06-02T10:00:00Z"`,
+		FormatOrg:      "#+DATE: 2024-06-02\n",
+		FormatAsciiDoc: ":revdate: 2024-06-02\n",
+	}
+	for format, date := range dates {
+		doc := testDocument("Found [1].")
+		if out := render(t, format, doc); !strings.Contains(out, date) {
+			t.Errorf("%s export does not contain %q:\n%s", format, date, out)
+		}
+
+		// Sessions checkpointed before completion times were recorded have
+		// no date
+		doc.Metadata.GeneratedAt = time.Time{}
+		if out := render(t, format, doc); strings.Contains(out, "2024-06-02") || strings.Contains(out, "0001") || strings.Contains(out, "Generated") || strings.Contains(out, "generated_at") {
+			t.Errorf("%s export without a completion time has a date:\n%s", format, out)
+		}
+	}
+}
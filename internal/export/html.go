@@ -0,0 +1,210 @@
+package export
+
+import (
+	"deep-research/internal/workflows"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// htmlStyle styles HTML exports. Citations are superscript links to their
+// entry in the Sources list, which is highlighted when followed.
+const htmlStyle = `
+body { max-width: 46rem; margin: 2rem auto; padding: 0 1rem; font: 16px/1.6 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 1.5rem; }
+header .meta { color: #656d76; font-size: 0.875rem; }
+h1, h2, h3, h4 { line-height: 1.25; }
+a { color: #0969da; }
+pre, code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 0.875em; background: #f6f8fa; border-radius: 4px; }
+code { padding: 0.1em 0.3em; }
+pre { padding: 0.75rem; overflow-x: auto; }
+pre code { padding: 0; background: none; }
+blockquote { margin: 0; padding: 0 1rem; color: #656d76; border-left: 0.25em solid #d0d7de; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 0.3rem 0.7rem; }
+sup.citation a { text-decoration: none; font-size: 0.75em; padding: 0 0.1em; }
+sup.citation a:hover { text-decoration: underline; }
+ol.sources { font-size: 0.9rem; }
+ol.sources li:target { background: #fff8c5; }
+ol.sources .published { color: #656d76; }
+`
+
+func writeHTML(w io.Writer, doc Document) error {
+	title, blocks := splitTitle(doc)
+	r := htmlRenderer{sources: make(map[int]workflows.CitedSource, len(doc.Report.Sources))}
+	for _, cited := range doc.Report.Sources {
+		r.sources[cited.Number] = cited
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	sb.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&sb, "<title>%s</title>\n", html.EscapeString(title))
+	if doc.Metadata.PromptVersion != "" {
+		fmt.Fprintf(&sb, "<meta name=\"prompt-version\" content=\"%s\">\n", html.EscapeString(doc.Metadata.PromptVersion))
+	}
+	fmt.Fprintf(&sb, "<style>%s</style>\n</head>\n<body>\n<header>\n", htmlStyle)
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n", html.EscapeString(title))
+	var meta []string
+	if !doc.Metadata.GeneratedAt.IsZero() {
+		meta = append(meta, "Generated "+doc.Metadata.GeneratedAt.Format("2 January 2006"))
+	}
+	if doc.Metadata.SessionID != "" {
+		meta = append(meta, "Session "+html.EscapeString(doc.Metadata.SessionID))
+	}
+	if len(meta) > 0 {
+		fmt.Fprintf(&sb, "<p class=\"meta\">%s</p>\n", strings.Join(meta, " &middot; "))
+	}
+	sb.WriteString("</header>\n<main>\n")
+	r.blocks(&sb, blocks)
+	sb.WriteString("</main>\n")
+
+	if len(doc.Report.Sources) > 0 {
+		sb.WriteString("<section>\n<h2>Sources</h2>\n<ol class=\"sources\">\n")
+		for _, cited := range doc.Report.Sources {
+			fmt.Fprintf(&sb, "<li id=\"source-%d\" value=\"%d\">", cited.Number, cited.Number)
+			if href, ok := safeURL(cited.Source.URL); ok {
+				fmt.Fprintf(&sb, "<a href=\"%s\">%s</a>", html.EscapeString(href), html.EscapeString(sourceTitle(cited)))
+			} else {
+				sb.WriteString(html.EscapeString(sourceTitle(cited)))
+			}
+			if cited.Source.PublishedDate != "" {
+				fmt.Fprintf(&sb, " <span class=\"published\">published %s</span>", html.EscapeString(cited.Source.PublishedDate))
+			}
+			sb.WriteString("</li>\n")
+		}
+		sb.WriteString("</ol>\n</section>\n")
+	}
+	sb.WriteString("</body>\n</html>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type htmlRenderer struct {
+	sources map[int]workflows.CitedSource
+}
+
+func (r htmlRenderer) blocks(sb *strings.Builder, blocks []block) {
+	for _, b := range blocks {
+		switch b.kind {
+		case blockHeading:
+			fmt.Fprintf(sb, "<h%d>", b.level)
+			r.inlines(sb, parseInlines(b.text))
+			fmt.Fprintf(sb, "</h%d>\n", b.level)
+		case blockParagraph:
+			sb.WriteString("<p>")
+			r.inlines(sb, parseInlines(b.text))
+			sb.WriteString("</p>\n")
+		case blockList:
+			tag := "ul"
+			if b.ordered {
+				tag = "ol"
+			}
+			if b.ordered && b.start != 1 {
+				fmt.Fprintf(sb, "<ol start=\"%d\">\n", b.start)
+			} else {
+				fmt.Fprintf(sb, "<%s>\n", tag)
+			}
+			for _, item := range b.items {
+				sb.WriteString("<li>")
+				// Tight items are rendered without a paragraph
+				if len(item) > 0 && item[0].kind == blockParagraph {
+					r.inlines(sb, parseInlines(item[0].text))
+					item = item[1:]
+					if len(item) > 0 {
+						sb.WriteString("\n")
+					}
+				}
+				r.blocks(sb, item)
+				sb.WriteString("</li>\n")
+			}
+			fmt.Fprintf(sb, "</%s>\n", tag)
+		case blockQuote:
+			sb.WriteString("<blockquote>\n")
+			r.blocks(sb, b.children)
+			sb.WriteString("</blockquote>\n")
+		case blockCode:
+			sb.WriteString("<pre><code")
+			if b.lang != "" {
+				fmt.Fprintf(sb, " class=\"language-%s\"", html.EscapeString(b.lang))
+			}
+			fmt.Fprintf(sb, ">%s\n</code></pre>\n", html.EscapeString(b.text))
+		case blockTable:
+			sb.WriteString("<table>\n<thead>\n<tr>")
+			for _, cell := range b.header {
+				sb.WriteString("<th>")
+				r.inlines(sb, parseInlines(cell))
+				sb.WriteString("</th>")
+			}
+			sb.WriteString("</tr>\n</thead>\n<tbody>\n")
+			for _, row := range b.rows {
+				sb.WriteString("<tr>")
+				for _, cell := range row {
+					sb.WriteString("<td>")
+					r.inlines(sb, parseInlines(cell))
+					sb.WriteString("</td>")
+				}
+				sb.WriteString("</tr>\n")
+			}
+			sb.WriteString("</tbody>\n</table>\n")
+		case blockRule:
+			sb.WriteString("<hr>\n")
+		}
+	}
+}
+
+func (r htmlRenderer) inlines(sb *strings.Builder, inlines []inline) {
+	for _, in := range inlines {
+		switch in.kind {
+		case inlineText:
+			sb.WriteString(html.EscapeString(in.text))
+		case inlineStrong:
+			sb.WriteString("<strong>")
+			r.inlines(sb, in.children)
+			sb.WriteString("</strong>")
+		case inlineEmphasis:
+			sb.WriteString("<em>")
+			r.inlines(sb, in.children)
+			sb.WriteString("</em>")
+		case inlineCode:
+			fmt.Fprintf(sb, "<code>%s</code>", html.EscapeString(in.text))
+		case inlineLink:
+			href, ok := safeURL(in.url)
+			if !ok {
+				r.inlines(sb, in.children)
+				continue
+			}
+			fmt.Fprintf(sb, "<a href=\"%s\">", html.EscapeString(href))
+			r.inlines(sb, in.children)
+			sb.WriteString("</a>")
+		case inlineCitation:
+			cited, ok := r.sources[in.number]
+			if !ok {
+				fmt.Fprintf(sb, "[%d]", in.number)
+				continue
+			}
+			fmt.Fprintf(sb, "<sup class=\"citation\"><a href=\"#source-%d\" title=\"%s\">[%d]</a></sup>",
+				in.number, html.EscapeString(sourceTitle(cited)), in.number)
+		case inlineBreak:
+			sb.WriteString("<br>\n")
+		}
+	}
+}
+
+// safeURL reports whether a link from the report may be followed: reports are
+// written from web content, so only web, mail and relative links are kept in
+// every format.
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return raw, true
+	}
+	return "", false
+}
@@ -0,0 +1,452 @@
+package export
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The reports written by the report workflow use a small part of Markdown:
+// headings, paragraphs, lists, block quotes, fenced code, tables, rules and
+// emphasis, code, links and [n] citation markers inline. It is parsed into
+// blocks and inlines so every format renders the same structure.
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockList
+	blockQuote
+	blockCode
+	blockTable
+	blockRule
+)
+
+type block struct {
+	kind blockKind
+	// level is the level of a heading
+	level int
+	// text is the inline text of a paragraph or heading, or the content of
+	// a code block
+	text string
+	// lang is the language of a code block
+	lang string
+	// ordered and start describe a list
+	ordered bool
+	start   int
+	items   [][]block
+	// children are the blocks of a block quote
+	children []block
+	// header and rows are the cells of a table
+	header []string
+	rows   [][]string
+}
+
+var (
+	headingPattern      = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	listItemPattern     = regexp.MustCompile(`^(\s*)([-*+]|(\d{1,9})[.)])\s+(.*)$`)
+	rulePattern         = regexp.MustCompile(`^\s{0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	tableDividerPattern = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// parseMarkdown splits Markdown text into blocks.
+func parseMarkdown(text string) []block {
+	return parseBlocks(strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"))
+}
+
+func parseBlocks(lines []string) []block {
+	var blocks []block
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			i++
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence := trimmed[:3]
+			lang := strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1]))
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			i++
+			blocks = append(blocks, block{kind: blockCode, lang: lang, text: strings.Join(code, "\n")})
+		case headingPattern.MatchString(trimmed):
+			m := headingPattern.FindStringSubmatch(trimmed)
+			blocks = append(blocks, block{kind: blockHeading, level: len(m[1]), text: m[2]})
+			i++
+		case rulePattern.MatchString(line):
+			blocks = append(blocks, block{kind: blockRule})
+			i++
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quoted = append(quoted, strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">"), " "))
+			}
+			blocks = append(blocks, block{kind: blockQuote, children: parseBlocks(quoted)})
+		case listItemPattern.MatchString(line):
+			var list block
+			list, i = parseList(lines, i)
+			blocks = append(blocks, list)
+		case strings.Contains(line, "|") && i+1 < len(lines) && tableDividerPattern.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-"):
+			table := block{kind: blockTable, header: splitTableRow(line)}
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+				table.rows = append(table.rows, splitTableRow(lines[i]))
+			}
+			blocks = append(blocks, table)
+		default:
+			// Trailing spaces are kept, as two of them mark a line break
+			paragraph := []string{strings.TrimLeft(line, " \t")}
+			for i++; i < len(lines) && !startsBlock(lines, i); i++ {
+				paragraph = append(paragraph, strings.TrimLeft(lines[i], " \t"))
+			}
+			blocks = append(blocks, block{kind: blockParagraph, text: strings.TrimRight(strings.Join(paragraph, "\n"), " \t")})
+		}
+	}
+	return blocks
+}
+
+// startsBlock reports whether lines[i] ends a paragraph.
+func startsBlock(lines []string, i int) bool {
+	trimmed := strings.TrimSpace(lines[i])
+	return trimmed == "" ||
+		strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") ||
+		strings.HasPrefix(trimmed, ">") ||
+		headingPattern.MatchString(trimmed) ||
+		rulePattern.MatchString(lines[i]) ||
+		listItemPattern.MatchString(lines[i])
+}
+
+// parseList parses the list starting at lines[i]. Lines indented past the
+// marker of an item, including nested lists, belong to that item.
+func parseList(lines []string, i int) (block, int) {
+	first := listItemPattern.FindStringSubmatch(lines[i])
+	indent := len(first[1])
+	list := block{kind: blockList, ordered: first[3] != "", start: 1}
+	if list.ordered {
+		list.start, _ = strconv.Atoi(first[3])
+	}
+
+	for i < len(lines) {
+		m := listItemPattern.FindStringSubmatch(lines[i])
+		if m == nil || len(m[1]) != indent || (m[3] != "") != list.ordered {
+			break
+		}
+		item := []string{m[4]}
+		contentIndent := len(m[1]) + len(m[2]) + 1
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				// A blank line continues the item only if it is followed by
+				// an indented line
+				if i+1 < len(lines) && leadingSpaces(lines[i+1]) > indent {
+					item = append(item, "")
+					continue
+				}
+				break
+			}
+			if leadingSpaces(line) <= indent && (listItemPattern.MatchString(line) || startsBlock(lines, i)) {
+				break
+			}
+			item = append(item, dedent(line, contentIndent))
+		}
+		list.items = append(list.items, parseBlocks(item))
+		// Skip the blank lines between items of a loose list
+		j := i
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+			j++
+		}
+		if j < len(lines) && j > i {
+			if m := listItemPattern.FindStringSubmatch(lines[j]); m != nil && len(m[1]) == indent {
+				i = j
+			}
+		}
+	}
+	return list, i
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// dedent removes up to n leading spaces from line.
+func dedent(line string, n int) string {
+	for n > 0 && line != "" && (line[0] == ' ' || line[0] == '\t') {
+		line = line[1:]
+		n--
+	}
+	return line
+}
+
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+type inlineKind int
+
+const (
+	inlineText inlineKind = iota
+	inlineStrong
+	inlineEmphasis
+	inlineCode
+	inlineLink
+	inlineCitation
+	inlineBreak
+)
+
+type inline struct {
+	kind inlineKind
+	// text is the content of text and code inlines
+	text string
+	// url is the target of a link
+	url string
+	// number is the source number of a citation
+	number int
+	// children are the content of strong, emphasis and link inlines
+	children []inline
+}
+
+var (
+	citationPattern = regexp.MustCompile(`^\[(\d+(?:\s*,\s*\d+)*)\]`)
+	linkPattern     = regexp.MustCompile(`^\[((?:[^\[\]]|\[[^\[\]]*\])*)\]\(\s*<?([^\s()<>]*(?:\([^\s()]*\)[^\s()<>]*)*)>?(?:\s+"[^"]*")?\s*\)`)
+	autolinkPattern = regexp.MustCompile(`^<(https?://[^\s<>]+)>`)
+)
+
+// parseInlines splits the text of a paragraph or heading into inlines.
+func parseInlines(text string) []inline {
+	var out []inline
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			out = append(out, inline{kind: inlineText, text: plain.String()})
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text) && strings.ContainsRune("\\`*_[]()#+-.!<>|~", rune(text[i+1])):
+			plain.WriteByte(text[i+1])
+			i += 2
+			continue
+		case c == '\n':
+			hardBreak := strings.HasSuffix(text[:i], "  ")
+			trailing := strings.TrimRight(plain.String(), " ")
+			plain.Reset()
+			plain.WriteString(trailing)
+			flush()
+			if hardBreak {
+				out = append(out, inline{kind: inlineBreak})
+			} else {
+				out = append(out, inline{kind: inlineText, text: " "})
+			}
+			i++
+			continue
+		case c == '`':
+			ticks := len(rest) - len(strings.TrimLeft(rest, "`"))
+			if end := closingTicks(rest[ticks:], ticks); end >= 0 {
+				flush()
+				out = append(out, inline{kind: inlineCode, text: strings.TrimSpace(rest[ticks : ticks+end])})
+				i += 2*ticks + end
+				continue
+			}
+			plain.WriteString(rest[:ticks])
+			i += ticks
+			continue
+		case c == '[':
+			if m := citationPattern.FindStringSubmatch(rest); m != nil && !strings.HasPrefix(rest[len(m[0]):], "(") {
+				flush()
+				for _, part := range strings.Split(m[1], ",") {
+					number, _ := strconv.Atoi(strings.TrimSpace(part))
+					out = append(out, inline{kind: inlineCitation, number: number})
+				}
+				i += len(m[0])
+				continue
+			}
+			if m := linkPattern.FindStringSubmatch(rest); m != nil {
+				flush()
+				out = append(out, inline{kind: inlineLink, url: m[2], children: parseInlines(m[1])})
+				i += len(m[0])
+				continue
+			}
+		case c == '<':
+			if m := autolinkPattern.FindStringSubmatch(rest); m != nil {
+				flush()
+				out = append(out, inline{kind: inlineLink, url: m[1], children: []inline{{kind: inlineText, text: m[1]}}})
+				i += len(m[0])
+				continue
+			}
+		case c == '*' || c == '_':
+			if content, n, ok := emphasis(text, i, 2); ok {
+				flush()
+				out = append(out, inline{kind: inlineStrong, children: parseInlines(content)})
+				i += n
+				continue
+			}
+			if content, n, ok := emphasis(text, i, 1); ok {
+				flush()
+				out = append(out, inline{kind: inlineEmphasis, children: parseInlines(content)})
+				i += n
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(rest)
+		plain.WriteRune(r)
+		i += size
+	}
+	flush()
+	return out
+}
+
+// emphasis matches a run of n '*' or '_' at text[i] with its closing run,
+// returning the content between them and the length of the whole match.
+// Underscores inside words, as in snake_case, are not emphasis.
+func emphasis(text string, i, n int) (string, int, bool) {
+	delim := strings.Repeat(text[i:i+1], n)
+	if !strings.HasPrefix(text[i:], delim) || strings.HasPrefix(text[i+n:], delim[:1]) && n == 1 {
+		return "", 0, false
+	}
+	if delim[0] == '_' && i > 0 && isWordRune(text[:i], true) {
+		return "", 0, false
+	}
+	open := i + n
+	if open >= len(text) || unicode.IsSpace(rune(text[open])) {
+		return "", 0, false
+	}
+	for j := open + 1; j < len(text); j++ {
+		if text[j] != delim[0] {
+			continue
+		}
+		run := 1
+		for j+run < len(text) && text[j+run] == delim[0] {
+			run++
+		}
+		// A run too short, or a pair closing stronger emphasis inside this
+		// one, does not close it
+		if run < n || (n == 1 && run == 2) || unicode.IsSpace(rune(text[j-1])) ||
+			(delim[0] == '_' && j+run < len(text) && isWordRune(text[j+run:], false)) {
+			j += run - 1
+			continue
+		}
+		closing := j + run - n
+		return text[open:closing], closing + n - i, true
+	}
+	return "", 0, false
+}
+
+// closingTicks returns the index in text of the first run of exactly n
+// backticks, which closes a code span opened by n backticks, or -1.
+func closingTicks(text string, n int) int {
+	for j := 0; j < len(text); {
+		if text[j] != '`' {
+			j++
+			continue
+		}
+		run := len(text[j:]) - len(strings.TrimLeft(text[j:], "`"))
+		if run == n {
+			return j
+		}
+		j += run
+	}
+	return -1
+}
+
+// isWordRune reports whether the last (or first) rune of s is a letter or
+// digit.
+func isWordRune(s string, last bool) bool {
+	var r rune
+	if last {
+		r, _ = utf8.DecodeLastRuneInString(s)
+	} else {
+		r, _ = utf8.DecodeRuneInString(s)
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// dropUnsafeLinks replaces the links of Markdown text whose target safeURL
+// rejects by their text, leaving code untouched, so Markdown and JSON exports
+// keep the same links as the other formats.
+func dropUnsafeLinks(text string) string {
+	lines := strings.Split(text, "\n")
+	fence := ""
+	for n, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+
+		var sb strings.Builder
+		for i := 0; i < len(line); {
+			rest := line[i:]
+			switch line[i] {
+			case '\\':
+				if i+1 < len(line) {
+					sb.WriteString(rest[:2])
+					i += 2
+					continue
+				}
+			case '`':
+				ticks := len(rest) - len(strings.TrimLeft(rest, "`"))
+				if end := closingTicks(rest[ticks:], ticks); end >= 0 {
+					sb.WriteString(rest[:2*ticks+end])
+					i += 2*ticks + end
+				} else {
+					sb.WriteString(rest[:ticks])
+					i += ticks
+				}
+				continue
+			case '[':
+				if m := linkPattern.FindStringSubmatch(rest); m != nil {
+					if _, ok := safeURL(m[2]); ok {
+						sb.WriteString(m[0])
+					} else {
+						sb.WriteString(m[1])
+					}
+					i += len(m[0])
+					continue
+				}
+			}
+			sb.WriteByte(line[i])
+			i++
+		}
+		lines[n] = sb.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// plainText returns the text of inlines without formatting, for titles and
+// attributes.
+func plainText(inlines []inline) string {
+	var sb strings.Builder
+	for _, in := range inlines {
+		switch in.kind {
+		case inlineText, inlineCode:
+			sb.WriteString(in.text)
+		case inlineStrong, inlineEmphasis, inlineLink:
+			sb.WriteString(plainText(in.children))
+		case inlineCitation:
+			sb.WriteString("[" + strconv.Itoa(in.number) + "]")
+		case inlineBreak:
+			sb.WriteString(" ")
+		}
+	}
+	return sb.String()
+}
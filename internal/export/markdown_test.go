@@ -0,0 +1,132 @@
+package export
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	paragraph := func(text string) block { return block{kind: blockParagraph, text: text} }
+
+	tests := []struct {
+		name string
+		text string
+		want []block
+	}{
+		{
+			name: "nested list",
+			text: "- one\n  - nested\n  - also nested\n- two",
+			want: []block{{kind: blockList, start: 1, items: [][]block{
+				{paragraph("one"), {kind: blockList, start: 1, items: [][]block{{paragraph("nested")}, {paragraph("also nested")}}}},
+				{paragraph("two")},
+			}}},
+		},
+		{
+			name: "loose ordered list",
+			text: "3. first\n\n4. second\n\n   continued\n\nAfter.",
+			want: []block{
+				{kind: blockList, ordered: true, start: 3, items: [][]block{
+					{paragraph("first")},
+					{paragraph("second"), paragraph("continued")},
+				}},
+				paragraph("After."),
+			},
+		},
+		{
+			name: "fenced code is not parsed",
+			text: "```go\n- not a list [1]\n*x* := a[1]\n```\n~~~\n# not a heading\n~~~",
+			want: []block{
+				{kind: blockCode, lang: "go", text: "- not a list [1]\n*x* := a[1]"},
+				{kind: blockCode, text: "# not a heading"},
+			},
+		},
+		{
+			name: "table",
+			text: "| Year | Efficiency |\n|:---|---:|\n| 2020 | 25% [1] |\n| 2024 | 27% |",
+			want: []block{{
+				kind:   blockTable,
+				header: []string{"Year", "Efficiency"},
+				rows:   [][]string{{"2020", "25% [1]"}, {"2024", "27%"}},
+			}},
+		},
+		{
+			name: "heading, quote and rule",
+			text: "## Findings ##\n> quoted\n> text\n\n---",
+			want: []block{
+				{kind: blockHeading, level: 2, text: "Findings"},
+				{kind: blockQuote, children: []block{paragraph("quoted\ntext")}},
+				{kind: blockRule},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMarkdown(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMarkdown() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInlines(t *testing.T) {
+	text := func(s string) inline { return inline{kind: inlineText, text: s} }
+
+	tests := []struct {
+		name string
+		text string
+		want []inline
+	}{
+		{
+			name: "citations",
+			text: "Found [1] and [2, 3].",
+			want: []inline{
+				text("Found "), {kind: inlineCitation, number: 1}, text(" and "),
+				{kind: inlineCitation, number: 2}, {kind: inlineCitation, number: 3}, text("."),
+			},
+		},
+		{
+			name: "numeric link text is a link",
+			text: "[1](https://example.com)",
+			want: []inline{{kind: inlineLink, url: "https://example.com", children: []inline{text("1")}}},
+		},
+		{
+			name: "code keeps markers and emphasis",
+			text: "`a[1] * b` and *c*",
+			want: []inline{{kind: inlineCode, text: "a[1] * b"}, text(" and "), {kind: inlineEmphasis, children: []inline{text("c")}}},
+		},
+		{
+			name: "underscores inside words",
+			text: "snake_case_name and __strong__",
+			want: []inline{text("snake_case_name and "), {kind: inlineStrong, children: []inline{text("strong")}}},
+		},
+		{
+			name: "escaped brackets",
+			text: `\[1\] is literal`,
+			want: []inline{text("[1] is literal")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseInlines(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseInlines(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropUnsafeLinks(t *testing.T) {
+	tests := map[string]string{
+		"[ok](https://example.com) [bad](javascript:alert(1))":  "[ok](https://example.com) bad",
+		"[mail](mailto:a@example.com) [data](data:text/html,x)": "[mail](mailto:a@example.com) data",
+		"`[code](javascript:x)` [bad](vbscript:x)":              "`[code](javascript:x)` bad",
+		`\[not a link](javascript:x)`:                           `\[not a link](javascript:x)`,
+		"```\n[fenced](javascript:x)\n```\n[bad](javascript:x)": "```\n[fenced](javascript:x)\n```\nbad",
+	}
+	for text, want := range tests {
+		if got := dropUnsafeLinks(text); got != want {
+			t.Errorf("dropUnsafeLinks(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
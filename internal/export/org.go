@@ -0,0 +1,204 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeOrg renders doc as an Org document. Citations become footnotes whose
+// definitions list the sources.
+func writeOrg(w io.Writer, doc Document) error {
+	title, blocks := splitTitle(doc)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "#+TITLE: %s\n", title)
+	if !doc.Metadata.GeneratedAt.IsZero() {
+		fmt.Fprintf(&sb, "#+DATE: %s\n", doc.Metadata.GeneratedAt.Format("2006-01-02"))
+	}
+	if doc.Metadata.SessionID != "" {
+		fmt.Fprintf(&sb, "#+SESSION_ID: %s\n", doc.Metadata.SessionID)
+	}
+	if doc.Metadata.PromptVersion != "" {
+		fmt.Fprintf(&sb, "#+PROMPT_VERSION: %s\n", doc.Metadata.PromptVersion)
+	}
+	sb.WriteString("\n")
+
+	r := orgRenderer{shift: minHeadingLevel(blocks) - 1, cited: citedNumbers(doc)}
+	r.blocks(&sb, blocks)
+
+	if len(doc.Report.Sources) > 0 {
+		sb.WriteString("* Sources\n\n")
+		for _, cited := range doc.Report.Sources {
+			fmt.Fprintf(&sb, "[fn:%d] %s", cited.Number, orgLink(cited.Source.URL, sourceTitle(cited)))
+			if cited.Source.PublishedDate != "" {
+				fmt.Fprintf(&sb, ", published %s", cited.Source.PublishedDate)
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type orgRenderer struct {
+	// shift is subtracted from heading levels so the top level headings of
+	// the report become top level Org headings
+	shift int
+	// cited holds the numbers of the cited sources
+	cited map[int]bool
+}
+
+func (r orgRenderer) blocks(sb *strings.Builder, blocks []block) {
+	for _, b := range blocks {
+		switch b.kind {
+		case blockHeading:
+			fmt.Fprintf(sb, "%s %s\n", strings.Repeat("*", b.level-r.shift), r.inlines(parseInlines(b.text)))
+		case blockParagraph:
+			sb.WriteString(r.inlines(parseInlines(b.text)) + "\n")
+		case blockList:
+			for i, item := range b.items {
+				marker := "- "
+				if b.ordered {
+					marker = fmt.Sprintf("%d. ", b.start+i)
+				}
+				sb.WriteString(indentItem(marker, r.item(item)) + "\n")
+			}
+		case blockQuote:
+			sb.WriteString("#+BEGIN_QUOTE\n")
+			var content strings.Builder
+			r.blocks(&content, b.children)
+			sb.WriteString(strings.TrimRight(content.String(), "\n") + "\n")
+			sb.WriteString("#+END_QUOTE\n")
+		case blockCode:
+			if b.lang != "" {
+				fmt.Fprintf(sb, "#+BEGIN_SRC %s\n", b.lang)
+			} else {
+				sb.WriteString("#+BEGIN_EXAMPLE\n")
+			}
+			for _, line := range strings.Split(b.text, "\n") {
+				// Protect lines that Org would read as headings or keywords
+				if strings.HasPrefix(line, "*") || strings.HasPrefix(line, "#+") {
+					line = "," + line
+				}
+				sb.WriteString(line + "\n")
+			}
+			if b.lang != "" {
+				sb.WriteString("#+END_SRC\n")
+			} else {
+				sb.WriteString("#+END_EXAMPLE\n")
+			}
+		case blockTable:
+			sb.WriteString(r.tableRow(b.header))
+			separators := make([]string, len(b.header))
+			for i := range separators {
+				separators[i] = "---"
+			}
+			sb.WriteString("|" + strings.Join(separators, "+") + "|\n")
+			for _, row := range b.rows {
+				sb.WriteString(r.tableRow(row))
+			}
+		case blockRule:
+			sb.WriteString("-----\n")
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// item renders the blocks of a list item. Nested lists follow the text of
+// the item without a blank line, so the list stays tight.
+func (r orgRenderer) item(blocks []block) string {
+	var sb strings.Builder
+	for i, b := range blocks {
+		var content strings.Builder
+		r.blocks(&content, []block{b})
+		if i > 0 {
+			sb.WriteString("\n")
+			if b.kind != blockList {
+				sb.WriteString("\n")
+			}
+		}
+		sb.WriteString(strings.TrimRight(content.String(), "\n"))
+	}
+	return sb.String()
+}
+
+func (r orgRenderer) tableRow(cells []string) string {
+	rendered := make([]string, len(cells))
+	for i, cell := range cells {
+		rendered[i] = strings.ReplaceAll(r.inlines(parseInlines(cell)), "|", "\\vert{}")
+	}
+	return "| " + strings.Join(rendered, " | ") + " |\n"
+}
+
+func (r orgRenderer) inlines(inlines []inline) string {
+	var sb strings.Builder
+	for _, in := range inlines {
+		switch in.kind {
+		case inlineText:
+			sb.WriteString(in.text)
+		case inlineStrong:
+			sb.WriteString("*" + r.inlines(in.children) + "*")
+		case inlineEmphasis:
+			sb.WriteString("/" + r.inlines(in.children) + "/")
+		case inlineCode:
+			sb.WriteString("~" + in.text + "~")
+		case inlineLink:
+			if _, ok := safeURL(in.url); !ok {
+				sb.WriteString(r.inlines(in.children))
+				continue
+			}
+			sb.WriteString(orgLink(in.url, plainText(in.children)))
+		case inlineCitation:
+			if r.cited[in.number] {
+				fmt.Fprintf(&sb, "[fn:%d]", in.number)
+			} else {
+				fmt.Fprintf(&sb, "[%d]", in.number)
+			}
+		case inlineBreak:
+			sb.WriteString("\\\\\n")
+		}
+	}
+	return sb.String()
+}
+
+// orgLink renders a link. Brackets would end the link early, so they are
+// replaced in its description.
+func orgLink(url, description string) string {
+	description = strings.NewReplacer("[", "(", "]", ")").Replace(description)
+	if description == "" || description == url {
+		return "[[" + url + "]]"
+	}
+	return "[[" + url + "][" + description + "]]"
+}
+
+// indentItem prefixes the first line of a list item with marker and indents
+// the following lines to line up with its content.
+func indentItem(marker, content string) string {
+	lines := strings.Split(content, "\n")
+	padding := strings.Repeat(" ", len(marker))
+	for i := range lines {
+		switch {
+		case i == 0:
+			lines[i] = marker + lines[i]
+		case lines[i] != "":
+			lines[i] = padding + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// minHeadingLevel returns the level of the top level headings in blocks.
+func minHeadingLevel(blocks []block) int {
+	level := 0
+	for _, b := range blocks {
+		if b.kind == blockHeading && (level == 0 || b.level < level) {
+			level = b.level
+		}
+	}
+	if level == 0 {
+		return 1
+	}
+	return level
+}
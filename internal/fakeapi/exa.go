@@ -0,0 +1,151 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// SearchResult is a scripted Exa search result.
+type SearchResult struct {
+	Title         string `json:"title"`
+	URL           string `json:"url"`
+	PublishedDate string `json:"publishedDate,omitempty"`
+	Author        string `json:"author,omitempty"`
+	Text          string `json:"text"`
+}
+
+// QueryMatcher selects the search queries scripted results answer.
+type QueryMatcher func(query string) bool
+
+// Exa is a fake Exa search server. Queries are answered by the first rule
+// whose matcher accepts them, and get no results otherwise. It also serves
+// pages registered with Page, so fetched URLs stay local.
+type Exa struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	rules     []searchRule
+	queries   []string
+	unmatched []string
+	pages     map[string]page
+}
+
+type searchRule struct {
+	match   QueryMatcher
+	results []SearchResult
+}
+
+type page struct {
+	contentType string
+	body        string
+}
+
+// NewExa starts a fake Exa search server. Close it when done.
+func NewExa() *Exa {
+	f := &Exa{pages: make(map[string]page)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /search", f.handleSearch)
+	mux.HandleFunc("GET /pages/", f.handlePage)
+	f.server = httptest.NewServer(mux)
+	return f
+}
+
+// URL returns the search endpoint, for EXA_ENDPOINT.
+func (f *Exa) URL() string {
+	return f.server.URL + "/search"
+}
+
+func (f *Exa) Close() {
+	f.server.Close()
+}
+
+// On scripts the results of queries accepted by match.
+func (f *Exa) On(match QueryMatcher, results ...SearchResult) *Exa {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, searchRule{match: match, results: results})
+	return f
+}
+
+// Page serves body under name and returns its URL, for search results or
+// fetch_url calls that must not leave the machine.
+func (f *Exa) Page(name, contentType, body string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = strings.TrimPrefix(name, "/")
+	f.pages[name] = page{contentType: contentType, body: body}
+	return f.server.URL + "/pages/" + name
+}
+
+// Queries returns the search queries received so far.
+func (f *Exa) Queries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.queries...)
+}
+
+// Unmatched returns the queries that no rule accepted, which got no results.
+func (f *Exa) Unmatched() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.unmatched...)
+}
+
+func (f *Exa) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query      string `json:"query"`
+		NumResults int    `json:"numResults"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid request body: %v", err)})
+		return
+	}
+
+	f.mu.Lock()
+	f.queries = append(f.queries, req.Query)
+	results := []SearchResult{}
+	matched := false
+	for _, rule := range f.rules {
+		if rule.match(req.Query) {
+			results, matched = rule.results, true
+			break
+		}
+	}
+	if !matched {
+		f.unmatched = append(f.unmatched, req.Query)
+	}
+	f.mu.Unlock()
+
+	if req.NumResults > 0 && len(results) > req.NumResults {
+		results = results[:req.NumResults]
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+func (f *Exa) handlePage(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	p, ok := f.pages[strings.TrimPrefix(r.URL.Path, "/pages/")]
+	f.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", p.contentType)
+	_, _ = w.Write([]byte(p.body))
+}
+
+// AnyQuery matches every query.
+func AnyQuery() QueryMatcher {
+	return func(string) bool { return true }
+}
+
+// QueryContains matches queries containing text, ignoring case.
+func QueryContains(text string) QueryMatcher {
+	return func(query string) bool {
+		return strings.Contains(strings.ToLower(query), strings.ToLower(text))
+	}
+}
@@ -0,0 +1,309 @@
+// Package fakeapi provides local fakes of the OpenAI chat completions and Exa
+// search APIs that answer with scripted responses, so the whole research
+// pipeline can run in integration tests without network access or API keys.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// schemaRefPattern finds the name of the struct described by the JSON schema
+// that structured output clients put in the system prompt.
+var schemaRefPattern = regexp.MustCompile(`"\$ref":\s*"#/\$defs/([^"]+)"`)
+
+// ChatMatcher selects the chat completion requests a scripted reply answers.
+type ChatMatcher func(req openai.ChatCompletionRequest) bool
+
+// ChatReply is a scripted chat completion.
+type ChatReply struct {
+	// Content is the text of the reply
+	Content string
+	// JSON, when not nil, is encoded as the content of the reply, for
+	// structured output requests
+	JSON any
+	// ToolCalls are the tool calls made by the reply
+	ToolCalls []ToolCall
+	// Status, when non-zero, fails the request with this HTTP status
+	Status int
+}
+
+// ToolCall is a scripted call of the named tool. Arguments are encoded as
+// JSON unless they are already a string.
+type ToolCall struct {
+	Name      string
+	Arguments any
+}
+
+// OpenAI is a fake OpenAI-compatible chat completions server. Requests are
+// answered by the first rule whose matcher accepts them; a rule gives its
+// replies in order and then repeats its last one. Unmatched requests fail
+// with a 400 error, which is not retried.
+type OpenAI struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	rules     []*chatRule
+	requests  []openai.ChatCompletionRequest
+	unmatched []openai.ChatCompletionRequest
+	calls     int
+}
+
+type chatRule struct {
+	match   ChatMatcher
+	replies []ChatReply
+}
+
+// NewOpenAI starts a fake chat completions server. Close it when done.
+func NewOpenAI() *OpenAI {
+	f := &OpenAI{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", f.handleChatCompletion)
+	f.server = httptest.NewServer(mux)
+	return f
+}
+
+// URL returns the base URL of the API, for OPENAI_BASE_URL or
+// OPENAI_COMPATIBLE_BASE_URL.
+func (f *OpenAI) URL() string {
+	return f.server.URL + "/v1"
+}
+
+func (f *OpenAI) Close() {
+	f.server.Close()
+}
+
+// On scripts the replies to requests accepted by match. It panics without
+// replies.
+func (f *OpenAI) On(match ChatMatcher, replies ...ChatReply) *OpenAI {
+	if len(replies) == 0 {
+		panic("fakeapi: On needs at least one reply")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, &chatRule{match: match, replies: replies})
+	return f
+}
+
+// Requests returns the chat completion requests received so far.
+func (f *OpenAI) Requests() []openai.ChatCompletionRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]openai.ChatCompletionRequest(nil), f.requests...)
+}
+
+// Unmatched returns the requests that no rule accepted, which were failed.
+func (f *OpenAI) Unmatched() []openai.ChatCompletionRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]openai.ChatCompletionRequest(nil), f.unmatched...)
+}
+
+func (f *OpenAI) handleChatCompletion(w http.ResponseWriter, r *http.Request) {
+	var req openai.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	reply, id, ok := f.reply(req)
+	if !ok {
+		writeOpenAIError(w, http.StatusBadRequest, "no scripted reply for request: "+describeRequest(req))
+		return
+	}
+	if reply.Status != 0 {
+		writeOpenAIError(w, reply.Status, "scripted failure")
+		return
+	}
+	resp, err := buildResponse(req, reply, id)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// reply records req and takes the next reply of the first matching rule.
+func (f *OpenAI) reply(req openai.ChatCompletionRequest) (ChatReply, int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	f.calls++
+	for _, rule := range f.rules {
+		if !rule.match(req) {
+			continue
+		}
+		reply := rule.replies[0]
+		if len(rule.replies) > 1 {
+			rule.replies = rule.replies[1:]
+		}
+		return reply, f.calls, true
+	}
+	f.unmatched = append(f.unmatched, req)
+	return ChatReply{}, f.calls, false
+}
+
+func buildResponse(req openai.ChatCompletionRequest, reply ChatReply, id int) (openai.ChatCompletionResponse, error) {
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply.Content}
+	if reply.JSON != nil {
+		content, err := json.Marshal(reply.JSON)
+		if err != nil {
+			return openai.ChatCompletionResponse{}, fmt.Errorf("failed to encode scripted JSON: %w", err)
+		}
+		msg.Content = string(content)
+	}
+	for i, call := range reply.ToolCalls {
+		arguments, ok := call.Arguments.(string)
+		if !ok {
+			raw, err := json.Marshal(call.Arguments)
+			if err != nil {
+				return openai.ChatCompletionResponse{}, fmt.Errorf("failed to encode scripted tool arguments: %w", err)
+			}
+			arguments = string(raw)
+		}
+		msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+			ID:       fmt.Sprintf("call_%d_%d", id, i),
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: call.Name, Arguments: arguments},
+		})
+	}
+	finishReason := openai.FinishReasonStop
+	if len(msg.ToolCalls) > 0 {
+		finishReason = openai.FinishReasonToolCalls
+	}
+
+	// Token counts are estimated at four characters per token
+	promptTokens := 0
+	for _, m := range req.Messages {
+		promptTokens += len(m.Content) / 4
+	}
+	completionTokens := len(msg.Content)/4 + 1
+	return openai.ChatCompletionResponse{
+		ID:      fmt.Sprintf("chatcmpl-fake-%d", id),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []openai.ChatCompletionChoice{{Index: 0, Message: msg, FinishReason: finishReason}},
+		Usage: openai.Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}, nil
+}
+
+// describeRequest summarizes req for error messages.
+func describeRequest(req openai.ChatCompletionRequest) string {
+	var tools []string
+	for _, tool := range req.Tools {
+		if tool.Function != nil {
+			tools = append(tools, tool.Function.Name)
+		}
+	}
+	return fmt.Sprintf("model=%s schema=%q tools=%v messages=%d", req.Model, SchemaName(req), tools, len(req.Messages))
+}
+
+// SchemaName returns the name of the structured output requested by req,
+// from its response format or from the JSON schema in its system prompt, or
+// "" for free-form requests.
+func SchemaName(req openai.ChatCompletionRequest) string {
+	if req.ResponseFormat != nil && req.ResponseFormat.JSONSchema != nil {
+		return req.ResponseFormat.JSONSchema.Name
+	}
+	for _, msg := range req.Messages {
+		if msg.Role != openai.ChatMessageRoleSystem {
+			continue
+		}
+		if match := schemaRefPattern.FindStringSubmatch(msg.Content); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// AnyChat matches every request.
+func AnyChat() ChatMatcher {
+	return func(openai.ChatCompletionRequest) bool { return true }
+}
+
+// Schema matches structured output requests for the named schema, the Go
+// type name of the decoded struct, e.g. ClarifyWithUserOutputSchema.
+func Schema(name string) ChatMatcher {
+	return func(req openai.ChatCompletionRequest) bool { return SchemaName(req) == name }
+}
+
+// Offers matches requests that offer the named tool to the model.
+func Offers(tool string) ChatMatcher {
+	return func(req openai.ChatCompletionRequest) bool {
+		for _, t := range req.Tools {
+			if t.Function != nil && t.Function.Name == tool {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AfterTool matches requests whose last message is a result of the named
+// tool, or of any tool when name is "".
+func AfterTool(name string) ChatMatcher {
+	return func(req openai.ChatCompletionRequest) bool {
+		if len(req.Messages) == 0 {
+			return false
+		}
+		last := req.Messages[len(req.Messages)-1]
+		return last.Role == openai.ChatMessageRoleTool && (name == "" || last.Name == name)
+	}
+}
+
+// Contains matches requests with a message containing text.
+func Contains(text string) ChatMatcher {
+	return func(req openai.ChatCompletionRequest) bool {
+		for _, msg := range req.Messages {
+			if strings.Contains(msg.Content, text) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// All matches requests accepted by every matcher.
+func All(matchers ...ChatMatcher) ChatMatcher {
+	return func(req openai.ChatCompletionRequest) bool {
+		for _, match := range matchers {
+			if !match(req) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Not matches requests rejected by match.
+func Not(match ChatMatcher) ChatMatcher {
+	return func(req openai.ChatCompletionRequest) bool { return !match(req) }
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"message": message,
+			"type":    "invalid_request_error",
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/instructor-ai/instructor-go/pkg/instructor"
+	"github.com/liushuangls/go-anthropic/v2"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// anthropicDefaultMaxTokens is used when a stage does not set max tokens, as
+// the Messages API requires an explicit limit.
+const anthropicDefaultMaxTokens = 8192
+
+// anthropicProvider talks to the Anthropic Messages API, translating to and
+// from the OpenAI chat completion types.
+type anthropicProvider struct {
+	client                 *anthropic.Client
+	structuredOutputClient *instructor.InstructorAnthropic
+}
+
+func newAnthropicProvider(apiKey string, httpClient *http.Client) *anthropicProvider {
+	client := anthropic.NewClient(apiKey, anthropic.WithHTTPClient(httpClient))
+	structuredOutputClient := instructor.FromAnthropic(
+		client,
+		instructor.WithMode(instructor.ModeToolCall),
+		instructor.WithMaxRetries(3),
+	)
+
+	return &anthropicProvider{
+		client:                 client,
+		structuredOutputClient: structuredOutputClient,
+	}
+}
+
+func (p *anthropicProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := p.client.CreateMessages(ctx, toAnthropicRequest(req))
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	reportCacheWrites(ctx, resp.Usage.CacheCreationInputTokens)
+	return fromAnthropicResponse(resp)
+}
+
+func (p *anthropicProvider) CreateStructuredCompletion(ctx context.Context, req openai.ChatCompletionRequest, out any) (openai.ChatCompletionResponse, error) {
+	anthropicReq := toAnthropicRequest(req)
+	// Force the model to answer through the schema tool instructor provides
+	anthropicReq.ToolChoice = &anthropic.ToolChoice{Type: "any"}
+
+	resp, err := p.structuredOutputClient.CreateMessages(ctx, anthropicReq, out)
+	reportCacheWrites(ctx, resp.Usage.CacheCreationInputTokens)
+	chatResp, convErr := fromAnthropicResponse(resp)
+	if err != nil {
+		return chatResp, err
+	}
+	return chatResp, convErr
+}
+
+func toAnthropicRequest(req openai.ChatCompletionRequest) anthropic.MessagesRequest {
+	anthropicReq := anthropic.MessagesRequest{
+		Model:     anthropic.Model(req.Model),
+		MaxTokens: anthropicDefaultMaxTokens,
+	}
+	if req.MaxCompletionTokens > 0 {
+		anthropicReq.MaxTokens = req.MaxCompletionTokens
+	}
+	if req.Temperature != 0 {
+		anthropicReq.SetTemperature(req.Temperature)
+	}
+
+	var system []string
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case openai.ChatMessageRoleSystem, openai.ChatMessageRoleDeveloper:
+			system = append(system, msg.Content)
+		case openai.ChatMessageRoleAssistant:
+			var content []anthropic.MessageContent
+			if msg.Content != "" {
+				content = append(content, anthropic.NewTextMessageContent(msg.Content))
+			}
+			for _, toolCall := range msg.ToolCalls {
+				input := json.RawMessage(toolCall.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				content = append(content, anthropic.NewToolUseMessageContent(toolCall.ID, toolCall.Function.Name, input))
+			}
+			anthropicReq.Messages = appendAnthropicMessage(anthropicReq.Messages, anthropic.RoleAssistant, content)
+		case openai.ChatMessageRoleTool:
+			content := []anthropic.MessageContent{anthropic.NewToolResultMessageContent(msg.ToolCallID, msg.Content, false)}
+			anthropicReq.Messages = appendAnthropicMessage(anthropicReq.Messages, anthropic.RoleUser, content)
+		default:
+			content := []anthropic.MessageContent{anthropic.NewTextMessageContent(msg.Content)}
+			anthropicReq.Messages = appendAnthropicMessage(anthropicReq.Messages, anthropic.RoleUser, content)
+		}
+	}
+	anthropicReq.System = strings.Join(system, "\n\n")
+
+	for _, tool := range req.Tools {
+		if tool.Function == nil {
+			continue
+		}
+		anthropicReq.Tools = append(anthropicReq.Tools, anthropic.ToolDefinition{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: tool.Function.Parameters,
+		})
+	}
+
+	return anthropicReq
+}
+
+// appendAnthropicMessage appends content as a new message, merging it into the
+// previous message when the roles match since the Messages API requires
+// alternating roles (e.g. consecutive tool results).
+func appendAnthropicMessage(messages []anthropic.Message, role anthropic.ChatRole, content []anthropic.MessageContent) []anthropic.Message {
+	if len(content) == 0 {
+		return messages
+	}
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		messages[n-1].Content = append(messages[n-1].Content, content...)
+		return messages
+	}
+	return append(messages, anthropic.Message{Role: role, Content: content})
+}
+
+// fromAnthropicResponse converts a Messages API response. A response without
+// content, e.g. a refusal, keeps its usage but fails with ErrNoChoices.
+func fromAnthropicResponse(resp anthropic.MessagesResponse) (openai.ChatCompletionResponse, error) {
+	// Anthropic reports cached input separately from the uncached input
+	// tokens. Cache writes are billed at their own rate and reported to the
+	// metering wrapper by the caller.
+	promptTokens := resp.Usage.InputTokens + resp.Usage.CacheReadInputTokens + resp.Usage.CacheCreationInputTokens
+	chatResp := openai.ChatCompletionResponse{
+		ID:    resp.ID,
+		Model: string(resp.Model),
+		Usage: openai.Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      promptTokens + resp.Usage.OutputTokens,
+			PromptTokensDetails: &openai.PromptTokensDetails{
+				CachedTokens: resp.Usage.CacheReadInputTokens,
+			},
+		},
+	}
+	if len(resp.Content) == 0 {
+		return chatResp, fmt.Errorf("%w: anthropic stop reason %q", ErrNoChoices, resp.StopReason)
+	}
+
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+	var text []string
+	for _, content := range resp.Content {
+		switch content.Type {
+		case anthropic.MessagesContentTypeText:
+			text = append(text, content.GetText())
+		case anthropic.MessagesContentTypeToolUse:
+			if content.MessageContentToolUse == nil {
+				continue
+			}
+			msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+				ID:   content.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      content.Name,
+					Arguments: string(content.Input),
+				},
+			})
+		}
+	}
+	msg.Content = strings.Join(text, "\n")
+
+	finishReason := openai.FinishReasonStop
+	switch resp.StopReason {
+	case anthropic.MessagesStopReasonToolUse:
+		finishReason = openai.FinishReasonToolCalls
+	case anthropic.MessagesStopReasonMaxTokens:
+		finishReason = openai.FinishReasonLength
+	}
+
+	chatResp.Choices = []openai.ChatCompletionChoice{{Message: msg, FinishReason: finishReason}}
+	return chatResp, nil
+}
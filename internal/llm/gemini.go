@@ -0,0 +1,288 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
+)
+
+// geminiStructuredRetries is the number of attempts made to obtain a reply
+// that decodes into the requested schema.
+const geminiStructuredRetries = 3
+
+// geminiThinkingBudgets maps OpenAI reasoning effort levels to Gemini
+// thinking budgets in tokens.
+var geminiThinkingBudgets = map[string]int32{
+	"minimal": 0,
+	"low":     1024,
+	"medium":  8192,
+	"high":    24576,
+}
+
+// geminiProvider talks to the Gemini API, translating to and from the OpenAI
+// chat completion types. Structured output uses Gemini's native JSON schema
+// support rather than instructor, whose Google integration does not forward
+// the schema.
+type geminiProvider struct {
+	client *genai.Client
+}
+
+func newGeminiProvider(ctx context.Context, apiKey string, httpClient *http.Client) (*geminiProvider, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:     apiKey,
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: httpClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &geminiProvider{client: client}, nil
+}
+
+func (p *geminiProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	contents, generateConfig, err := toGeminiRequest(req)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	resp, err := p.client.Models.GenerateContent(ctx, req.Model, contents, generateConfig)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	return fromGeminiResponse(req.Model, resp)
+}
+
+func (p *geminiProvider) CreateStructuredCompletion(ctx context.Context, req openai.ChatCompletionRequest, out any) (openai.ChatCompletionResponse, error) {
+	contents, generateConfig, err := toGeminiRequest(req)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	reflector := jsonschema.Reflector{
+		AllowAdditionalProperties: false,
+		DoNotReference:            true,
+	}
+	schema, err := cleanSchema(reflector.Reflect(out))
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	generateConfig.ResponseMIMEType = "application/json"
+	generateConfig.ResponseJsonSchema = schema
+
+	var usage openai.Usage
+	var lastErr error
+	for attempt := 0; attempt < geminiStructuredRetries; attempt++ {
+		resp, err := p.client.Models.GenerateContent(ctx, req.Model, contents, generateConfig)
+		if err != nil {
+			return openai.ChatCompletionResponse{Usage: usage}, err
+		}
+
+		chatResp, err := fromGeminiResponse(req.Model, resp)
+		usage = addUsage(usage, chatResp.Usage)
+		chatResp.Usage = usage
+		if err != nil {
+			return chatResp, err
+		}
+
+		lastErr = json.Unmarshal([]byte(chatResp.Choices[0].Message.Content), out)
+		if lastErr == nil {
+			return chatResp, nil
+		}
+	}
+	return openai.ChatCompletionResponse{Usage: usage}, fmt.Errorf("failed to decode structured output: %w", lastErr)
+}
+
+func toGeminiRequest(req openai.ChatCompletionRequest) ([]*genai.Content, *genai.GenerateContentConfig, error) {
+	generateConfig := &genai.GenerateContentConfig{}
+	if req.Temperature != 0 {
+		generateConfig.Temperature = genai.Ptr(req.Temperature)
+	}
+	if req.MaxCompletionTokens > 0 {
+		generateConfig.MaxOutputTokens = int32(req.MaxCompletionTokens)
+	}
+	if budget, ok := geminiThinkingBudgets[req.ReasoningEffort]; ok {
+		generateConfig.ThinkingConfig = &genai.ThinkingConfig{ThinkingBudget: genai.Ptr(budget)}
+	}
+
+	// Tool results must name the function they answer, so remember the name of
+	// every tool call issued by the assistant
+	toolNames := make(map[string]string)
+
+	var system []string
+	var contents []*genai.Content
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case openai.ChatMessageRoleSystem, openai.ChatMessageRoleDeveloper:
+			system = append(system, msg.Content)
+		case openai.ChatMessageRoleAssistant:
+			var parts []*genai.Part
+			if msg.Content != "" {
+				parts = append(parts, genai.NewPartFromText(msg.Content))
+			}
+			for _, toolCall := range msg.ToolCalls {
+				args := make(map[string]any)
+				if toolCall.Function.Arguments != "" {
+					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+						return nil, nil, fmt.Errorf("failed to decode tool call arguments: %w", err)
+					}
+				}
+				toolNames[toolCall.ID] = toolCall.Function.Name
+				parts = append(parts, &genai.Part{FunctionCall: &genai.FunctionCall{
+					ID:   toolCall.ID,
+					Name: toolCall.Function.Name,
+					Args: args,
+				}})
+			}
+			contents = appendGeminiContent(contents, genai.RoleModel, parts)
+		case openai.ChatMessageRoleTool:
+			name := msg.Name
+			if name == "" {
+				name = toolNames[msg.ToolCallID]
+			}
+			parts := []*genai.Part{{FunctionResponse: &genai.FunctionResponse{
+				ID:       msg.ToolCallID,
+				Name:     name,
+				Response: map[string]any{"output": msg.Content},
+			}}}
+			contents = appendGeminiContent(contents, genai.RoleUser, parts)
+		default:
+			contents = appendGeminiContent(contents, genai.RoleUser, []*genai.Part{genai.NewPartFromText(msg.Content)})
+		}
+	}
+	if len(system) > 0 {
+		generateConfig.SystemInstruction = genai.NewContentFromText(strings.Join(system, "\n\n"), genai.RoleUser)
+	}
+
+	var declarations []*genai.FunctionDeclaration
+	for _, tool := range req.Tools {
+		if tool.Function == nil {
+			continue
+		}
+		parameters, err := cleanSchema(tool.Function.Parameters)
+		if err != nil {
+			return nil, nil, err
+		}
+		declarations = append(declarations, &genai.FunctionDeclaration{
+			Name:                 tool.Function.Name,
+			Description:          tool.Function.Description,
+			ParametersJsonSchema: parameters,
+		})
+	}
+	if len(declarations) > 0 {
+		generateConfig.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
+	}
+
+	return contents, generateConfig, nil
+}
+
+// appendGeminiContent appends parts as a new content block, merging it into
+// the previous block when the roles match.
+func appendGeminiContent(contents []*genai.Content, role string, parts []*genai.Part) []*genai.Content {
+	if len(parts) == 0 {
+		return contents
+	}
+	if n := len(contents); n > 0 && contents[n-1].Role == role {
+		contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+		return contents
+	}
+	return append(contents, &genai.Content{Role: role, Parts: parts})
+}
+
+func fromGeminiResponse(model string, resp *genai.GenerateContentResponse) (openai.ChatCompletionResponse, error) {
+	chatResp := openai.ChatCompletionResponse{Model: model}
+	if resp.UsageMetadata != nil {
+		chatResp.Usage = openai.Usage{
+			PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
+			CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount + resp.UsageMetadata.ThoughtsTokenCount),
+			TotalTokens:      int(resp.UsageMetadata.TotalTokenCount),
+			CompletionTokensDetails: &openai.CompletionTokensDetails{
+				ReasoningTokens: int(resp.UsageMetadata.ThoughtsTokenCount),
+			},
+		}
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		reason := "no candidates"
+		switch {
+		case resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "":
+			reason = fmt.Sprintf("prompt blocked: %s", resp.PromptFeedback.BlockReason)
+		case len(resp.Candidates) > 0:
+			reason = fmt.Sprintf("finish reason %s", resp.Candidates[0].FinishReason)
+		}
+		return chatResp, fmt.Errorf("%w: gemini %s", ErrNoChoices, reason)
+	}
+
+	candidate := resp.Candidates[0]
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant}
+	var text []string
+	for i, part := range candidate.Content.Parts {
+		switch {
+		case part.Thought:
+			continue
+		case part.FunctionCall != nil:
+			arguments, err := json.Marshal(part.FunctionCall.Args)
+			if err != nil {
+				return chatResp, fmt.Errorf("failed to encode tool call arguments: %w", err)
+			}
+			// Gemini does not always assign call IDs, but tool results need one
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", i)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+				ID:   id,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      part.FunctionCall.Name,
+					Arguments: string(arguments),
+				},
+			})
+		case part.Text != "":
+			text = append(text, part.Text)
+		}
+	}
+	msg.Content = strings.Join(text, "")
+
+	finishReason := openai.FinishReasonStop
+	if len(msg.ToolCalls) > 0 {
+		finishReason = openai.FinishReasonToolCalls
+	} else if candidate.FinishReason == genai.FinishReasonMaxTokens {
+		finishReason = openai.FinishReasonLength
+	}
+	chatResp.Choices = []openai.ChatCompletionChoice{{Message: msg, FinishReason: finishReason}}
+	return chatResp, nil
+}
+
+// cleanSchema converts a JSON schema into a generic map, dropping the
+// $schema and $id keywords that Gemini rejects.
+func cleanSchema(schema any) (map[string]any, error) {
+	raw, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	var cleaned map[string]any
+	if err := json.Unmarshal(raw, &cleaned); err != nil {
+		return nil, fmt.Errorf("failed to decode schema: %w", err)
+	}
+	delete(cleaned, "$schema")
+	delete(cleaned, "$id")
+	return cleaned, nil
+}
+
+func addUsage(a, b openai.Usage) openai.Usage {
+	a.PromptTokens += b.PromptTokens
+	a.CompletionTokens += b.CompletionTokens
+	a.TotalTokens += b.TotalTokens
+	if b.CompletionTokensDetails != nil {
+		if a.CompletionTokensDetails == nil {
+			a.CompletionTokensDetails = &openai.CompletionTokensDetails{}
+		}
+		a.CompletionTokensDetails.ReasoningTokens += b.CompletionTokensDetails.ReasoningTokens
+	}
+	return a
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"context"
+	"deep-research/internal/usage"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// meteredProvider records the token usage of every call with the usage
+// tracker attached to the request context.
+type meteredProvider struct {
+	Provider
+}
+
+func (p meteredProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	call := &callUsage{}
+	resp, err := p.Provider.CreateChatCompletion(context.WithValue(ctx, callUsageKey{}, call), req)
+	record(ctx, req, resp, call)
+	return resp, err
+}
+
+func (p meteredProvider) CreateStructuredCompletion(ctx context.Context, req openai.ChatCompletionRequest, out any) (openai.ChatCompletionResponse, error) {
+	call := &callUsage{}
+	resp, err := p.Provider.CreateStructuredCompletion(context.WithValue(ctx, callUsageKey{}, call), req, out)
+	record(ctx, req, resp, call)
+	return resp, err
+}
+
+// callUsage collects the usage a provider reports for a call beyond what
+// openai.Usage can express.
+type callUsage struct {
+	cacheWriteTokens int
+}
+
+type callUsageKey struct{}
+
+// reportCacheWrites records prompt tokens that the call running in ctx wrote
+// to the provider's prompt cache, which are billed at their own rate.
+func reportCacheWrites(ctx context.Context, tokens int) {
+	if call, ok := ctx.Value(callUsageKey{}).(*callUsage); ok {
+		call.cacheWriteTokens += tokens
+	}
+}
+
+// record adds the usage of a call. Failed calls are recorded too when the
+// provider reports usage for them, since those tokens are still billed.
+func record(ctx context.Context, req openai.ChatCompletionRequest, resp openai.ChatCompletionResponse, call *callUsage) {
+	if resp.Usage.TotalTokens == 0 && resp.Usage.PromptTokens == 0 {
+		return
+	}
+	model := req.Model
+	if model == "" {
+		model = resp.Model
+	}
+	usage.Add(ctx, model, resp.Usage, call.cacheWriteTokens)
+}
@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	"github.com/instructor-ai/instructor-go/pkg/instructor"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// openAIProvider talks to the OpenAI API, or to any server implementing the
+// OpenAI chat completions API such as Ollama, vLLM or llama.cpp.
+type openAIProvider struct {
+	client                 *openai.Client
+	structuredOutputClient *instructor.InstructorOpenAI
+}
+
+func newOpenAIProvider(clientConfig openai.ClientConfig) *openAIProvider {
+	client := openai.NewClientWithConfig(clientConfig)
+	structuredOutputClient := instructor.FromOpenAI(
+		client,
+		instructor.WithMode(instructor.ModeJSONSchema),
+		instructor.WithMaxRetries(3),
+	)
+
+	return &openAIProvider{
+		client:                 client,
+		structuredOutputClient: structuredOutputClient,
+	}
+}
+
+func (p *openAIProvider) CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := p.client.CreateChatCompletion(ctx, req)
+	if err == nil && len(resp.Choices) == 0 {
+		return resp, ErrNoChoices
+	}
+	return resp, err
+}
+
+func (p *openAIProvider) CreateStructuredCompletion(ctx context.Context, req openai.ChatCompletionRequest, out any) (resp openai.ChatCompletionResponse, err error) {
+	// instructor reads the first choice without checking there is one, which
+	// OpenAI-compatible servers do not always return
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(runtime.Error); !ok {
+				panic(r)
+			}
+			resp, err = openai.ChatCompletionResponse{}, fmt.Errorf("%w: %v", ErrNoChoices, r)
+		}
+	}()
+	return p.structuredOutputClient.CreateChatCompletion(ctx, req, out)
+}
@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"context"
+	"deep-research/internal/config"
+	"deep-research/internal/transport"
+	"errors"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	ProviderOpenAI           = "openai"
+	ProviderAnthropic        = "anthropic"
+	ProviderGemini           = "gemini"
+	ProviderOpenAICompatible = "openai-compatible"
+)
+
+// ErrNoChoices is returned when a model answers without any message, e.g.
+// after a refusal, a safety block or with no candidates.
+var ErrNoChoices = errors.New("model returned no choices")
+
+// Provider is a chat model backend. Requests and responses use the OpenAI
+// chat completion types regardless of the underlying API so workflows can
+// switch providers without changes.
+type Provider interface {
+	// CreateChatCompletion sends a chat request, including any tool definitions,
+	// and returns the model reply.
+	CreateChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+	// CreateStructuredCompletion sends a chat request and decodes the reply into
+	// out, which must be a pointer to a struct describing the expected JSON.
+	CreateStructuredCompletion(ctx context.Context, req openai.ChatCompletionRequest, out any) (openai.ChatCompletionResponse, error)
+}
+
+// StageProviders holds the provider used by each pipeline stage.
+type StageProviders struct {
+	Clarify    Provider
+	Brief      Provider
+	Supervisor Provider
+	Research   Provider
+	Summarizer Provider
+	Report     Provider
+}
+
+// InitializeStageProviders builds the provider configured for each stage.
+// Stages that use the same provider share a single client.
+func InitializeStageProviders(ctx context.Context, cfg *config.Config) (*StageProviders, error) {
+	providers := make(map[string]Provider)
+	get := func(stage config.StageConfig) (Provider, error) {
+		name := stage.Provider
+		if name == "" {
+			name = ProviderOpenAI
+		}
+		if provider, ok := providers[name]; ok {
+			return provider, nil
+		}
+		provider, err := NewProvider(ctx, cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		providers[name] = provider
+		return provider, nil
+	}
+
+	var err error
+	sp := &StageProviders{}
+	if sp.Clarify, err = get(cfg.Models.Clarify); err != nil {
+		return nil, err
+	}
+	if sp.Brief, err = get(cfg.Models.Brief); err != nil {
+		return nil, err
+	}
+	if sp.Supervisor, err = get(cfg.Models.Supervisor); err != nil {
+		return nil, err
+	}
+	if sp.Research, err = get(cfg.Models.Research); err != nil {
+		return nil, err
+	}
+	if sp.Summarizer, err = get(cfg.Models.Summarizer); err != nil {
+		return nil, err
+	}
+	if sp.Report, err = get(cfg.Models.Report); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+// NewProvider builds the named provider from cfg. The provider records the
+// token usage of its calls with the tracker attached to the request context.
+func NewProvider(ctx context.Context, cfg *config.Config, name string) (Provider, error) {
+	provider, err := newProvider(ctx, cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	return meteredProvider{provider}, nil
+}
+
+func newProvider(ctx context.Context, cfg *config.Config, name string) (Provider, error) {
+	// Transient failures and rate limits are retried below the client
+	httpClient := transport.NewClient(name, cfg.Retry, cfg.Limits[name], 0, cfg.Transport)
+	switch name {
+	case ProviderOpenAI:
+		clientConfig := openai.DefaultConfig(cfg.OpenAIKey)
+		clientConfig.BaseURL = cfg.OpenAIBaseURL
+		clientConfig.HTTPClient = httpClient
+		return newOpenAIProvider(clientConfig), nil
+	case ProviderOpenAICompatible:
+		clientConfig := openai.DefaultConfig(cfg.OpenAICompatibleKey)
+		clientConfig.BaseURL = cfg.OpenAICompatibleBaseURL
+		clientConfig.HTTPClient = httpClient
+		return newOpenAIProvider(clientConfig), nil
+	case ProviderAnthropic:
+		return newAnthropicProvider(cfg.AnthropicKey, httpClient), nil
+	case ProviderGemini:
+		provider, err := newGeminiProvider(ctx, cfg.GeminiKey, httpClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize gemini client: %w", err)
+		}
+		return provider, nil
+	default:
+		return nil, &config.ConfigError{
+			Field:   "Provider",
+			Value:   name,
+			Message: "unknown LLM provider, expected one of openai, anthropic, gemini or openai-compatible",
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"deep-research/internal/config"
+	"deep-research/internal/usage"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	openai "github.com/sashabaranov/go-openai"
+	"google.golang.org/genai"
+)
+
+// redirect sends every request to server, whatever its host.
+type redirect struct{ server *httptest.Server }
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, _ := url.Parse(r.server.URL)
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newJSONServer answers every request with body.
+func newJSONServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProvidersRejectResponsesWithoutChoices(t *testing.T) {
+	openAIServer := newJSONServer(t, `{"id":"chatcmpl-1","choices":[],"usage":{"prompt_tokens":10,"total_tokens":10}}`)
+	clientConfig := openai.DefaultConfig("test")
+	clientConfig.BaseURL = openAIServer.URL + "/v1"
+	openAIProvider := newOpenAIProvider(clientConfig)
+
+	anthropicServer := newJSONServer(t, `{"id":"msg_1","content":[],"stop_reason":"refusal","usage":{"input_tokens":10,"output_tokens":0}}`)
+	anthropicProvider := newAnthropicProvider("test", &http.Client{Transport: redirect{anthropicServer}})
+
+	var out struct {
+		Answer string `json:"answer"`
+	}
+	calls := []struct {
+		name string
+		call func() (openai.ChatCompletionResponse, error)
+	}{
+		{"openai chat", func() (openai.ChatCompletionResponse, error) {
+			return openAIProvider.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: "gpt-5"})
+		}},
+		{"openai structured", func() (openai.ChatCompletionResponse, error) {
+			return openAIProvider.CreateStructuredCompletion(context.Background(), openai.ChatCompletionRequest{Model: "gpt-5"}, &out)
+		}},
+		{"anthropic chat", func() (openai.ChatCompletionResponse, error) {
+			return anthropicProvider.CreateChatCompletion(context.Background(), openai.ChatCompletionRequest{Model: "claude-sonnet-4"})
+		}},
+	}
+	for _, tt := range calls {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.call(); !errors.Is(err, ErrNoChoices) {
+				t.Errorf("error = %v, want ErrNoChoices", err)
+			}
+		})
+	}
+}
+
+func TestFromGeminiResponseWithoutCandidates(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *genai.GenerateContentResponse
+		want string
+	}{
+		{
+			name: "no candidates",
+			resp: &genai.GenerateContentResponse{},
+			want: "model returned no choices: gemini no candidates",
+		},
+		{
+			name: "blocked prompt",
+			resp: &genai.GenerateContentResponse{PromptFeedback: &genai.GenerateContentResponsePromptFeedback{BlockReason: genai.BlockedReasonSafety}},
+			want: "model returned no choices: gemini prompt blocked: SAFETY",
+		},
+		{
+			name: "empty candidate",
+			resp: &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonSafety}}},
+			want: "model returned no choices: gemini finish reason SAFETY",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fromGeminiResponse("gemini-2.5-flash", tt.resp)
+			if !errors.Is(err, ErrNoChoices) || err.Error() != tt.want {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromAnthropicResponseUsage(t *testing.T) {
+	resp, err := fromAnthropicResponse(anthropic.MessagesResponse{
+		Content: []anthropic.MessageContent{anthropic.NewTextMessageContent("Hello")},
+		Usage: anthropic.MessagesUsage{
+			InputTokens:              100,
+			OutputTokens:             20,
+			CacheCreationInputTokens: 1000,
+			CacheReadInputTokens:     4000,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Usage.PromptTokens != 5100 || resp.Usage.PromptTokensDetails.CachedTokens != 4000 || resp.Usage.TotalTokens != 5120 {
+		t.Errorf("usage = %+v, want 5100 prompt tokens of which 4000 cached, 5120 in total", resp.Usage)
+	}
+}
+
+func TestMeteredAnthropicRecordsCacheWrites(t *testing.T) {
+	server := newJSONServer(t, `{"id":"msg_1","content":[{"type":"text","text":"Hello"}],"stop_reason":"end_turn",`+
+		`"usage":{"input_tokens":100,"output_tokens":20,"cache_creation_input_tokens":1000,"cache_read_input_tokens":4000}}`)
+	provider := meteredProvider{newAnthropicProvider("test", &http.Client{Transport: redirect{server}})}
+
+	tracker := usage.NewTracker(nil, map[string]config.ModelPrice{
+		"claude-sonnet-4": {Input: 3, CachedInput: 0.3, CacheWriteInput: 3.75, Output: 15},
+	})
+	ctx := usage.WithTracker(context.Background(), tracker)
+	if _, err := provider.CreateChatCompletion(ctx, openai.ChatCompletionRequest{Model: "claude-sonnet-4"}); err != nil {
+		t.Fatal(err)
+	}
+
+	records := tracker.Records()
+	if len(records) != 1 || records[0].CacheWriteTokens != 1000 || records[0].CachedTokens != 4000 {
+		t.Fatalf("records = %+v, want one call with 1000 cache write and 4000 cached tokens", records)
+	}
+	// 100 input at $3, 4000 cache reads at $0.30, 1000 cache writes at $3.75
+	// and 20 output at $15 per million tokens
+	want := (100*3 + 4000*0.3 + 1000*3.75 + 20*15) / 1_000_000
+	if got := tracker.Summary().Total.CostUSD; math.Abs(got-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v", got, want)
+	}
+}
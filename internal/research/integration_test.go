@@ -0,0 +1,127 @@
+package research
+
+import (
+	"context"
+	"deep-research/internal/fakeapi"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// describeChat names the kind of a chat completion request: the structured
+// output it asks for, or the research agent.
+func describeChat(req openai.ChatCompletionRequest) string {
+	if name := fakeapi.SchemaName(req); name != "" {
+		return name
+	}
+	if fakeapi.Offers("search_tool")(req) {
+		return "research_agent"
+	}
+	return "unknown"
+}
+
+// TestSessionEndToEnd drives clarification, brief, planning, web research
+// and report writing through the fake APIs.
+func TestSessionEndToEnd(t *testing.T) {
+	chat := fakeapi.NewOpenAI()
+	defer chat.Close()
+	exa := fakeapi.NewExa()
+	defer exa.Close()
+
+	page := exa.Page("perovskite.html", "text/html",
+		`<html><head><title>Perovskite cells</title></head><body><article><p>Perovskite tandem cells passed 33% efficiency in lab tests.</p></article></body></html>`)
+	exa.On(fakeapi.QueryContains("solar efficiency"), fakeapi.SearchResult{
+		Title:         "Solar efficiency records",
+		URL:           "https://example.com/records",
+		PublishedDate: "2024-05-01",
+		Text:          "Silicon cells reach about 27% efficiency.",
+	})
+
+	chat.On(fakeapi.Schema("ClarifyWithUserOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"need_clarification": true, "question": "Residential or utility-scale panels?"}},
+		fakeapi.ChatReply{JSON: map[string]any{"need_clarification": false, "verification": "Will research residential panel efficiency."}})
+	chat.On(fakeapi.Schema("ResearchBriefGenerationOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"research_brief": "Research residential solar panel efficiency."}})
+	chat.On(fakeapi.Schema("ResearchSupervisorOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"subtopics": []map[string]string{{"title": "Efficiency", "brief": "Research residential solar panel efficiency."}}}})
+	chat.On(fakeapi.Schema("SummarizedResearchOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"summary": "Efficiency figures.", "key_excerpts": "27% and 33%."}})
+	chat.On(fakeapi.Schema("ResearchReportGenerationOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{
+			"report":    "# Solar Efficiency\n\nSilicon reaches 27% [2], perovskite tandems 33% [1].",
+			"citations": []map[string]int{{"marker": 1, "source_id": 2}, {"marker": 2, "source_id": 1}},
+		}})
+	chat.On(fakeapi.All(fakeapi.Offers("search_tool"), fakeapi.Not(fakeapi.AfterTool(""))),
+		fakeapi.ChatReply{ToolCalls: []fakeapi.ToolCall{{Name: "search_tool", Arguments: map[string]string{"query": "solar efficiency"}}}})
+	chat.On(fakeapi.AfterTool("search_tool"),
+		fakeapi.ChatReply{ToolCalls: []fakeapi.ToolCall{{Name: "fetch_url", Arguments: map[string]string{"url": page}}}})
+	chat.On(fakeapi.AfterTool("fetch_url"), fakeapi.ChatReply{Content: "Research complete."})
+
+	session := newFakeSession(t, chat, exa, map[string]string{"FETCH_ALLOW_PRIVATE": "true"})
+	ctx := context.Background()
+
+	if err := session.AddUserMessage("How efficient are solar panels?"); err != nil {
+		t.Fatal(err)
+	}
+	reply, cont, err := session.Clarify(ctx)
+	if err != nil || !cont || !strings.Contains(reply, "Residential or utility-scale") {
+		t.Fatalf("first Clarify() = %q, %v, %v, want the clarifying question", reply, cont, err)
+	}
+	if err := session.AddUserMessage("Residential."); err != nil {
+		t.Fatal(err)
+	}
+	reply, cont, err = session.Clarify(ctx)
+	if err != nil || cont || !strings.Contains(reply, "Will research residential") {
+		t.Fatalf("second Clarify() = %q, %v, %v, want the verification", reply, cont, err)
+	}
+
+	report, err := session.Research(ctx)
+	if err != nil {
+		t.Fatalf("Research() error = %v", err)
+	}
+
+	wantReport := "# Solar Efficiency\n\nSilicon reaches 27% [1], perovskite tandems 33% [2].\n\n## Sources\n\n" +
+		"1. [Solar efficiency records](https://example.com/records), published 2024-05-01\n" +
+		"2. [Perovskite cells](" + page + ")\n"
+	if report != wantReport {
+		t.Errorf("report = %q, want %q", report, wantReport)
+	}
+	if session.State.CompletedStage != StageResearchReport {
+		t.Errorf("completed stage = %q, want %q", session.State.CompletedStage, StageResearchReport)
+	}
+	if session.State.CompletedAt.IsZero() {
+		t.Error("completion time was not recorded")
+	}
+
+	var kinds []string
+	for _, req := range chat.Requests() {
+		kinds = append(kinds, describeChat(req))
+	}
+	wantKinds := []string{
+		"ClarifyWithUserOutputSchema",
+		"ClarifyWithUserOutputSchema",
+		"ResearchBriefGenerationOutputSchema",
+		"ResearchSupervisorOutputSchema",
+		"research_agent",
+		"SummarizedResearchOutputSchema",
+		"research_agent",
+		"SummarizedResearchOutputSchema",
+		"research_agent",
+		"ResearchReportGenerationOutputSchema",
+	}
+	if !reflect.DeepEqual(kinds, wantKinds) {
+		t.Errorf("chat requests = %v, want %v", kinds, wantKinds)
+	}
+	if queries := exa.Queries(); !reflect.DeepEqual(queries, []string{"solar efficiency"}) {
+		t.Errorf("search queries = %v, want [solar efficiency]", queries)
+	}
+
+	for _, req := range chat.Unmatched() {
+		t.Errorf("unscripted chat request: %s with %d messages", describeChat(req), len(req.Messages))
+	}
+	for _, query := range exa.Unmatched() {
+		t.Errorf("unscripted search query %q", query)
+	}
+}
@@ -0,0 +1,106 @@
+package research
+
+import (
+	"context"
+	"deep-research/internal/fakeapi"
+	"deep-research/internal/transport"
+	"flag"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var record = flag.Bool("record", false, "re-record testdata cassettes against the fake APIs")
+
+// Endpoints the replayed sessions are configured with. Replaying never
+// connects to them.
+const (
+	replayOpenAIURL = "https://api.openai.com/v1"
+	replayExaURL    = "https://api.exa.ai/search"
+)
+
+// hostRewriter sends requests for the configured endpoints to local fakes,
+// so a cassette recorded against the fakes names the real endpoints.
+type hostRewriter map[string]*url.URL
+
+func (h hostRewriter) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, ok := h[req.URL.Host]
+	if !ok {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host, req.Host = target.Scheme, target.Host, ""
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// recordSessionCassette records a scripted run of query to path.
+func recordSessionCassette(t *testing.T, path, query string) {
+	t.Helper()
+	chat := fakeapi.NewOpenAI()
+	defer chat.Close()
+	exa := fakeapi.NewExa()
+	defer exa.Close()
+	scriptSubtopics(chat, exa, 2, "solar")
+
+	chatURL, _ := url.Parse(chat.URL())
+	exaURL, _ := url.Parse(exa.URL())
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	cassette, err := transport.NewCassette(path, transport.CassetteRecord, hostRewriter{
+		"api.openai.com": chatURL,
+		"api.exa.ai":     exaURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("OPENAI_BASE_URL", replayOpenAIURL)
+	t.Setenv("EXA_ENDPOINT", replayExaURL)
+	session := newTestSession(t, cassette, nil)
+	if err := session.AddUserMessage(query); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Research(context.Background()); err != nil {
+		t.Fatalf("failed to record session: %v", err)
+	}
+}
+
+// TestReplaySession runs a full session offline from a committed cassette.
+// Run with -record to re-record it.
+func TestReplaySession(t *testing.T) {
+	const query = "How do solar panels work?"
+	path := filepath.Join("testdata", "solar_session.json")
+	if *record {
+		recordSessionCassette(t, path, query)
+	}
+
+	cassette, err := transport.NewCassette(path, transport.CassetteReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENAI_BASE_URL", replayOpenAIURL)
+	t.Setenv("EXA_ENDPOINT", replayExaURL)
+	session := newTestSession(t, cassette, nil)
+	if err := session.AddUserMessage(query); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := session.Research(context.Background())
+	if err != nil {
+		t.Fatalf("Research() error = %v", err)
+	}
+
+	if session.State.ResearchBrief != "Research solar" {
+		t.Errorf("brief = %q, want %q", session.State.ResearchBrief, "Research solar")
+	}
+	if got := len(session.State.CompressedResearchNotes); got != 2 {
+		t.Errorf("collected %d notes, want 2", got)
+	}
+	want := "# Report\n\nFindings [1].\n\n## Sources\n\n1. [solar 1](https://example.com/solar-1)\n"
+	if report != want {
+		t.Errorf("report = %q, want %q", report, want)
+	}
+}
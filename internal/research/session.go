@@ -0,0 +1,401 @@
+package research
+
+import (
+	"context"
+	"crypto/rand"
+	"deep-research/internal/cache"
+	"deep-research/internal/config"
+	"deep-research/internal/llm"
+	"deep-research/internal/tools"
+	"deep-research/internal/usage"
+	"deep-research/internal/workflows"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Stages of the research pipeline, in the order they run.
+const (
+	StageClarifyWithUser = "clarify_with_user"
+	StageResearchBrief   = "research_brief_generation"
+	StageResearchPlan    = "research_planning"
+	StageWebResearch     = "web_research"
+	StageResearchReport  = "research_report_generation"
+	StageCompleted       = "completed"
+)
+
+// StageError records which research stage an error originated from.
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// State holds everything produced while researching a single query.
+type State struct {
+	Conversation  []openai.ChatCompletionMessage `json:"conversation"`
+	ResearchBrief string                         `json:"research_brief"`
+	// ResearchPlan holds the subtopics the supervisor split the brief into,
+	// and Subtopics the research done on each of them, in the same order
+	ResearchPlan            []workflows.Subtopic     `json:"research_plan"`
+	Subtopics               []SubtopicResearch       `json:"subtopics"`
+	CompressedResearchNotes []workflows.ResearchNote `json:"compressed_research_notes"`
+	Report                  workflows.ResearchReport `json:"report"`
+	// Usage is owned by the session's usage tracker while the session runs
+	// and is only filled in on the checkpointed copy
+	Usage []usage.Record `json:"usage"`
+	// PromptVersion identifies the prompts the session was researched with
+	PromptVersion string `json:"prompt_version,omitempty"`
+	// ResearchElapsed is the wall-clock time spent on web research so far,
+	// which a resumed session counts against the time limit
+	ResearchElapsed time.Duration `json:"research_elapsed,omitempty"`
+
+	// CompletedStage is the last stage that ran to completion. Resuming a
+	// session continues from the stage after it.
+	CompletedStage string `json:"completed_stage,omitempty"`
+	// CompletedAt is when the report was written
+	CompletedAt time.Time `json:"completed_at,omitzero"`
+}
+
+// stageOrder ranks the stages so a session can tell which ones already ran.
+var stageOrder = map[string]int{
+	"":                   0,
+	StageClarifyWithUser: 1,
+	StageResearchBrief:   2,
+	StageResearchPlan:    3,
+	StageWebResearch:     4,
+	StageResearchReport:  5,
+}
+
+// completed reports whether stage has already run to completion.
+func (st *State) completed(stage string) bool {
+	return stageOrder[st.CompletedStage] >= stageOrder[stage]
+}
+
+// ScopingComplete reports whether the clarification conversation has ended.
+func (st *State) ScopingComplete() bool {
+	return st.completed(StageClarifyWithUser)
+}
+
+type WorkflowManager struct {
+	clarifyWithUser          workflows.ChatSessionWorkflow
+	researchBriefGeneration  workflows.ChatSessionWorkflow
+	researchSupervisor       workflows.ChatSessionWorkflow
+	researchReportGeneration workflows.ChatSessionWorkflow
+
+	// newWebResearch creates the research agent of a subtopic over its own
+	// conversation, notes and budget usage, drawing from the budget shared
+	// by every agent of the session
+	newWebResearch func(messages *[]openai.ChatCompletionMessage, notes *[]workflows.ResearchNote, usage *workflows.ResearchUsage, budget *workflows.SharedBudget, logger *slog.Logger) workflows.ChatSessionWorkflow
+}
+
+// Session drives the research workflows over a shared State. It is used by
+// both the interactive chat and the non-interactive entry points.
+type Session struct {
+	// ID identifies the session in the checkpoint store
+	ID        string
+	State     *State
+	logger    *slog.Logger
+	workflows *WorkflowManager
+	store     *Store
+	budget    config.ResearchBudget
+	tracker   *usage.Tracker
+	prompts   *workflows.Prompts
+	stage     string
+	// mu guards State while sub-researchers run concurrently
+	mu sync.Mutex
+
+	// OnProgress, if set, receives the progress events emitted while researching
+	OnProgress workflows.ProgressReporter
+}
+
+// NewSession creates a session with empty state. If store is not nil, the
+// state is checkpointed to it after every completed step. localSearch, if
+// not nil, is offered to the research agent alongside web search, and
+// summaries are reused from responseCache when it is not nil. The workflows
+// build their prompts from prompts.
+func NewSession(cfg *config.Config, providers *llm.StageProviders, searchProvider, localSearch tools.SearchProvider, responseCache *cache.Cache, prompts *workflows.Prompts, store *Store, logger *slog.Logger) (*Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	state := &State{
+		Conversation:            make([]openai.ChatCompletionMessage, 0),
+		ResearchBrief:           "",
+		CompressedResearchNotes: make([]workflows.ResearchNote, 0),
+		Usage:                   make([]usage.Record, 0),
+		PromptVersion:           prompts.Version,
+	}
+	return newSession(id, state, cfg, providers, searchProvider, localSearch, responseCache, prompts, store, logger), nil
+}
+
+// ResumeSession restores a session from its last checkpoint in store. The
+// remaining stages use prompts, which may differ from the prompts the
+// session started with.
+func ResumeSession(id string, cfg *config.Config, providers *llm.StageProviders, searchProvider, localSearch tools.SearchProvider, responseCache *cache.Cache, prompts *workflows.Prompts, store *Store, logger *slog.Logger) (*Session, error) {
+	checkpoint, err := store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Resuming research session",
+		"session_id", id,
+		"completed_stage", checkpoint.State.CompletedStage,
+		"checkpointed_at", checkpoint.UpdatedAt)
+	if checkpoint.State.PromptVersion != prompts.Version {
+		logger.Warn("Prompts changed since the session started",
+			"session_id", id,
+			"checkpoint_prompt_version", checkpoint.State.PromptVersion,
+			"prompt_version", prompts.Version)
+		checkpoint.State.PromptVersion = prompts.Version
+	}
+	return newSession(id, &checkpoint.State, cfg, providers, searchProvider, localSearch, responseCache, prompts, store, logger), nil
+}
+
+func newSession(id string, state *State, cfg *config.Config, providers *llm.StageProviders, searchProvider, localSearch tools.SearchProvider, responseCache *cache.Cache, prompts *workflows.Prompts, store *Store, logger *slog.Logger) *Session {
+	logger = logger.With("session_id", id)
+	logger.Info("Using prompts", "prompt_version", prompts.Version, "overridden", prompts.Overridden)
+	fetcher := tools.NewURLFetcher(cfg.Transport, cfg.FetchMaxChars, cfg.FetchAllowPrivate)
+	sources := workflows.NewSourceIndex(mergeSubtopicNotes(state.Subtopics))
+	return &Session{
+		ID:      id,
+		State:   state,
+		logger:  logger,
+		store:   store,
+		budget:  cfg.Budget,
+		tracker: usage.NewTracker(state.Usage, cfg.Prices),
+		prompts: prompts,
+		workflows: &WorkflowManager{
+			clarifyWithUser:          workflows.NewClarifyWithUser(&state.Conversation, providers.Clarify, cfg.Models.Clarify, logger),
+			researchBriefGeneration:  workflows.NewResearchBriefGeneration(&state.Conversation, providers.Brief, cfg.Models.Brief, logger),
+			researchSupervisor:       workflows.NewResearchSupervisor(&state.ResearchBrief, &state.ResearchPlan, providers.Supervisor, cfg.Models.Supervisor, cfg.Budget.MaxSubtopics, logger),
+			researchReportGeneration: workflows.NewResearchReportGeneration(&state.ResearchBrief, &state.CompressedResearchNotes, &state.Report, providers.Report, cfg.Models.Report, logger),
+			newWebResearch: func(messages *[]openai.ChatCompletionMessage, notes *[]workflows.ResearchNote, researchUsage *workflows.ResearchUsage, budget *workflows.SharedBudget, logger *slog.Logger) workflows.ChatSessionWorkflow {
+				return workflows.NewWebResearch(messages, notes, researchUsage, providers.Research, providers.Summarizer, searchProvider, localSearch, fetcher, responseCache, sources, cfg.Models.Research, cfg.Models.Summarizer, budget, logger)
+			},
+		},
+	}
+}
+
+func (s *Session) AddUserMessage(message string) error {
+	if len(message) == 0 {
+		return fmt.Errorf("user message cannot be empty")
+	}
+
+	s.State.Conversation = append(s.State.Conversation, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: message,
+	})
+
+	s.logger.Debug("User message added to conversation",
+		"message_length", len(message),
+		"conversation_length", len(s.State.Conversation))
+	s.checkpoint()
+	return nil
+}
+
+// Clarify runs the clarify with user workflow once. It returns the reply for
+// the user and whether further clarification is needed.
+func (s *Session) Clarify(ctx context.Context) (string, bool, error) {
+	ctx = s.sessionContext(ctx)
+	ctx = s.enterStage(ctx, StageClarifyWithUser)
+
+	resp, cont, err := s.workflows.clarifyWithUser.Execute(ctx)
+	if err != nil {
+		return "", false, &StageError{Stage: StageClarifyWithUser, Err: err}
+	}
+	if !cont {
+		s.State.CompletedStage = StageClarifyWithUser
+	}
+	s.checkpoint()
+	return resp.(string), cont, nil
+}
+
+// SkipClarification marks the scoping conversation as complete, so the
+// messages added so far are used as-is to generate the research brief.
+func (s *Session) SkipClarification() {
+	s.State.CompletedStage = StageClarifyWithUser
+	s.checkpoint()
+}
+
+// Research executes the non-interactive stages of the session: research
+// brief generation, web research and report writing. It expects the scoping
+// conversation to already be present in the session state. Stages completed
+// before the session was resumed are skipped.
+func (s *Session) Research(ctx context.Context) (string, error) {
+	ctx = s.sessionContext(ctx)
+
+	// Reaching research means the scoping conversation is over
+	if !s.State.completed(StageClarifyWithUser) {
+		s.SkipClarification()
+	}
+
+	// Workflow 2: Generate research brief based on scoping interactions
+	if !s.State.completed(StageResearchBrief) {
+		stageCtx := s.enterStage(ctx, StageResearchBrief)
+		resp, _, err := s.workflows.researchBriefGeneration.Execute(stageCtx)
+		if err != nil {
+			s.logger.Error("Failed to execute research brief generation workflow", "error", err)
+			return "", &StageError{Stage: StageResearchBrief, Err: fmt.Errorf("error generating response: %w", err)}
+		}
+		s.State.ResearchBrief = resp.(string)
+		s.State.CompletedStage = StageResearchBrief
+		s.checkpoint()
+	}
+
+	// Workflow 3: Split the research brief into independent subtopics
+	if !s.State.completed(StageResearchPlan) {
+		stageCtx := s.enterStage(ctx, StageResearchPlan)
+		if _, _, err := s.workflows.researchSupervisor.Execute(stageCtx); err != nil {
+			if ctx.Err() != nil {
+				return "", &StageError{Stage: StageResearchPlan, Err: err}
+			}
+			// Planning is an optimization, so research the brief as a whole
+			s.logger.Warn("Failed to split research brief into subtopics", "error", err)
+			s.State.ResearchPlan = []workflows.Subtopic{{Title: "Research brief", Brief: s.State.ResearchBrief}}
+		}
+		s.State.Subtopics = newSubtopicResearch(s.State.ResearchPlan)
+		s.State.CompletedStage = StageResearchPlan
+		s.checkpoint()
+	}
+
+	// Workflow 4: Research every subtopic concurrently and merge their notes.
+	// Each iteration is checkpointed so a resumed session picks up every
+	// research conversation where it stopped.
+	if !s.State.completed(StageWebResearch) {
+		stageCtx := s.enterStage(ctx, StageWebResearch)
+		if err := s.webResearch(stageCtx); err != nil {
+			return "", err
+		}
+		s.State.CompressedResearchNotes = mergeSubtopicNotes(s.State.Subtopics)
+		s.State.CompletedStage = StageWebResearch
+		s.checkpoint()
+	}
+
+	// Workflow 5: Write research report based on all available information
+	if !s.State.completed(StageResearchReport) {
+		stageCtx := s.enterStage(ctx, StageResearchReport)
+		if _, _, err := s.workflows.researchReportGeneration.Execute(stageCtx); err != nil {
+			s.logger.Error("Failed to execute research report generation workflow", "error", err)
+			return "", &StageError{Stage: StageResearchReport, Err: fmt.Errorf("error generating response: %w", err)}
+		}
+		s.State.CompletedStage = StageResearchReport
+		s.State.CompletedAt = time.Now().UTC()
+		s.checkpoint()
+	}
+
+	s.enterStage(ctx, StageCompleted)
+	return s.State.Report.String(), nil
+}
+
+func (s *Session) budgetExhausted(ctx context.Context, logger *slog.Logger, reason string, consumed workflows.ResearchUsage) {
+	logger.Info("Research budget exhausted",
+		"reason", reason,
+		"search_calls", consumed.SearchCalls,
+		"iterations", consumed.Iterations,
+		"tokens", consumed.Tokens)
+	workflows.EmitProgress(ctx, workflows.ProgressEvent{
+		Type:    workflows.EventBudgetExhausted,
+		Message: fmt.Sprintf("Stopping research: %s", reason),
+		Data: map[string]any{
+			"reason":       reason,
+			"search_calls": consumed.SearchCalls,
+			"iterations":   consumed.Iterations,
+			"tokens":       consumed.Tokens,
+		},
+	})
+}
+
+// checkpoint saves the session state to the store. Failing to save is logged
+// but does not interrupt research. It is safe to call from sub-researchers.
+func (s *Session) checkpoint() {
+	if s.store == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Usage is owned by the tracker, which sub-researchers append to without
+	// holding s.mu, so it is copied from the tracker rather than from State
+	snapshot := State{
+		Conversation:            s.State.Conversation,
+		ResearchBrief:           s.State.ResearchBrief,
+		ResearchPlan:            s.State.ResearchPlan,
+		Subtopics:               s.State.Subtopics,
+		CompressedResearchNotes: s.State.CompressedResearchNotes,
+		Report:                  s.State.Report,
+		Usage:                   s.tracker.Records(),
+		PromptVersion:           s.State.PromptVersion,
+		ResearchElapsed:         s.State.ResearchElapsed,
+		CompletedStage:          s.State.CompletedStage,
+		CompletedAt:             s.State.CompletedAt,
+	}
+	if err := s.store.Save(s.ID, &snapshot); err != nil {
+		s.logger.Warn("Failed to checkpoint session", "error", err)
+	}
+}
+
+// Query returns the first message of the user, the question the session
+// researches.
+func (s *Session) Query() string {
+	for _, message := range s.State.Conversation {
+		if message.Role == openai.ChatMessageRoleUser {
+			return message.Content
+		}
+	}
+	return ""
+}
+
+// Usage summarizes the token usage and estimated cost of the session so far.
+func (s *Session) Usage() usage.Summary {
+	return s.tracker.Summary()
+}
+
+// sessionContext attaches the usage tracker, the prompts and OnProgress to
+// ctx, stamping every progress event with the stage the session is in.
+func (s *Session) sessionContext(ctx context.Context) context.Context {
+	ctx = usage.WithTracker(ctx, s.tracker)
+	ctx = workflows.WithPrompts(ctx, s.prompts)
+	if s.OnProgress == nil {
+		return ctx
+	}
+	return workflows.WithProgressReporter(ctx, func(event workflows.ProgressEvent) {
+		if event.Stage == "" {
+			event.Stage = s.stage
+		}
+		s.OnProgress(event)
+	})
+}
+
+// enterStage announces stage and returns a context that attributes model
+// usage to it.
+func (s *Session) enterStage(ctx context.Context, stage string) context.Context {
+	s.logger.Debug("Entering research stage", "stage", stage)
+	s.stage = stage
+	workflows.EmitProgress(ctx, workflows.ProgressEvent{
+		Type:    workflows.EventStageEntered,
+		Stage:   stage,
+		Message: fmt.Sprintf("Entering %s", stage),
+	})
+	return usage.WithStage(ctx, stage)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,214 @@
+package research
+
+import (
+	"context"
+	"deep-research/internal/cache"
+	"deep-research/internal/config"
+	"deep-research/internal/fakeapi"
+	"deep-research/internal/llm"
+	"deep-research/internal/tools"
+	"deep-research/internal/workflows"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeSession creates a session whose LLM and search calls go to the
+// fakes and whose checkpoints are written to a temporary directory.
+func newFakeSession(t *testing.T, chat *fakeapi.OpenAI, exa *fakeapi.Exa, overrides map[string]string) *Session {
+	t.Helper()
+	t.Setenv("OPENAI_BASE_URL", chat.URL())
+	t.Setenv("EXA_ENDPOINT", exa.URL())
+	return newTestSession(t, nil, overrides)
+}
+
+// newTestSession creates a session configured from overrides alone, sending
+// every HTTP call through base, whose checkpoints are written to a temporary
+// directory.
+func newTestSession(t *testing.T, base http.RoundTripper, overrides map[string]string) *Session {
+	t.Helper()
+	t.Setenv("DEEP_RESEARCH_CONFIG", "")
+	t.Setenv("DEEP_RESEARCH_PROFILE", "")
+	t.Setenv("OPENAI_API_KEY", "test")
+	t.Setenv("EXA_API_KEY", "test")
+	t.Setenv("SEARCH_PROVIDER", "exa")
+	t.Setenv("DEEP_RESEARCH_SESSION_DIR", t.TempDir())
+	t.Setenv("CACHE_DISABLED", "true")
+	t.Setenv("RETRY_MAX_RETRIES", "0")
+
+	cfg, err := config.Load(config.Options{Overrides: overrides})
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.Transport = base
+	responseCache, err := cache.New(cfg.Cache)
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	providers, err := llm.InitializeStageProviders(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("failed to initialize providers: %v", err)
+	}
+	searchProvider, err := tools.NewSearchProvider(cfg, responseCache)
+	if err != nil {
+		t.Fatalf("failed to initialize search provider: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	session, err := NewSession(cfg, providers, searchProvider, nil, responseCache, workflows.DefaultPrompts(), NewStore(cfg.SessionDir), logger)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	return session
+}
+
+// scriptSubtopics scripts a plan of one subtopic per query. Each subtopic is
+// researched with the given number of searches for its query, whose results
+// are summarized, and the report cites the first source.
+func scriptSubtopics(chat *fakeapi.OpenAI, exa *fakeapi.Exa, searches int, queries ...string) {
+	var plan []workflows.Subtopic
+	for _, query := range queries {
+		plan = append(plan, workflows.Subtopic{Title: query, Brief: "Research " + query})
+	}
+
+	// Schema rules come first since the brief, plan and report prompts
+	// mention every subtopic
+	chat.On(fakeapi.Schema("ResearchBriefGenerationOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"research_brief": "Research " + strings.Join(queries, " and ")}})
+	chat.On(fakeapi.Schema("ResearchSupervisorOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"subtopics": plan}})
+	chat.On(fakeapi.Schema("SummarizedResearchOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{"summary": "A summary.", "key_excerpts": "An excerpt."}})
+	chat.On(fakeapi.Schema("ResearchReportGenerationOutputSchema"),
+		fakeapi.ChatReply{JSON: map[string]any{
+			"report":    "# Report\n\nFindings [1].",
+			"citations": []map[string]int{{"marker": 1, "source_id": 1}},
+		}})
+
+	for _, query := range queries {
+		var replies []fakeapi.ChatReply
+		for i := range searches {
+			search := fmt.Sprintf("%s %d", query, i+1)
+			exa.On(fakeapi.QueryContains(search), fakeapi.SearchResult{
+				Title: search,
+				URL:   "https://example.com/" + strings.ReplaceAll(search, " ", "-"),
+				Text:  "Facts about " + search + ".",
+			})
+			replies = append(replies, fakeapi.ChatReply{ToolCalls: []fakeapi.ToolCall{{Name: "search_tool", Arguments: map[string]string{"query": search}}}})
+		}
+		replies = append(replies, fakeapi.ChatReply{Content: "Research complete."})
+		chat.On(fakeapi.All(fakeapi.Offers("search_tool"), fakeapi.Contains("Research "+query)), replies...)
+	}
+}
+
+// TestResearchSubtopicsConcurrently runs two sub-researchers that record
+// usage and checkpoint concurrently; run it with -race.
+func TestResearchSubtopicsConcurrently(t *testing.T) {
+	chat := fakeapi.NewOpenAI()
+	defer chat.Close()
+	exa := fakeapi.NewExa()
+	defer exa.Close()
+	scriptSubtopics(chat, exa, 8, "solar", "wind")
+
+	session := newFakeSession(t, chat, exa, map[string]string{"BUDGET_MAX_SUBTOPICS": "2"})
+	if err := session.AddUserMessage("Renewable energy"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Research(context.Background()); err != nil {
+		t.Fatalf("Research() error = %v", err)
+	}
+
+	if got := len(session.State.Subtopics); got != 2 {
+		t.Fatalf("researched %d subtopics, want 2", got)
+	}
+	for _, subtopic := range session.State.Subtopics {
+		if !subtopic.Done || len(subtopic.Notes) != 8 {
+			t.Errorf("subtopic done = %v with %d notes, want done with 8 notes", subtopic.Done, len(subtopic.Notes))
+		}
+	}
+
+	checkpoint, err := session.store.Load(session.ID)
+	if err != nil {
+		t.Fatalf("failed to load checkpoint: %v", err)
+	}
+	if got, want := len(checkpoint.State.Usage), len(chat.Requests()); got != want {
+		t.Errorf("checkpoint has %d usage records, want one per model call (%d)", got, want)
+	}
+}
+
+// TestResearchSubtopicsHoldsAgentsToTheirShare lets each sub-researcher ask
+// for more searches than its share of the budget.
+func TestResearchSubtopicsHoldsAgentsToTheirShare(t *testing.T) {
+	chat := fakeapi.NewOpenAI()
+	defer chat.Close()
+	exa := fakeapi.NewExa()
+	defer exa.Close()
+	scriptSubtopics(chat, exa, 8, "solar", "wind")
+
+	session := newFakeSession(t, chat, exa, map[string]string{
+		"BUDGET_MAX_SUBTOPICS":    "2",
+		"BUDGET_MAX_SEARCH_CALLS": "4",
+	})
+	if err := session.AddUserMessage("Renewable energy"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Research(context.Background()); err != nil {
+		t.Fatalf("Research() error = %v", err)
+	}
+
+	for i, subtopic := range session.State.Subtopics {
+		if subtopic.Usage.SearchCalls != 2 || len(subtopic.Notes) != 2 {
+			t.Errorf("subtopic %d ran %d searches with %d notes, want its share of 2", i, subtopic.Usage.SearchCalls, len(subtopic.Notes))
+		}
+	}
+	if got := len(exa.Queries()); got != 4 {
+		t.Errorf("ran %d searches, want 4", got)
+	}
+}
+
+// TestWebResearchCountsTimeSpentBeforeResume resumes web research whose time
+// limit was used up before the session was interrupted.
+func TestWebResearchCountsTimeSpentBeforeResume(t *testing.T) {
+	chat := fakeapi.NewOpenAI()
+	defer chat.Close()
+	exa := fakeapi.NewExa()
+	defer exa.Close()
+	scriptSubtopics(chat, exa, 1, "solar")
+
+	session := newFakeSession(t, chat, exa, map[string]string{"BUDGET_MAX_DURATION": "1m"})
+	if err := session.AddUserMessage("Solar power"); err != nil {
+		t.Fatal(err)
+	}
+	// A checkpoint taken after a minute of research on the only subtopic
+	session.State.ResearchBrief = "Research solar"
+	session.State.ResearchPlan = []workflows.Subtopic{{Title: "solar", Brief: "Research solar"}}
+	session.State.Subtopics = newSubtopicResearch(session.State.ResearchPlan)
+	session.State.Subtopics[0].Notes = []workflows.ResearchNote{{
+		Source:  tools.Source{Title: "solar 1", URL: "https://example.com/solar-1"},
+		Summary: "A summary.",
+	}}
+	session.State.ResearchElapsed = time.Minute
+	session.State.CompletedStage = StageResearchPlan
+
+	if _, err := session.Research(context.Background()); err != nil {
+		t.Fatalf("Research() error = %v", err)
+	}
+	for _, req := range chat.Requests() {
+		if fakeapi.Offers("search_tool")(req) {
+			t.Fatal("research agent ran after the time limit was used up")
+		}
+	}
+	if !session.State.Subtopics[0].Done {
+		t.Error("subtopic not marked done")
+	}
+	checkpoint, err := session.store.Load(session.ID)
+	if err != nil {
+		t.Fatalf("failed to load checkpoint: %v", err)
+	}
+	if checkpoint.State.ResearchElapsed < time.Minute {
+		t.Errorf("checkpointed research time = %s, want at least 1m", checkpoint.State.ResearchElapsed)
+	}
+}
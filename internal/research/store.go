@@ -0,0 +1,96 @@
+package research
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// stateFileName is the name of the checkpoint file inside a session directory.
+const stateFileName = "state.json"
+
+// ErrSessionNotFound is returned when no checkpoint exists for a session id.
+var ErrSessionNotFound = errors.New("session not found")
+
+var sessionIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Checkpoint is the persisted form of a session.
+type Checkpoint struct {
+	ID        string    `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	State     State     `json:"state"`
+}
+
+// Store persists session checkpoints as JSON files, one directory per session.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save writes the checkpoint for a session, replacing any previous one.
+// The file is written atomically so a crash never leaves a partial checkpoint.
+func (s *Store) Save(id string, state *State) error {
+	if !sessionIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid session id %q", id)
+	}
+
+	sessionDir := filepath.Join(s.dir, id)
+	if err := os.MkdirAll(sessionDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	payload, err := json.MarshalIndent(Checkpoint{
+		ID:        id,
+		UpdatedAt: time.Now().UTC(),
+		State:     *state,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(sessionDir, stateFileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(payload); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(sessionDir, stateFileName)); err != nil {
+		return fmt.Errorf("failed to replace checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Load reads the checkpoint of a session.
+func (s *Store) Load(id string) (*Checkpoint, error) {
+	if !sessionIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid session id %q", id)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(s.dir, id, stateFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(raw, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	return &checkpoint, nil
+}
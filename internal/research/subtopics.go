@@ -0,0 +1,154 @@
+package research
+
+import (
+	"context"
+	"deep-research/internal/workflows"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// SubtopicResearch is the work of the research agent assigned to one
+// subtopic of the research plan.
+type SubtopicResearch struct {
+	Conversation []openai.ChatCompletionMessage `json:"conversation"`
+	Notes        []workflows.ResearchNote       `json:"notes"`
+	Usage        workflows.ResearchUsage        `json:"usage"`
+	// Done is set once the agent has finished, so resuming skips it
+	Done bool `json:"done"`
+}
+
+// newSubtopicResearch starts a conversation for every subtopic of plan.
+func newSubtopicResearch(plan []workflows.Subtopic) []SubtopicResearch {
+	subtopics := make([]SubtopicResearch, len(plan))
+	for i, subtopic := range plan {
+		subtopics[i] = SubtopicResearch{
+			Conversation: []openai.ChatCompletionMessage{
+				{
+					Role:    openai.ChatMessageRoleUser,
+					Content: subtopic.Brief,
+				},
+			},
+			Notes: make([]workflows.ResearchNote, 0),
+		}
+	}
+	return subtopics
+}
+
+// mergeSubtopicNotes combines the notes of every subtopic, in plan order.
+func mergeSubtopicNotes(subtopics []SubtopicResearch) []workflows.ResearchNote {
+	notes := make([]workflows.ResearchNote, 0)
+	for _, subtopic := range subtopics {
+		notes = append(notes, subtopic.Notes...)
+	}
+	return notes
+}
+
+// researchUsage totals the budget consumed by every subtopic so far.
+func researchUsage(subtopics []SubtopicResearch) workflows.ResearchUsage {
+	var total workflows.ResearchUsage
+	for _, subtopic := range subtopics {
+		total.SearchCalls += subtopic.Usage.SearchCalls
+		total.Iterations += subtopic.Usage.Iterations
+		total.Tokens += subtopic.Usage.Tokens
+	}
+	return total
+}
+
+// webResearch runs one research agent per unfinished subtopic concurrently.
+// Each agent is held to its share of a single budget, whose limits also hold
+// for the session as a whole. It fails only when no agent collected any notes to base a report on.
+func (s *Session) webResearch(ctx context.Context) error {
+	// Time spent before the session was resumed counts against the limit
+	started := time.Now().Add(-s.State.ResearchElapsed)
+	researchCtx := ctx
+	if s.budget.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		researchCtx, cancel = context.WithDeadline(ctx, started.Add(s.budget.MaxDuration))
+		defer cancel()
+	}
+
+	budget := workflows.NewSharedBudget(s.budget, len(s.State.Subtopics), researchUsage(s.State.Subtopics))
+	var wg sync.WaitGroup
+	errs := make([]error, len(s.State.Subtopics))
+	for i := range s.State.Subtopics {
+		if s.State.Subtopics[i].Done {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.researchSubtopic(ctx, researchCtx, i, budget, started)
+		}(i)
+	}
+	wg.Wait()
+
+	// An interrupted session must not be marked as researched
+	if ctx.Err() != nil {
+		return &StageError{Stage: StageWebResearch, Err: fmt.Errorf("error conducting web research: %w", ctx.Err())}
+	}
+	if len(mergeSubtopicNotes(s.State.Subtopics)) == 0 {
+		return &StageError{Stage: StageWebResearch, Err: fmt.Errorf("error conducting web research: %w", errors.Join(errs...))}
+	}
+	return nil
+}
+
+// researchSubtopic runs the research loop of the i-th subtopic until the
+// agent stops searching or it or the session exhausts the budget. Hitting a limit ends
+// research cleanly so the report is still written from the notes collected
+// so far. The agent works on its own copy of the subtopic state and
+// publishes it to the session after every iteration.
+func (s *Session) researchSubtopic(ctx, researchCtx context.Context, i int, budget *workflows.SharedBudget, started time.Time) error {
+	subtopic := s.State.ResearchPlan[i]
+	s.mu.Lock()
+	research := s.State.Subtopics[i]
+	s.mu.Unlock()
+
+	logger := s.logger.With("subtopic", subtopic.Title)
+	if len(s.State.Subtopics) > 1 {
+		ctx = workflows.WithProgressData(ctx, "subtopic", subtopic.Title)
+		researchCtx = workflows.WithProgressData(researchCtx, "subtopic", subtopic.Title)
+	}
+	agent := s.workflows.newWebResearch(&research.Conversation, &research.Notes, &research.Usage, budget, logger)
+
+	publish := func(done bool) {
+		research.Done = done
+		s.mu.Lock()
+		s.State.Subtopics[i] = research
+		s.State.ResearchElapsed = time.Since(started)
+		s.mu.Unlock()
+		s.checkpoint()
+	}
+
+	for {
+		if reason, exhausted := budget.StartIteration(research.Usage, time.Since(started)); exhausted {
+			s.budgetExhausted(ctx, logger, reason, budget.Used())
+			publish(true)
+			return nil
+		}
+		research.Usage.Iterations++
+
+		_, cont, err := agent.Execute(researchCtx)
+		if err != nil {
+			// The time limit can expire in the middle of an iteration
+			if ctx.Err() == nil && errors.Is(researchCtx.Err(), context.DeadlineExceeded) && len(research.Notes) > 0 {
+				s.budgetExhausted(ctx, logger, fmt.Sprintf("time limit of %s reached", s.budget.MaxDuration), budget.Used())
+				publish(true)
+				return nil
+			}
+			logger.Error("Failed to execute web research workflow", "error", err)
+			// Keep the notes collected so far, but let a resumed session retry
+			// a subtopic that produced nothing
+			publish(len(research.Notes) > 0 && ctx.Err() == nil)
+			return fmt.Errorf("%s: %w", subtopic.Title, err)
+		}
+		if !cont {
+			publish(true)
+			return nil
+		}
+		publish(false)
+	}
+}
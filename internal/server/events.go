@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// handleJobEvents streams the progress events of a job as Server-Sent Events.
+// Past events are replayed first, so clients can connect at any time. A
+// client reconnecting with Last-Event-ID resumes after that event. Jobs keep
+// only their latest events, so when older ones were dropped the stream
+// starts with a "truncated" event carrying the number of dropped events and
+// the current job. The stream ends with a "done" event carrying the final job
+// once the job finishes.
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.jobs.Get(id); !ok {
+		s.writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	next := 0
+	if lastID, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil {
+		next = lastID + 1
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		events, ok := s.jobs.Events(id, next)
+		if !ok {
+			return
+		}
+		if events.First > next {
+			truncated := truncatedEvent{Dropped: events.First - next, Job: events.Job}
+			if err := writeSSE(w, "", "truncated", truncated); err != nil {
+				return
+			}
+			next = events.First
+		}
+		for _, event := range events.Events {
+			if err := writeSSE(w, strconv.Itoa(next), "progress", event); err != nil {
+				return
+			}
+			next++
+		}
+		if events.Job.finished() {
+			_ = writeSSE(w, "", "done", events.Job)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-events.Changed:
+		}
+	}
+}
+
+// truncatedEvent tells a subscriber that events it asked for were dropped.
+type truncatedEvent struct {
+	Dropped int `json:"dropped"`
+	Job     Job `json:"job"`
+}
+
+func writeSSE(w http.ResponseWriter, id, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
@@ -0,0 +1,374 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"deep-research/internal/research"
+	"deep-research/internal/usage"
+	"deep-research/internal/workflows"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+// SessionFactory creates a fresh research session for a job.
+type SessionFactory func(logger *slog.Logger) (*research.Session, error)
+
+// Job is a single research request submitted through the API.
+type Job struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	Query     string    `json:"query"`
+	Status    JobStatus `json:"status"`
+	Stage     string    `json:"stage,omitempty"`
+	Report    string    `json:"report,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	// PromptVersion identifies the prompts the job is researched with
+	PromptVersion string `json:"prompt_version,omitempty"`
+	// Usage is the token usage and estimated cost, set once the job finishes
+	Usage     *usage.Summary `json:"usage,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	// FinishedAt is when the job succeeded, failed or was cancelled
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+
+	cancel context.CancelFunc
+	events eventLog
+	// notify is closed and replaced whenever the job changes, waking up
+	// event stream subscribers
+	notify chan struct{}
+}
+
+// maxJobEvents is how many of the latest progress events a job keeps for
+// event stream subscribers.
+const maxJobEvents = 1000
+
+// eventLog keeps the latest progress events of a job in a ring buffer. Events
+// are numbered in the order they were added, starting at 0.
+type eventLog struct {
+	limit  int
+	events []workflows.ProgressEvent
+	// total is the number of events added so far
+	total int
+}
+
+func (l *eventLog) add(event workflows.ProgressEvent) {
+	if len(l.events) < l.limit {
+		l.events = append(l.events, event)
+	} else {
+		l.events[l.total%l.limit] = event
+	}
+	l.total++
+}
+
+// since returns the kept events numbered from on, and the number of the
+// first one, which is past from when older events were dropped.
+func (l *eventLog) since(from int) ([]workflows.ProgressEvent, int) {
+	first := max(from, l.total-len(l.events), 0)
+	var events []workflows.ProgressEvent
+	for n := first; n < l.total; n++ {
+		events = append(events, l.events[n%l.limit])
+	}
+	return events, first
+}
+
+func (j *Job) finished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
+
+// JobRetention bounds how many finished jobs a JobManager keeps and for how
+// long. Running jobs are never evicted. Zero values disable the limit.
+type JobRetention struct {
+	// TTL is how long a job is kept after it finishes
+	TTL time.Duration
+	// MaxFinished is how many finished jobs are kept, oldest evicted first
+	MaxFinished int
+}
+
+// DefaultJobRetention keeps finished jobs for a day, up to 100 of them.
+var DefaultJobRetention = JobRetention{TTL: 24 * time.Hour, MaxFinished: 100}
+
+// evictionInterval is how often expired jobs are swept.
+const evictionInterval = time.Minute
+
+// JobManager runs research jobs in the background and tracks their progress.
+type JobManager struct {
+	mu         sync.RWMutex
+	jobs       map[string]*Job
+	newSession SessionFactory
+	retention  JobRetention
+	logger     *slog.Logger
+	ctx        context.Context
+	wg         sync.WaitGroup
+}
+
+// NewJobManager creates a job manager that evicts finished jobs according to
+// retention. Cancelling ctx cancels every running job.
+func NewJobManager(ctx context.Context, newSession SessionFactory, retention JobRetention, logger *slog.Logger) *JobManager {
+	m := &JobManager{
+		jobs:       make(map[string]*Job),
+		newSession: newSession,
+		retention:  retention,
+		logger:     logger,
+		ctx:        ctx,
+	}
+	if retention.TTL > 0 {
+		go m.sweep()
+	}
+	return m
+}
+
+// Create registers a job for query and starts researching it in the background.
+func (m *JobManager) Create(query string) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, fmt.Errorf("failed to generate job id: %w", err)
+	}
+
+	logger := m.logger.With("job_id", id)
+	session, err := m.newSession(logger)
+	if err != nil {
+		return Job{}, fmt.Errorf("failed to create research session: %w", err)
+	}
+	if err := session.AddUserMessage(query); err != nil {
+		return Job{}, err
+	}
+	session.SkipClarification()
+
+	jobCtx, cancel := context.WithCancel(m.ctx)
+	now := time.Now().UTC()
+	job := &Job{
+		ID:            id,
+		SessionID:     session.ID,
+		Query:         query,
+		Status:        JobStatusQueued,
+		PromptVersion: session.State.PromptVersion,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		cancel:        cancel,
+		events:        eventLog{limit: maxJobEvents},
+		notify:        make(chan struct{}),
+	}
+	session.OnProgress = func(event workflows.ProgressEvent) {
+		m.update(id, func(j *Job) {
+			j.events.add(event)
+			if event.Type == workflows.EventStageEntered {
+				j.Stage = event.Stage
+			}
+		})
+	}
+
+	m.mu.Lock()
+	m.jobs[id] = job
+	snapshot := *job
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.run(jobCtx, job.ID, session, logger)
+
+	return snapshot, nil
+}
+
+func (m *JobManager) run(ctx context.Context, id string, session *research.Session, logger *slog.Logger) {
+	defer m.wg.Done()
+
+	m.update(id, func(j *Job) {
+		j.Status = JobStatusRunning
+	})
+	logger.Info("Research job started")
+
+	report, err := session.Research(ctx)
+	cancelled := errors.Is(err, context.Canceled) || ctx.Err() != nil
+	summary := session.Usage()
+	m.update(id, func(j *Job) {
+		// Release the job context now that research has finished
+		j.cancel()
+		j.Usage = &summary
+		switch {
+		case err == nil:
+			j.Status = JobStatusSucceeded
+			j.Report = report
+		case cancelled:
+			j.Status = JobStatusCancelled
+			j.Error = "job was cancelled"
+		default:
+			j.Status = JobStatusFailed
+			j.Error = err.Error()
+		}
+		j.FinishedAt = time.Now().UTC()
+	})
+	logger.Info("Research job finished", "error", err)
+	m.evict(time.Now().UTC())
+}
+
+// Get returns a snapshot of the job with the given id.
+func (m *JobManager) Get(id string) (Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns snapshots of all jobs, newest first.
+func (m *JobManager) List() []Job {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// Cancel stops a running job. Cancelling a finished job is a no-op.
+func (m *JobManager) Cancel(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	if !job.finished() {
+		job.cancel()
+	}
+	return *job, true
+}
+
+// Delete removes a job, cancelling it first if it is still running. Event
+// stream subscribers of the job are released.
+func (m *JobManager) Delete(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return false
+	}
+	if !job.finished() {
+		job.cancel()
+	}
+	delete(m.jobs, id)
+	close(job.notify)
+	return true
+}
+
+// JobEvents are the progress events of a job from a given event number on.
+type JobEvents struct {
+	Events []workflows.ProgressEvent
+	// First is the number of the first event, past the requested one when
+	// older events were dropped from the job's buffer
+	First int
+	// Job is a snapshot of the job
+	Job Job
+	// Changed is closed when the job next changes
+	Changed <-chan struct{}
+}
+
+// Events returns the kept progress events of a job numbered from on, and
+// whether the job exists.
+func (m *JobManager) Events(id string, from int) (JobEvents, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return JobEvents{}, false
+	}
+	events, first := job.events.since(from)
+	return JobEvents{Events: events, First: first, Job: *job, Changed: job.notify}, true
+}
+
+// Wait blocks until every job has finished.
+func (m *JobManager) Wait() {
+	m.wg.Wait()
+}
+
+func (m *JobManager) update(id string, fn func(j *Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job, ok := m.jobs[id]; ok {
+		fn(job)
+		job.UpdatedAt = time.Now().UTC()
+		close(job.notify)
+		job.notify = make(chan struct{})
+	}
+}
+
+// sweep evicts expired jobs periodically until the manager's context is
+// cancelled.
+func (m *JobManager) sweep() {
+	ticker := time.NewTicker(min(m.retention.TTL, evictionInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.evict(now.UTC())
+		}
+	}
+}
+
+// evict removes finished jobs that are older than the retention TTL and,
+// beyond the retention count, the oldest finished jobs.
+func (m *JobManager) evict(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var finished []*Job
+	for id, job := range m.jobs {
+		if !job.finished() {
+			continue
+		}
+		if m.retention.TTL > 0 && now.Sub(job.FinishedAt) >= m.retention.TTL {
+			delete(m.jobs, id)
+			close(job.notify)
+			continue
+		}
+		finished = append(finished, job)
+	}
+
+	if m.retention.MaxFinished <= 0 || len(finished) <= m.retention.MaxFinished {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(finished[j].FinishedAt)
+	})
+	for _, job := range finished[:len(finished)-m.retention.MaxFinished] {
+		delete(m.jobs, job.ID)
+		close(job.notify)
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
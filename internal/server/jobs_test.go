@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"deep-research/internal/workflows"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestJob(id string, status JobStatus, finishedAt time.Time) *Job {
+	_, cancel := context.WithCancel(context.Background())
+	return &Job{ID: id, Status: status, FinishedAt: finishedAt, cancel: cancel, events: eventLog{limit: maxJobEvents}, notify: make(chan struct{})}
+}
+
+func TestJobManagerEvict(t *testing.T) {
+	now := time.Now().UTC()
+	m := NewJobManager(context.Background(), nil, JobRetention{TTL: time.Hour, MaxFinished: 2}, slog.Default())
+	expired := newTestJob("expired", JobStatusSucceeded, now.Add(-2*time.Hour))
+	m.jobs = map[string]*Job{
+		"running": newTestJob("running", JobStatusRunning, time.Time{}),
+		"expired": expired,
+		"oldest":  newTestJob("oldest", JobStatusFailed, now.Add(-30*time.Minute)),
+		"older":   newTestJob("older", JobStatusCancelled, now.Add(-20*time.Minute)),
+		"newest":  newTestJob("newest", JobStatusSucceeded, now.Add(-10*time.Minute)),
+	}
+
+	m.evict(now)
+
+	for _, id := range []string{"running", "older", "newest"} {
+		if _, ok := m.Get(id); !ok {
+			t.Errorf("job %q was evicted", id)
+		}
+	}
+	for _, id := range []string{"expired", "oldest"} {
+		if _, ok := m.Get(id); ok {
+			t.Errorf("job %q was kept", id)
+		}
+	}
+	select {
+	case <-expired.notify:
+	default:
+		t.Error("subscribers of an evicted job were not released")
+	}
+}
+
+func TestJobManagerDelete(t *testing.T) {
+	m := NewJobManager(context.Background(), nil, JobRetention{}, slog.Default())
+	ctx, cancel := context.WithCancel(context.Background())
+	job := newTestJob("running", JobStatusRunning, time.Time{})
+	job.cancel = cancel
+	m.jobs = map[string]*Job{"running": job}
+
+	if !m.Delete("running") {
+		t.Fatal("Delete returned false for an existing job")
+	}
+	if ctx.Err() == nil {
+		t.Error("deleting a running job did not cancel it")
+	}
+	if _, ok := m.Get("running"); ok {
+		t.Error("job still listed after Delete")
+	}
+	if m.Delete("running") {
+		t.Error("Delete returned true for a missing job")
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	log := eventLog{limit: 3}
+	for i := range 5 {
+		log.add(workflows.ProgressEvent{Message: fmt.Sprint(i)})
+	}
+
+	tests := []struct {
+		from      int
+		want      []string
+		wantFirst int
+	}{
+		{from: 0, want: []string{"2", "3", "4"}, wantFirst: 2},
+		{from: 3, want: []string{"3", "4"}, wantFirst: 3},
+		{from: 5, wantFirst: 5},
+	}
+	for _, tt := range tests {
+		events, first := log.since(tt.from)
+		var got []string
+		for _, event := range events {
+			got = append(got, event.Message)
+		}
+		if !reflect.DeepEqual(got, tt.want) || first != tt.wantFirst {
+			t.Errorf("since(%d) = %v, %d, want %v, %d", tt.from, got, first, tt.want, tt.wantFirst)
+		}
+	}
+}
+
+func TestJobEventsStreamTruncated(t *testing.T) {
+	m := NewJobManager(context.Background(), nil, JobRetention{}, slog.Default())
+	job := newTestJob("job", JobStatusSucceeded, time.Now().UTC())
+	job.Stage = "final_report_generation"
+	job.events = eventLog{limit: 3}
+	for i := range 5 {
+		job.events.add(workflows.ProgressEvent{Message: fmt.Sprintf("event %d", i)})
+	}
+	m.jobs = map[string]*Job{"job": job}
+	server := New(m, slog.Default())
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        []string
+		wantMissing []string
+	}{
+		{
+			name: "late subscriber",
+			want: []string{
+				"event: truncated\ndata: {\"dropped\":2,\"job\":{\"id\":\"job\"",
+				"id: 2\nevent: progress", "id: 4\nevent: progress", "event: done",
+			},
+			wantMissing: []string{"event 1"},
+		},
+		{
+			name:        "reconnect within the buffer",
+			lastEventID: "2",
+			want:        []string{"id: 3\nevent: progress", "id: 4\nevent: progress", "event: done"},
+			wantMissing: []string{"event: truncated", "event 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/jobs/job/events", nil)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			body := rec.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("stream lacks %q:\n%s", want, body)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(body, missing) {
+					t.Errorf("stream contains %q:\n%s", missing, body)
+				}
+			}
+		})
+	}
+}
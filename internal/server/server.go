@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Server exposes research jobs over a REST API:
+//
+//	POST   /jobs              create a job from {"query": "..."}
+//	GET    /jobs              list jobs
+//	GET    /jobs/{id}         get the status, stage and report of a job
+//	GET    /jobs/{id}/events  stream progress events as Server-Sent Events
+//	POST   /jobs/{id}/cancel  cancel a job
+//	DELETE /jobs/{id}         cancel a job if it is running and remove it
+type Server struct {
+	jobs   *JobManager
+	logger *slog.Logger
+	mux    *http.ServeMux
+}
+
+type createJobRequest struct {
+	Query string `json:"query"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func New(jobs *JobManager, logger *slog.Logger) *Server {
+	s := &Server{
+		jobs:   jobs,
+		logger: logger,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /jobs", s.handleCreateJob)
+	s.mux.HandleFunc("GET /jobs", s.handleListJobs)
+	s.mux.HandleFunc("GET /jobs/{id}", s.handleGetJob)
+	s.mux.HandleFunc("GET /jobs/{id}/events", s.handleJobEvents)
+	s.mux.HandleFunc("POST /jobs/{id}/cancel", s.handleCancelJob)
+	s.mux.HandleFunc("DELETE /jobs/{id}", s.handleDeleteJob)
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	var req createJobRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		s.writeError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	job, err := s.jobs.Create(req.Query)
+	if err != nil {
+		s.logger.Error("Failed to create research job", "error", err)
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	s.writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, s.jobs.List())
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.Get(r.PathValue("id"))
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.Cancel(r.PathValue("id"))
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	s.writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) handleDeleteJob(w http.ResponseWriter, r *http.Request) {
+	if !s.jobs.Delete(r.PathValue("id")) {
+		s.writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error("Failed to write response", "error", err)
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, message string) {
+	s.writeJSON(w, status, errorResponse{Error: message})
+}
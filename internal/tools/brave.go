@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"context"
+	"deep-research/internal/config"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type braveClient struct {
+	client     *http.Client
+	endpoint   string
+	apiKey     string
+	numResults int
+}
+
+type braveSearchResponse struct {
+	Web struct {
+		Results []braveIndividualSearchResult `json:"results"`
+	} `json:"web"`
+}
+
+type braveIndividualSearchResult struct {
+	Title         string   `json:"title"`
+	Url           string   `json:"url"`
+	Description   string   `json:"description"`
+	Age           string   `json:"age"`
+	PageAge       string   `json:"page_age"`
+	ExtraSnippets []string `json:"extra_snippets"`
+}
+
+func newBraveClient(cfg *config.Config, client *http.Client) *braveClient {
+	return &braveClient{
+		client:     client,
+		endpoint:   cfg.BraveEndpoint,
+		apiKey:     cfg.BraveKey,
+		numResults: cfg.SearchNumResults,
+	}
+}
+
+func (b *braveClient) Search(ctx context.Context, query string) ([]Source, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	// Brave caps the page size at 20 results
+	params.Set("count", strconv.Itoa(min(b.numResults, 20)))
+	params.Set("extra_snippets", "true")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", b.endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return []Source{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Subscription-Token", b.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	var searchResult braveSearchResponse
+	if err := sendSearchRequest(b.client, req, &searchResult); err != nil {
+		return []Source{}, err
+	}
+
+	// Brave only returns snippets, so combine them into a single document per result
+	var sources []Source
+	for _, result := range searchResult.Web.Results {
+		parts := append([]string{result.Title, result.Description}, result.ExtraSnippets...)
+		sources = append(sources, Source{
+			Title:         result.Title,
+			URL:           result.Url,
+			PublishedDate: result.PageAge,
+			Text:          strings.Join(parts, "\n"),
+		})
+	}
+	return sources, nil
+}
@@ -0,0 +1,250 @@
+package tools
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+const (
+	mediaTypePDF  = "application/pdf"
+	mediaTypeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+// documentExtensions maps the file extensions of supported document formats
+// to their media types, for servers that do not send a useful content type.
+var documentExtensions = map[string]string{
+	".pdf":  mediaTypePDF,
+	".docx": mediaTypeDOCX,
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+}
+
+// IsDocumentURL reports whether rawURL points to a PDF, DOCX or plain text file.
+func IsDocumentURL(rawURL string) bool {
+	_, ok := mediaTypeFromURL(rawURL)
+	return ok
+}
+
+func mediaTypeFromURL(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	mediaType, ok := documentExtensions[strings.ToLower(path.Ext(u.Path))]
+	return mediaType, ok
+}
+
+// ReadDocuments makes every source readable before summarization. Sources
+// that link to a PDF, DOCX or text file without usable text are downloaded
+// and extracted concurrently. Sources that remain unreadable are dropped and
+// returned alongside the readable sources.
+func (f *URLFetcher) ReadDocuments(ctx context.Context, sources []Source) (readable, dropped []Source) {
+	resolved := make([]Source, len(sources))
+	ok := make([]bool, len(sources))
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		readable := strings.TrimSpace(source.Text) != "" && !LooksBinary(source.Text)
+		if readable || !IsDocumentURL(source.URL) {
+			resolved[i], ok[i] = source, readable
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, source Source) {
+			defer wg.Done()
+			document, err := f.Fetch(ctx, source.URL)
+			if err != nil {
+				return
+			}
+			// Keep the search metadata, which is often better than the file's
+			source.Text = document.Text
+			if source.Title == "" {
+				source.Title = document.Title
+			}
+			if source.PublishedDate == "" {
+				source.PublishedDate = document.PublishedDate
+			}
+			resolved[i], ok[i] = source, true
+		}(i, source)
+	}
+	wg.Wait()
+
+	readable = make([]Source, 0, len(sources))
+	for i, source := range resolved {
+		if ok[i] {
+			readable = append(readable, source)
+		} else {
+			dropped = append(dropped, sources[i])
+		}
+	}
+	return readable, dropped
+}
+
+// LooksBinary reports whether text is undecoded binary content, such as the
+// raw bytes of a PDF, rather than readable text.
+func LooksBinary(text string) bool {
+	if strings.HasPrefix(text, "%PDF-") || strings.HasPrefix(text, "PK\x03\x04") {
+		return true
+	}
+	if !utf8.ValidString(text) {
+		return true
+	}
+
+	sample := text
+	if len(sample) > 4096 {
+		sample = sample[:4096]
+	}
+	control := 0
+	total := 0
+	for _, r := range sample {
+		total++
+		if r == utf8.RuneError || (unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t') {
+			control++
+		}
+	}
+	return total > 0 && control*10 > total
+}
+
+// extractPDF returns the title and plain text of a PDF document. Pages that
+// cannot be decoded are skipped.
+func extractPDF(body []byte) (title string, text string, err error) {
+	title, pages, err := extractPDFPages(body)
+	if err != nil {
+		return "", "", err
+	}
+	return title, normalizeText(strings.Join(pages, "\n\n")), nil
+}
+
+// extractPDFPages returns the title of a PDF document and the plain text of
+// each of its pages. Pages that cannot be decoded are left empty.
+func extractPDFPages(body []byte) (title string, pages []string, err error) {
+	// The PDF parser panics on some malformed documents
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to parse PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse PDF: %w", err)
+	}
+	title = strings.TrimSpace(reader.Trailer().Key("Info").Key("Title").Text())
+
+	pages = make([]string, reader.NumPage())
+	for i := range pages {
+		pageText, err := pdfPageText(reader.Page(i + 1))
+		if err != nil {
+			continue
+		}
+		pages[i] = pageText
+	}
+	return title, pages, nil
+}
+
+func pdfPageText(page pdf.Page) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read PDF page: %v", r)
+		}
+	}()
+	if page.V.IsNull() {
+		return "", errors.New("missing page")
+	}
+	return page.GetPlainText(nil)
+}
+
+// extractDOCX returns the title and text of a Word document by reading the
+// paragraphs of word/document.xml.
+func extractDOCX(body []byte) (title string, text string, err error) {
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open DOCX: %w", err)
+	}
+
+	for _, file := range archive.File {
+		switch file.Name {
+		case "word/document.xml":
+			text, err = readZipXML(file, docxText)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to read DOCX: %w", err)
+			}
+		case "docProps/core.xml":
+			// The title is optional, so a malformed core.xml is ignored
+			title, _ = readZipXML(file, docxTitle)
+		}
+	}
+	return title, normalizeText(text), nil
+}
+
+func readZipXML(file *zip.File, read func(*xml.Decoder) (string, error)) (string, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return read(xml.NewDecoder(io.LimitReader(rc, maxFetchBytes)))
+}
+
+// docxText collects the text runs of a WordprocessingML document, with one
+// line per paragraph.
+func docxText(decoder *xml.Decoder) (string, error) {
+	var b strings.Builder
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return b.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteString("\t")
+			case "br", "cr":
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteString("\n\n")
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+}
+
+func docxTitle(decoder *xml.Decoder) (string, error) {
+	var core struct {
+		Title string `xml:"title"`
+	}
+	if err := decoder.Decode(&core); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(core.Title), nil
+}
@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readPDFFixture returns a one-page PDF titled "Solar power".
+func readPDFFixture(t *testing.T) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", "solar.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// buildDOCX returns a Word document with the given title and paragraphs.
+func buildDOCX(t *testing.T, title string, paragraphs ...string) []byte {
+	t.Helper()
+	var document strings.Builder
+	document.WriteString(`<?xml version="1.0" encoding="UTF-8"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`)
+	for _, p := range paragraphs {
+		document.WriteString(`<w:p><w:r><w:t>` + p + `</w:t></w:r></w:p>`)
+	}
+	document.WriteString(`</w:body></w:document>`)
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := map[string]string{
+		"word/document.xml": document.String(),
+		"docProps/core.xml": `<?xml version="1.0" encoding="UTF-8"?><cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>` + title + `</dc:title></cp:coreProperties>`,
+	}
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractDocument(t *testing.T) {
+	pdf := readPDFFixture(t)
+	docx := buildDOCX(t, "Wind power", "Wind turbines convert wind into electricity.", "They work best offshore.")
+
+	tests := []struct {
+		name        string
+		contentType string
+		url         string
+		body        []byte
+		wantTitle   string
+		wantText    string
+		wantErr     string
+	}{
+		{name: "pdf", contentType: "application/pdf", url: "https://example.com/report", body: pdf, wantTitle: "Solar power", wantText: "Solar panels convert sunlight into electricity."},
+		{name: "pdf alias", contentType: "application/x-pdf", url: "https://example.com/report", body: pdf, wantTitle: "Solar power", wantText: "Solar panels convert sunlight"},
+		{name: "pdf by extension", contentType: "application/octet-stream", url: "https://example.com/report.PDF", body: pdf, wantTitle: "Solar power", wantText: "Solar panels convert sunlight"},
+		{name: "pdf sniffed from content", contentType: "", url: "https://example.com/download?id=1", body: pdf, wantTitle: "Solar power", wantText: "Solar panels convert sunlight"},
+		{name: "docx", contentType: mediaTypeDOCX, url: "https://example.com/doc", body: docx, wantTitle: "Wind power", wantText: "Wind turbines convert wind into electricity.\n\nThey work best offshore."},
+		{name: "docx by extension", contentType: "binary/octet-stream", url: "https://example.com/wind.docx", body: docx, wantTitle: "Wind power", wantText: "They work best offshore."},
+		{name: "text by extension", contentType: "application/octet-stream", url: "https://example.com/notes.txt", body: []byte("Plain notes."), wantText: "Plain notes."},
+		{name: "html sniffed from content", contentType: "application/octet-stream", url: "https://example.com/page", body: []byte("<html><head><title>Tides</title></head><body><p>Tidal energy is predictable.</p></body></html>"), wantTitle: "Tides", wantText: "Tidal energy is predictable."},
+		{name: "binary text", contentType: "text/plain", url: "https://example.com/notes", body: []byte("\x00\x01\x02\x03\x04\x05\x06\x07"), wantErr: "not readable text"},
+		{name: "corrupt pdf", contentType: "application/pdf", url: "https://example.com/report.pdf", body: []byte("%PDF-1.4\nnot really a pdf"), wantErr: "failed to parse PDF"},
+		{name: "corrupt docx", contentType: mediaTypeDOCX, url: "https://example.com/wind.docx", body: []byte("PK not a zip"), wantErr: "failed to open DOCX"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := extractDocument(tt.contentType, tt.url, tt.body)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("extractDocument() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractDocument() error = %v", err)
+			}
+			if source.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", source.Title, tt.wantTitle)
+			}
+			if !strings.Contains(source.Text, tt.wantText) {
+				t.Errorf("Text = %q, want it to contain %q", source.Text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestReadDocuments(t *testing.T) {
+	pdf := readPDFFixture(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/report.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(pdf)
+	})
+	mux.HandleFunc("/missing.pdf", http.NotFound)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	sources := []Source{
+		{URL: server.URL + "/article", Text: "A readable snippet."},
+		{URL: server.URL + "/report.pdf", Title: "Search title", Text: string(pdf[:64])},
+		{URL: server.URL + "/missing.pdf"},
+		{URL: server.URL + "/page", Text: "  "},
+	}
+	readable, dropped := NewURLFetcher(nil, 0, true).ReadDocuments(context.Background(), sources)
+
+	if len(readable) != 2 {
+		t.Fatalf("read %d sources, want 2", len(readable))
+	}
+	if readable[0] != sources[0] {
+		t.Errorf("readable snippet changed to %+v", readable[0])
+	}
+	if readable[1].Title != "Search title" || !strings.Contains(readable[1].Text, "Solar panels convert sunlight") {
+		t.Errorf("downloaded PDF = %+v, want the search title and the text of the PDF", readable[1])
+	}
+	if !reflect.DeepEqual(dropped, sources[2:]) {
+		t.Errorf("dropped = %+v, want the missing PDF and the empty page", dropped)
+	}
+}
+
+func TestLooksBinary(t *testing.T) {
+	tests := map[string]bool{
+		"Plain text with\ttabs and\nnewlines":                  false,
+		"Ünïcödé text":                                         false,
+		"%PDF-1.7 raw bytes":                                   true,
+		"PK\x03\x04zip bytes":                                  true,
+		"invalid \xff\xfe utf-8":                               true,
+		"\x00\x01\x02 mostly control \x03\x04\x05\x06\x07\x08": true,
+	}
+	for text, want := range tests {
+		if got := LooksBinary(text); got != want {
+			t.Errorf("LooksBinary(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"deep-research/internal/config"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type exaClient struct {
+	client     *http.Client
+	endpoint   string
+	apiKey     string
+	numResults int
+}
+
+type exaSearchRequest struct {
+	Query      string         `json:"query"`
+	Type       string         `json:"type"`
+	NumResults int            `json:"numResults"`
+	Content    map[string]any `json:"contents"`
+}
+
+type exaSearchResponse struct {
+	Results []exaIndividualSearchResult `json:"results"`
+}
+
+type exaIndividualSearchResult struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Url           string `json:"url"`
+	PublishedDate string `json:"publishedDate"`
+	Author        string `json:"author"`
+	Text          string `json:"text"`
+	Image         string `json:"image"`
+}
+
+func newExaClient(cfg *config.Config, client *http.Client) *exaClient {
+	return &exaClient{
+		client:     client,
+		endpoint:   cfg.ExaEndpoint,
+		apiKey:     cfg.ExaKey,
+		numResults: cfg.SearchNumResults,
+	}
+}
+
+func (e *exaClient) Search(ctx context.Context, query string) ([]Source, error) {
+	requestPayload, err := json.Marshal(
+		&exaSearchRequest{
+			Query:      query,
+			Type:       "auto",
+			NumResults: e.numResults,
+			Content: map[string]any{
+				"text": true,
+			},
+		},
+	)
+	if err != nil {
+		return []Source{}, fmt.Errorf("failed to encode search request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.endpoint, bytes.NewBuffer(requestPayload))
+	if err != nil {
+		return []Source{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("x-api-key", e.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	var searchResult exaSearchResponse
+	if err := sendSearchRequest(e.client, req, &searchResult); err != nil {
+		return []Source{}, err
+	}
+
+	var sources []Source
+	results := searchResult.Results
+	for _, result := range results {
+		sources = append(sources, Source{
+			Title:         result.Title,
+			URL:           result.Url,
+			PublishedDate: result.PublishedDate,
+			Author:        result.Author,
+			Text:          result.Text,
+		})
+	}
+	return sources, nil
+}
@@ -0,0 +1,276 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"deep-research/internal/transport"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sashabaranov/go-openai"
+	"golang.org/x/net/html/charset"
+)
+
+// maxFetchBytes caps how much of a response body is read.
+const maxFetchBytes = 10 << 20
+
+// truncationMarker is appended to text cut down to the fetch budget.
+const truncationMarker = "\n\n[Content truncated]"
+
+type FetchURLTool struct {
+	URL string `json:"url" jsonschema:"title=url,description=the URL of the web page to read,required"`
+}
+
+var FetchURLToolDefinition = openai.FunctionDefinition{
+	Name:        "fetch_url",
+	Description: "Download a web page or document (HTML, PDF, DOCX or plain text) and read its main content. Use it on promising search results whose snippet is not detailed enough",
+	Parameters:  GenerateToolSchema[FetchURLTool](),
+}
+
+// URLFetcher downloads pages and extracts their readable text.
+type URLFetcher struct {
+	client   *http.Client
+	maxChars int
+}
+
+// NewURLFetcher creates a fetcher that keeps at most maxChars characters of
+// each page, sending requests through base, or directly when base is nil.
+// Unless allowPrivate is set, it refuses to connect to addresses that are not
+// publicly routable, so the model cannot reach loopback, cloud metadata or
+// private network services. The check runs on the resolved address of every
+// connection, redirects included, and also guards the requests a cassette
+// records.
+func NewURLFetcher(base http.RoundTripper, maxChars int, allowPrivate bool) *URLFetcher {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !allowPrivate {
+		base = publicOnly(base)
+	}
+	return &URLFetcher{client: &http.Client{Timeout: searchTimeout, Transport: base}, maxChars: maxChars}
+}
+
+// publicOnly returns base restricted to publicly routable addresses. The
+// dialer of an *http.Transport, including one behind a cassette, checks the
+// address of every connection. Other transports cannot be looked into, so
+// the host of each request is resolved and checked before it is sent.
+func publicOnly(base http.RoundTripper) http.RoundTripper {
+	switch b := base.(type) {
+	case *http.Transport:
+		guarded := b.Clone()
+		// A proxy would resolve the host itself and bypass the check
+		guarded.Proxy = nil
+		guarded.DialContext = (&net.Dialer{
+			Timeout:   searchTimeout,
+			KeepAlive: 30 * time.Second,
+			Control:   rejectNonPublic,
+		}).DialContext
+		return guarded
+	case *transport.Cassette:
+		return b.WithBase(publicOnly(b.Base()))
+	default:
+		return resolvedPublicOnly{base: base}
+	}
+}
+
+// resolvedPublicOnly rejects requests whose host resolves to an address that
+// is not publicly routable before handing them to base.
+type resolvedPublicOnly struct {
+	base http.RoundTripper
+}
+
+func (t resolvedPublicOnly) RoundTrip(req *http.Request) (*http.Response, error) {
+	addrs, err := net.DefaultResolver.LookupNetIP(req.Context(), "ip", req.URL.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", req.URL.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return nil, fmt.Errorf("connecting to %s: %w", req.URL.Host, errNonPublicAddress)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// errNonPublicAddress is returned when a fetched URL resolves to an address
+// that is not publicly routable.
+var errNonPublicAddress = errors.New("address is not publicly routable")
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// rejectNonPublic is a net.Dialer Control function that refuses connections
+// to loopback, private, link-local, multicast and unspecified addresses.
+func rejectNonPublic(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", address, err)
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("connecting to %s: %w", address, errNonPublicAddress)
+	}
+	return nil
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr) &&
+		!(addr.Is4() && addr.As4()[0] == 0)
+}
+
+func (t FetchURLTool) Execute(ctx context.Context, fetcher *URLFetcher, input json.RawMessage) (Source, error) {
+	var fetchInput FetchURLTool
+	if err := json.Unmarshal(input, &fetchInput); err != nil {
+		return Source{}, fmt.Errorf("failed to parse fetch input: %w", err)
+	}
+	return fetcher.Fetch(ctx, fetchInput.URL)
+}
+
+// Fetch downloads rawURL and returns its readable content as a source.
+func (f *URLFetcher) Fetch(ctx context.Context, rawURL string) (Source, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Source{}, fmt.Errorf("invalid URL %q: only http and https URLs can be fetched", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Source{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/pdf,text/plain;q=0.9,*/*;q=0.5")
+	req.Header.Set("User-Agent", "deep-research/1.0")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Source{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Source{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes))
+	if err != nil {
+		return Source{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	source, err := extractDocument(resp.Header.Get("Content-Type"), resp.Request.URL.String(), body)
+	if err != nil {
+		return Source{}, err
+	}
+
+	// Redirects may have moved the page, so cite the final URL
+	source.URL = resp.Request.URL.String()
+	if source.Title == "" {
+		source.Title = titleFromURL(resp.Request.URL)
+	}
+	if strings.TrimSpace(source.Text) == "" {
+		return Source{}, fmt.Errorf("no readable content found")
+	}
+	source.Text = truncateText(source.Text, f.maxChars)
+	return source, nil
+}
+
+// extractDocument returns the readable content of a response body based on
+// its content type, falling back to the file extension of rawURL and the
+// content itself when the server sends a generic or missing type.
+func extractDocument(contentType, rawURL string, body []byte) (Source, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	switch mediaType {
+	case "", "application/octet-stream", "binary/octet-stream", "application/download", "application/force-download":
+		if fromURL, ok := mediaTypeFromURL(rawURL); ok {
+			mediaType = fromURL
+		} else {
+			mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+		}
+	case "application/x-pdf", "application/acrobat":
+		mediaType = mediaTypePDF
+	}
+
+	switch {
+	case mediaType == mediaTypePDF:
+		title, text, err := extractPDF(body)
+		if err != nil {
+			return Source{}, err
+		}
+		return Source{Title: title, Text: text}, nil
+	case mediaType == mediaTypeDOCX:
+		title, text, err := extractDOCX(body)
+		if err != nil {
+			return Source{}, err
+		}
+		return Source{Title: title, Text: text}, nil
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+		if err != nil {
+			return Source{}, fmt.Errorf("failed to decode page: %w", err)
+		}
+		page, err := extractHTML(reader)
+		if err != nil {
+			return Source{}, fmt.Errorf("failed to parse HTML: %w", err)
+		}
+		return Source{
+			Title:         page.Title,
+			Author:        page.Author,
+			PublishedDate: page.PublishedDate,
+			Text:          page.Text,
+		}, nil
+	case strings.HasPrefix(mediaType, "text/"):
+		text := string(body)
+		if cs := params["charset"]; cs != "" && !strings.EqualFold(cs, "utf-8") {
+			reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+			if err == nil {
+				if decoded, err := io.ReadAll(reader); err == nil {
+					text = string(decoded)
+				}
+			}
+		}
+		if LooksBinary(text) {
+			return Source{}, fmt.Errorf("content of type %q is not readable text", mediaType)
+		}
+		return Source{Text: normalizeText(text)}, nil
+	default:
+		return Source{}, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+}
+
+func titleFromURL(u *url.URL) string {
+	if name := path.Base(u.Path); name != "/" && name != "." {
+		return name
+	}
+	return u.Host
+}
+
+// truncateText cuts text to at most maxChars characters, preferring a
+// paragraph or word boundary. A non-positive maxChars keeps the full text.
+func truncateText(text string, maxChars int) string {
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:maxChars])
+	if i := strings.LastIndex(cut, "\n\n"); i > len(cut)/2 {
+		cut = cut[:i]
+	} else if i := strings.LastIndexAny(cut, " \n"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + truncationMarker
+}
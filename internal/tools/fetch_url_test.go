@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"context"
+	"deep-research/internal/transport"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newPageServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<html><head><title>Solar power</title></head><body><article><p>Solar panels convert sunlight into electricity.</p></article></body></html>`))
+	})
+	mux.HandleFunc("/notes.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("Plain notes about wind turbines."))
+	})
+	mux.HandleFunc("/files/notes.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("Plain notes about tidal energy."))
+	})
+	mux.HandleFunc("/long", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(strings.Repeat("word ", 200)))
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(strings.Repeat("word ", maxFetchBytes/5+1000)))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body></body></html>`))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestURLFetcherFetch(t *testing.T) {
+	server := newPageServer(t)
+
+	tests := []struct {
+		name      string
+		path      string
+		maxChars  int
+		wantURL   string
+		wantTitle string
+		wantText  string
+		wantErr   string
+	}{
+		{name: "html", path: "/article", wantTitle: "Solar power", wantText: "Solar panels convert sunlight"},
+		{name: "plain text titled by file name", path: "/notes.txt", wantTitle: "notes.txt", wantText: "Plain notes about wind turbines."},
+		{name: "generic type falls back to extension", path: "/files/notes.txt", wantTitle: "notes.txt", wantText: "Plain notes about tidal energy."},
+		{name: "truncated to max chars", path: "/long", maxChars: 50, wantText: truncationMarker},
+		{name: "body capped at max fetch bytes", path: "/huge", wantText: "word word"},
+		{name: "redirect cites final URL", path: "/moved", wantURL: "/article", wantTitle: "Solar power"},
+		{name: "unsupported content type", path: "/image.png", wantErr: `unsupported content type "image/png"`},
+		{name: "no readable content", path: "/empty", wantErr: "no readable content"},
+		{name: "error status", path: "/missing", wantErr: "unexpected status 404"},
+		{name: "redirect loop", path: "/loop", wantErr: "stopped after 10 redirects"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewURLFetcher(nil, tt.maxChars, true)
+			source, err := fetcher.Fetch(context.Background(), server.URL+tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Fetch() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+
+			wantURL := tt.wantURL
+			if wantURL == "" {
+				wantURL = tt.path
+			}
+			if source.URL != server.URL+wantURL {
+				t.Errorf("URL = %q, want %q", source.URL, server.URL+wantURL)
+			}
+			if tt.wantTitle != "" && source.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", source.Title, tt.wantTitle)
+			}
+			if !strings.Contains(source.Text, tt.wantText) {
+				t.Errorf("Text = %q, want it to contain %q", source.Text, tt.wantText)
+			}
+			if tt.maxChars > 0 && len([]rune(source.Text)) > tt.maxChars+len(truncationMarker) {
+				t.Errorf("Text has %d characters, want at most %d", len([]rune(source.Text)), tt.maxChars)
+			}
+			if len(source.Text) > maxFetchBytes {
+				t.Errorf("Text has %d bytes, want at most %d", len(source.Text), maxFetchBytes)
+			}
+		})
+	}
+}
+
+func TestURLFetcherRejectsNonPublicAddresses(t *testing.T) {
+	server := newPageServer(t)
+	fetcher := NewURLFetcher(nil, 0, false)
+
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+	for _, rawURL := range []string{server.URL + "/article", "http://localhost:" + port + "/article"} {
+		if _, err := fetcher.Fetch(context.Background(), rawURL); !errors.Is(err, errNonPublicAddress) {
+			t.Errorf("Fetch(%q) error = %v, want %v", rawURL, err, errNonPublicAddress)
+		}
+	}
+}
+
+// roundTripperFunc is a transport the fetcher cannot look into.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestURLFetcherGuardsWrappedTransports(t *testing.T) {
+	server := newPageServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette, err := transport.NewCassette(path, transport.CassetteRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opaque := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	for name, base := range map[string]http.RoundTripper{"recording cassette": cassette, "opaque transport": opaque} {
+		t.Run(name, func(t *testing.T) {
+			fetcher := NewURLFetcher(base, 0, false)
+			if _, err := fetcher.Fetch(context.Background(), server.URL+"/article"); !errors.Is(err, errNonPublicAddress) {
+				t.Errorf("Fetch() error = %v, want %v", err, errNonPublicAddress)
+			}
+			// The guard only applies to the fetcher
+			if _, err := (&http.Client{Transport: base}).Get(server.URL + "/article"); err != nil {
+				t.Errorf("direct request error = %v", err)
+			}
+		})
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"0.1.2.3":          false,
+		"fc00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+		"224.0.0.1":        false,
+	}
+	for addr, want := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
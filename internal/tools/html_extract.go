@@ -0,0 +1,262 @@
+package tools
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skippedElements never contain readable page content.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Canvas:   true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Dialog:   true,
+}
+
+// blockElements start a new line in the extracted text.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Table: true, atom.Tr: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Br: true, atom.Hr: true,
+}
+
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// boilerplatePattern matches class names, ids and roles of page chrome such
+// as menus, cookie banners and share buttons.
+var boilerplatePattern = regexp.MustCompile(`(?i)(^|[\s_-])(nav|navbar|navigation|menu|breadcrumbs?|sidebar|footer|header|banner|cookies?|consent|share|social|subscribe|newsletter|advert|ads?|promo|related|comments?|popup|modal)([\s_-]|$)`)
+
+var boilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"search":        true,
+	"dialog":        true,
+}
+
+// extractedPage is the readable content of an HTML page.
+type extractedPage struct {
+	Title         string
+	Author        string
+	PublishedDate string
+	Text          string
+}
+
+// extractHTML parses an HTML document and returns its main readable text
+// with navigation, scripts and other boilerplate removed.
+func extractHTML(r io.Reader) (extractedPage, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return extractedPage{}, err
+	}
+
+	page := extractedPage{}
+	readMetadata(doc, &page)
+
+	root := findMainContent(doc)
+	var b strings.Builder
+	writeText(&b, root, false)
+	page.Text = normalizeText(b.String())
+	return page, nil
+}
+
+// readMetadata fills in the title, author and publication date from the
+// document head.
+func readMetadata(n *html.Node, page *extractedPage) {
+	if n.Type == html.ElementNode {
+		switch n.DataAtom {
+		case atom.Title:
+			if page.Title == "" && n.FirstChild != nil {
+				page.Title = strings.TrimSpace(n.FirstChild.Data)
+			}
+		case atom.Meta:
+			name := strings.ToLower(attr(n, "name") + attr(n, "property"))
+			content := strings.TrimSpace(attr(n, "content"))
+			switch name {
+			case "og:title":
+				if content != "" {
+					page.Title = content
+				}
+			case "author", "article:author":
+				if page.Author == "" {
+					page.Author = content
+				}
+			case "article:published_time", "date", "dc.date", "pubdate":
+				if page.PublishedDate == "" {
+					page.PublishedDate = content
+				}
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		readMetadata(c, page)
+	}
+}
+
+// findMainContent returns the element holding the main content of the page:
+// the largest <article>, else <main>, else <body>.
+func findMainContent(doc *html.Node) *html.Node {
+	var article, main, body *html.Node
+	articleLength := 0
+	var visit func(*html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.DataAtom == atom.Article:
+				if length := textLength(n); length > articleLength {
+					article, articleLength = n, length
+				}
+			case n.DataAtom == atom.Main || attr(n, "role") == "main":
+				if main == nil {
+					main = n
+				}
+			case n.DataAtom == atom.Body:
+				body = n
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc)
+
+	switch {
+	case article != nil:
+		return article
+	case main != nil:
+		return main
+	case body != nil:
+		return body
+	default:
+		return doc
+	}
+}
+
+func textLength(n *html.Node) int {
+	if n.Type == html.TextNode {
+		return len(strings.TrimSpace(n.Data))
+	}
+	length := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		length += textLength(c)
+	}
+	return length
+}
+
+func isBoilerplate(n *html.Node) bool {
+	if skippedElements[n.DataAtom] {
+		return true
+	}
+	if boilerplateRoles[attr(n, "role")] || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	if _, hidden := attrValue(n, "hidden"); hidden {
+		return true
+	}
+	// Page-level containers often carry state classes such as "nav-open"
+	switch n.DataAtom {
+	case atom.Html, atom.Body, atom.Main, atom.Article:
+		return false
+	}
+	return boilerplatePattern.MatchString(attr(n, "class")) || boilerplatePattern.MatchString(attr(n, "id"))
+}
+
+// writeText renders the text under n. Line breaks in the markup are
+// collapsed like a browser would, except inside <pre>.
+func writeText(b *strings.Builder, n *html.Node, pre bool) {
+	switch n.Type {
+	case html.TextNode:
+		if pre {
+			b.WriteString(n.Data)
+		} else {
+			b.WriteString(strings.ReplaceAll(n.Data, "\n", " "))
+		}
+		return
+	case html.ElementNode:
+		if isBoilerplate(n) {
+			return
+		}
+	case html.CommentNode, html.DoctypeNode:
+		return
+	}
+
+	block := blockElements[n.DataAtom]
+	if block {
+		b.WriteString("\n")
+	}
+	if level, ok := headingLevels[n.DataAtom]; ok {
+		b.WriteString(strings.Repeat("#", level) + " ")
+	}
+	if n.DataAtom == atom.Li {
+		b.WriteString("- ")
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(b, c, pre || n.DataAtom == atom.Pre)
+	}
+	// List items end where the next one starts, keeping lists compact
+	if block && n.DataAtom != atom.Li {
+		b.WriteString("\n")
+	} else if n.DataAtom == atom.Td || n.DataAtom == atom.Th {
+		b.WriteString(" ")
+	}
+}
+
+var spacePattern = regexp.MustCompile(`[ \t\r\f\v\x{00a0}]+`)
+
+// normalizeText collapses runs of whitespace and blank lines.
+func normalizeText(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimSpace(spacePattern.ReplaceAllString(line, " "))
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		// Drop headings and bullets left without content
+		if strings.Trim(line, "#- ") == "" {
+			continue
+		}
+		out = append(out, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+func attr(n *html.Node, key string) string {
+	value, _ := attrValue(n, key)
+	return value
+}
+
+func attrValue(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,312 @@
+package tools
+
+import (
+	"context"
+	"deep-research/internal/config"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// BM25 ranking parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// passageChars is the approximate size of the passages files are split into.
+const passageChars = 1200
+
+// LocalCorpus searches a directory of Markdown, plain text and PDF files on
+// disk. Files are split into passages of a few paragraphs that are ranked
+// with BM25, so research can run fully offline and cite the exact lines a
+// finding came from. The index is built once when the corpus is opened.
+type LocalCorpus struct {
+	dir        string
+	numResults int
+	passages   []passage
+	// docFreq counts the passages each term appears in
+	docFreq   map[string]int
+	avgLength float64
+}
+
+// passage is an indexed section of a corpus file.
+type passage struct {
+	path     string
+	title    string
+	anchor   string
+	modified time.Time
+	text     string
+	terms    map[string]int
+	length   int
+}
+
+var localCorpusExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+	".pdf":      true,
+}
+
+func newLocalCorpus(cfg *config.Config) (*LocalCorpus, error) {
+	if cfg.LocalCorpusDir == "" {
+		return nil, &config.ConfigError{
+			Field:   "LocalCorpusDir",
+			Message: "a corpus directory is required for the local search provider",
+		}
+	}
+	info, err := os.Stat(cfg.LocalCorpusDir)
+	if err != nil || !info.IsDir() {
+		return nil, &config.ConfigError{
+			Field:   "LocalCorpusDir",
+			Value:   cfg.LocalCorpusDir,
+			Message: "corpus directory does not exist",
+		}
+	}
+	return NewLocalCorpus(cfg.LocalCorpusDir, cfg.SearchNumResults)
+}
+
+// NewLocalCorpus indexes the supported files under dir. Searches return at
+// most numResults passages.
+func NewLocalCorpus(dir string, numResults int) (*LocalCorpus, error) {
+	// Absolute paths keep file:// source URLs meaningful in the report
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve corpus directory: %w", err)
+	}
+
+	corpus := &LocalCorpus{
+		dir:        dir,
+		numResults: numResults,
+		docFreq:    make(map[string]int),
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.IsDir() || !localCorpusExtensions[ext] {
+			return nil
+		}
+		return corpus.indexFile(path, ext, d)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index local corpus: %w", err)
+	}
+
+	totalLength := 0
+	for _, p := range corpus.passages {
+		totalLength += p.length
+		for term := range p.terms {
+			corpus.docFreq[term]++
+		}
+	}
+	if len(corpus.passages) > 0 {
+		corpus.avgLength = float64(totalLength) / float64(len(corpus.passages))
+	}
+	return corpus, nil
+}
+
+// indexFile splits a file into passages. Text passages are anchored to
+// their line range and PDF passages to their page.
+func (c *LocalCorpus) indexFile(path, ext string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	name, err := filepath.Rel(c.dir, path)
+	if err != nil {
+		name = filepath.Base(path)
+	}
+
+	add := func(text, anchor, label string) {
+		terms := make(map[string]int)
+		tokens := tokenize(text)
+		for _, token := range tokens {
+			terms[token]++
+		}
+		c.passages = append(c.passages, passage{
+			path:     path,
+			title:    fmt.Sprintf("%s (%s)", filepath.ToSlash(name), label),
+			anchor:   anchor,
+			modified: info.ModTime(),
+			text:     text,
+			terms:    terms,
+			length:   len(tokens),
+		})
+	}
+
+	if ext == ".pdf" {
+		// Unparseable PDFs are left out rather than failing the whole index
+		_, pages, err := extractPDFPages(content)
+		if err != nil {
+			return nil
+		}
+		for i, page := range pages {
+			for _, chunk := range splitPassages(normalizeText(page)) {
+				add(chunk.text, fmt.Sprintf("page=%d", i+1), fmt.Sprintf("page %d", i+1))
+			}
+		}
+		return nil
+	}
+
+	for _, chunk := range splitPassages(string(content)) {
+		add(chunk.text, fmt.Sprintf("L%d-L%d", chunk.start, chunk.end), fmt.Sprintf("lines %d-%d", chunk.start, chunk.end))
+	}
+	return nil
+}
+
+type textChunk struct {
+	text       string
+	start, end int
+}
+
+// splitPassages groups the lines of text into passages of about
+// passageChars characters, breaking at paragraph ends and before Markdown
+// ATX headings outside fenced code blocks. Line numbers are 1-based.
+func splitPassages(text string) []textChunk {
+	var chunks []textChunk
+	var lines []string
+	start, size := 0, 0
+
+	flush := func() {
+		// Passages start at a non-blank line, so trimming only drops
+		// trailing blank lines
+		chunk := strings.TrimSpace(strings.Join(lines, "\n"))
+		if chunk != "" {
+			chunks = append(chunks, textChunk{text: chunk, start: start, end: start + strings.Count(chunk, "\n")})
+		}
+		lines, size = nil, 0
+	}
+
+	fence := ""
+	for i, line := range strings.Split(text, "\n") {
+		lineNumber := i + 1
+		trimmed := strings.TrimSpace(line)
+		blank := trimmed == ""
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		case isATXHeading(strings.TrimRight(line, "\r")) && size > 0:
+			flush()
+		}
+		if len(lines) == 0 {
+			if blank {
+				continue
+			}
+			start = lineNumber
+		}
+		lines = append(lines, strings.TrimRight(line, "\r"))
+		size += len(line)
+		if (blank && size >= passageChars) || size >= 2*passageChars {
+			flush()
+		}
+	}
+	flush()
+	return chunks
+}
+
+// isATXHeading reports whether line is a Markdown heading of one to six #
+// characters followed by a space, such as "## Results". Lines like "#hashtag"
+// or "#!/bin/sh" are not headings.
+func isATXHeading(line string) bool {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return false
+	}
+	return level == len(line) || line[level] == ' ' || line[level] == '\t'
+}
+
+func (c *LocalCorpus) Search(ctx context.Context, query string) ([]Source, error) {
+	if err := ctx.Err(); err != nil {
+		return []Source{}, fmt.Errorf("failed to search local corpus: %w", err)
+	}
+
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 {
+		return []Source{}, nil
+	}
+
+	type match struct {
+		index int
+		score float64
+	}
+	var matches []match
+	for i, p := range c.passages {
+		if score := c.score(p, terms); score > 0 {
+			matches = append(matches, match{index: i, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	if len(matches) > c.numResults {
+		matches = matches[:c.numResults]
+	}
+
+	sources := make([]Source, len(matches))
+	for i, m := range matches {
+		p := c.passages[m.index]
+		sources[i] = Source{
+			Title:         p.title,
+			URL:           "file://" + filepath.ToSlash(p.path) + "#" + p.anchor,
+			PublishedDate: p.modified.Format("2006-01-02"),
+			Text:          p.text,
+		}
+	}
+	return sources, nil
+}
+
+// score returns the BM25 score of a passage for the query terms.
+func (c *LocalCorpus) score(p passage, terms []string) float64 {
+	n := float64(len(c.passages))
+	score := 0.0
+	for _, term := range terms {
+		tf := float64(p.terms[term])
+		if tf == 0 {
+			continue
+		}
+		df := float64(c.docFreq[term])
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		norm := 1 - bm25B + bm25B*float64(p.length)/c.avgLength
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+	}
+	return score
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := terms[:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// tokenize splits text into lowercase alphanumeric terms.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
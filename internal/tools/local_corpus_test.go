@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPassages(t *testing.T) {
+	long := strings.Repeat("word ", passageChars/5)
+
+	tests := []struct {
+		name string
+		text string
+		want []textChunk
+	}{
+		{
+			name: "short paragraphs stay together",
+			text: "\nFirst paragraph.\n\nSecond paragraph.\n\n",
+			want: []textChunk{{text: "First paragraph.\n\nSecond paragraph.", start: 2, end: 4}},
+		},
+		{
+			name: "headings start a passage",
+			text: "Intro.\n# Title\nBody.\n###### Deep\nMore.",
+			want: []textChunk{
+				{text: "Intro.", start: 1, end: 1},
+				{text: "# Title\nBody.", start: 2, end: 3},
+				{text: "###### Deep\nMore.", start: 4, end: 5},
+			},
+		},
+		{
+			name: "lines starting with # that are not headings",
+			text: "Intro.\n#hashtag\n#!/bin/sh\n####### seven\nEnd.",
+			want: []textChunk{{text: "Intro.\n#hashtag\n#!/bin/sh\n####### seven\nEnd.", start: 1, end: 5}},
+		},
+		{
+			name: "comments in fenced code",
+			text: "Run this:\n```sh\n# install\nmake\n```\n~~~\n# not a heading\n~~~\nDone.",
+			want: []textChunk{{text: "Run this:\n```sh\n# install\nmake\n```\n~~~\n# not a heading\n~~~\nDone.", start: 1, end: 9}},
+		},
+		{
+			name: "long paragraphs break at blank lines",
+			text: long + "\n\n" + "Next.",
+			want: []textChunk{
+				{text: strings.TrimSpace(long), start: 1, end: 1},
+				{text: "Next.", start: 3, end: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitPassages(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitPassages() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPassagesCapsSize(t *testing.T) {
+	// A single paragraph without blank lines is cut at twice the passage size
+	text := strings.Repeat(strings.Repeat("word ", 20)+"\n", 100)
+	chunks := splitPassages(text)
+	if len(chunks) < 2 {
+		t.Fatalf("split into %d passages, want several", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if len(chunk.text) > 2*passageChars+len("word ")*20 {
+			t.Errorf("passage %d has %d characters", i, len(chunk.text))
+		}
+		if i > 0 && chunk.start != chunks[i-1].end+1 {
+			t.Errorf("passage %d starts at line %d, want %d", i, chunk.start, chunks[i-1].end+1)
+		}
+	}
+}
+
+func TestLocalCorpusSearch(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"solar.md":       "# Solar\nSolar panels convert sunlight into electricity.\n\n# Storage\nBatteries store solar electricity for the night.",
+		"wind.txt":       "Wind turbines convert wind into electricity. Wind farms work best offshore, where wind is steady.",
+		"notes/tides.md": "Tidal power is predictable.",
+		"image.png":      "solar solar solar",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pdf, err := os.ReadFile(filepath.Join("testdata", "solar.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "report.pdf"), pdf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	corpus, err := NewLocalCorpus(dir, 3)
+	if err != nil {
+		t.Fatalf("NewLocalCorpus() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantTitle []string
+		wantURL   string
+	}{
+		{
+			name:      "ranks by term frequency",
+			query:     "wind",
+			wantTitle: []string{"wind.txt (lines 1-1)"},
+		},
+		{
+			// Four passages mention electricity; the longest is cut
+			name:      "rare terms outweigh common ones",
+			query:     "electricity batteries",
+			wantTitle: []string{"solar.md (lines 4-5)", "report.pdf (page 1)", "solar.md (lines 1-2)"},
+			wantURL:   "file://" + filepath.ToSlash(filepath.Join(dir, "solar.md")) + "#L4-L5",
+		},
+		{
+			name:      "pdf pages",
+			query:     "sunlight",
+			wantTitle: []string{"report.pdf (page 1)", "solar.md (lines 1-2)"},
+			wantURL:   "file://" + filepath.ToSlash(filepath.Join(dir, "report.pdf")) + "#page=1",
+		},
+		{
+			name:      "files in subdirectories",
+			query:     "Tidal",
+			wantTitle: []string{"notes/tides.md (lines 1-1)"},
+		},
+		{name: "no match", query: "geothermal"},
+		{name: "no terms", query: "?!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources, err := corpus.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			var titles []string
+			for _, source := range sources {
+				titles = append(titles, source.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.wantTitle) {
+				t.Errorf("titles = %q, want %q", titles, tt.wantTitle)
+			}
+			if tt.wantURL != "" && sources[0].URL != tt.wantURL {
+				t.Errorf("URL = %q, want %q", sources[0].URL, tt.wantURL)
+			}
+		})
+	}
+}
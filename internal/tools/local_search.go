@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"deep-research/internal/config"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+type LocalSearchTool struct {
+	Query string `json:"query" jsonschema:"title=search query,description=keywords to search for in the local document collection,required"`
+}
+
+var LocalSearchToolDefinition = openai.FunctionDefinition{
+	Name:        "local_search",
+	Description: "Search the user's local document collection (Markdown, text and PDF files). Results are passages cited by file path and line or page",
+	Parameters:  GenerateToolSchema[LocalSearchTool](),
+}
+
+// NewLocalSearch returns the local corpus offered to the research agent
+// through the local_search tool, alongside web search. It returns nil when
+// no corpus directory is configured, or when the corpus is already the
+// search provider behind search_tool.
+func NewLocalSearch(cfg *config.Config) (SearchProvider, error) {
+	if cfg.LocalCorpusDir == "" || cfg.SearchProvider == "local" {
+		return nil, nil
+	}
+	corpus, err := newLocalCorpus(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return corpus, nil
+}
+
+func (t LocalSearchTool) Execute(ctx context.Context, corpus SearchProvider, input json.RawMessage) ([]Source, error) {
+	var searchInput LocalSearchTool
+	if err := json.Unmarshal(input, &searchInput); err != nil {
+		return []Source{}, fmt.Errorf("failed to parse local search input: %w", err)
+	}
+
+	results, err := corpus.Search(ctx, searchInput.Query)
+	if err != nil {
+		return []Source{}, fmt.Errorf("failed to search local documents: %w", err)
+	}
+	return results, nil
+}
@@ -1,12 +1,15 @@
 package tools
 
 import (
-	"bytes"
+	"context"
+	"deep-research/internal/cache"
 	"deep-research/internal/config"
+	"deep-research/internal/transport"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/sashabaranov/go-openai"
@@ -16,36 +19,23 @@ type SearchTool struct {
 	Query string `json:"query" jsonschema:"title=search query,description=the search query to be use for web search,required"`
 }
 
-type exaClient struct {
-	client *http.Client
-}
-
-type exaSearchRequest struct {
-	Query      string         `json:"query"`
-	Type       string         `json:"type"`
-	NumResults int            `json:"numResults"`
-	Content    map[string]any `json:"contents"`
-}
-
-type exaSearchResponse struct {
-	Results []exaIndividualSearchResult `json:"results"`
-}
-
-type exaIndividualSearchResult struct {
-	ID            string `json:"id"`
+// Source is a document returned by a search provider along with the metadata
+// needed to cite it.
+type Source struct {
 	Title         string `json:"title"`
-	Url           string `json:"url"`
-	PublishedDate string `json:"publishedDate"`
-	Author        string `json:"author"`
-	Text          string `json:"text"`
-	Image         string `json:"image"`
+	URL           string `json:"url"`
+	PublishedDate string `json:"published_date,omitempty"`
+	Author        string `json:"author,omitempty"`
+	Text          string `json:"text,omitempty"`
 }
 
-var httpClient = &http.Client{
-	Timeout: 30 * time.Second,
+// SearchProvider is a search backend that returns the documents matching a query.
+type SearchProvider interface {
+	Search(ctx context.Context, query string) ([]Source, error)
 }
 
-var defaultExaClient *exaClient
+// searchTimeout bounds each attempt of a search or page fetch.
+const searchTimeout = 30 * time.Second
 
 var SearchToolDefinition = openai.FunctionDefinition{
 	Name:        "search_tool",
@@ -53,82 +43,88 @@ var SearchToolDefinition = openai.FunctionDefinition{
 	Parameters:  GenerateToolSchema[SearchTool](),
 }
 
-func getExaClient() *exaClient {
-	if defaultExaClient == nil {
-		defaultExaClient = &exaClient{
-			client: httpClient,
-		}
-	}
-	return defaultExaClient
-}
-
-func (e *exaClient) Search(cfg *config.Config, query []byte) ([]string, error) {
-	req, err := http.NewRequest("POST", cfg.ExaEndpoint, bytes.NewBuffer(query))
+// NewSearchProvider returns the search provider selected by cfg.SearchProvider.
+// Web search results are served from responseCache when it is not nil.
+func NewSearchProvider(cfg *config.Config, responseCache *cache.Cache) (SearchProvider, error) {
+	provider, err := newSearchProvider(cfg)
 	if err != nil {
-		return []string{}, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
+	}
+	// The local corpus is already on disk, so caching it gains nothing
+	if responseCache == nil || cfg.SearchProvider == "local" {
+		return provider, nil
 	}
-	req.Header.Set("x-api-key", cfg.ExaKey)
-	req.Header.Set("Content-Type", "application/json")
+	return &cachedSearch{
+		provider:   provider,
+		name:       cfg.SearchProvider,
+		numResults: cfg.SearchNumResults,
+		cache:      responseCache,
+	}, nil
+}
 
-	resp, err := e.client.Do(req)
-	if err != nil {
-		return []string{}, fmt.Errorf("failed to send request: %w", err)
+func newSearchProvider(cfg *config.Config) (SearchProvider, error) {
+	name := cfg.SearchProvider
+	if name == "" {
+		name = "exa"
 	}
-	defer func() {
-		if err = resp.Body.Close(); err != nil {
-			fmt.Printf("failed to close response body: %v\n", err)
+	// Transient failures and rate limits are retried below the client
+	client := transport.NewClient(name, cfg.Retry, cfg.Limits[name], searchTimeout, cfg.Transport)
+	switch name {
+	case "exa":
+		return newExaClient(cfg, client), nil
+	case "tavily":
+		return newTavilyClient(cfg, client), nil
+	case "brave":
+		return newBraveClient(cfg, client), nil
+	case "searxng":
+		return newSearXNGClient(cfg, client), nil
+	case "local":
+		return newLocalCorpus(cfg)
+	default:
+		return nil, &config.ConfigError{
+			Field:   "SearchProvider",
+			Value:   cfg.SearchProvider,
+			Message: "unknown search provider, expected one of exa, tavily, brave, searxng or local",
 		}
-	}()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return []string{}, fmt.Errorf("failed to read response body: %w", err)
 	}
+}
 
-	var searchResult exaSearchResponse
-	err = json.Unmarshal(body, &searchResult)
+func (s SearchTool) Execute(ctx context.Context, provider SearchProvider, input json.RawMessage) ([]Source, error) {
+	var searchInput SearchTool
+	err := json.Unmarshal(input, &searchInput)
 	if err != nil {
-		return []string{}, fmt.Errorf("failed to parse JSON response: %w", err)
+		return []Source{}, fmt.Errorf("failed to parse search input: %w", err)
 	}
 
-	var texts []string
-	results := searchResult.Results
-	for _, result := range results {
-		texts = append(texts, result.Text)
+	results, err := provider.Search(ctx, searchInput.Query)
+	if err != nil {
+		return []Source{}, fmt.Errorf("failed to search: %w", err)
 	}
-	return texts, nil
+	return results, nil
 }
 
-func (s SearchTool) Execute(input json.RawMessage) ([]string, error) {
-	cfg, err := config.LoadConfig()
+// sendSearchRequest sends req and decodes the JSON response body into out.
+func sendSearchRequest(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
 	if err != nil {
-		return []string{}, fmt.Errorf("failed to load configuration: %w", err)
+		return fmt.Errorf("failed to send request: %w", err)
 	}
+	defer func() {
+		if err = resp.Body.Close(); err != nil {
+			fmt.Printf("failed to close response body: %v\n", err)
+		}
+	}()
 
-	var searchInput SearchTool
-	err = json.Unmarshal(input, &searchInput)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return []string{}, fmt.Errorf("failed to parse search input: %w", err)
+		return fmt.Errorf("failed to read response body: %w", err)
 	}
-
-	requestPayload, err := json.Marshal(
-		&exaSearchRequest{
-			Query:      searchInput.Query,
-			Type:       "auto",
-			NumResults: cfg.ExaNumSearchResult,
-			Content: map[string]any{
-				"text": true,
-			},
-		},
-	)
-
-	if err != nil {
-		return []string{}, fmt.Errorf("failed to parse search input: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
 	}
 
-	results, err := getExaClient().Search(cfg, requestPayload)
-	if err != nil {
-		return []string{}, fmt.Errorf("failed to search: %w", err)
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse JSON response: %w", err)
 	}
-	return results, nil
+	return nil
 }
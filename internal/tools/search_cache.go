@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"deep-research/internal/cache"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cachedSearch serves repeated searches from the response cache.
+type cachedSearch struct {
+	provider   SearchProvider
+	name       string
+	numResults int
+	cache      *cache.Cache
+}
+
+func (c *cachedSearch) Search(ctx context.Context, query string) ([]Source, error) {
+	key := cache.Key("search", c.name, strconv.Itoa(c.numResults), NormalizeQuery(query))
+	var sources []Source
+	if c.cache.Get(key, &sources) {
+		return sources, nil
+	}
+
+	sources, err := c.provider.Search(ctx, query)
+	if err != nil {
+		return sources, err
+	}
+	// A failed store only costs a repeated search later
+	_ = c.cache.Set(key, sources)
+	return sources, nil
+}
+
+// NormalizeQuery lowercases a search query and collapses its whitespace, so
+// trivially different spellings of a query share a cache entry.
+func NormalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// trackingParams are query parameters that do not change the page served.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "mc_cid": true, "mc_eid": true, "ref": true,
+}
+
+// CanonicalURL normalizes a URL so that links to the same page compare
+// equal: the scheme and host are lowercased, default ports, fragments,
+// trailing slashes and tracking parameters are removed, and the remaining
+// query parameters are sorted. URLs without a host, such as the file URLs of
+// local passages, are returned unchanged.
+func CanonicalURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	u.Host = strings.TrimPrefix(u.Host, "www.")
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	u.Fragment = ""
+
+	query := u.Query()
+	for key := range query {
+		if trackingParams[strings.ToLower(key)] || strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	u.RawQuery = strings.Join(parts, "&")
+	return u.String()
+}
@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"deep-research/internal/config"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type searXNGClient struct {
+	client     *http.Client
+	endpoint   string
+	numResults int
+}
+
+type searXNGSearchResponse struct {
+	Results []searXNGIndividualSearchResult `json:"results"`
+}
+
+type searXNGIndividualSearchResult struct {
+	Title         string `json:"title"`
+	Url           string `json:"url"`
+	Content       string `json:"content"`
+	PublishedDate string `json:"publishedDate"`
+	Engine        string `json:"engine"`
+}
+
+func newSearXNGClient(cfg *config.Config, client *http.Client) *searXNGClient {
+	return &searXNGClient{
+		client:     client,
+		endpoint:   cfg.SearXNGEndpoint,
+		numResults: cfg.SearchNumResults,
+	}
+}
+
+func (s *searXNGClient) Search(ctx context.Context, query string) ([]Source, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return []Source{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var searchResult searXNGSearchResponse
+	if err := sendSearchRequest(s.client, req, &searchResult); err != nil {
+		return []Source{}, err
+	}
+
+	// SearXNG has no page size parameter so trim the results ourselves
+	results := searchResult.Results
+	if len(results) > s.numResults {
+		results = results[:s.numResults]
+	}
+
+	var sources []Source
+	for _, result := range results {
+		sources = append(sources, Source{
+			Title:         result.Title,
+			URL:           result.Url,
+			PublishedDate: result.PublishedDate,
+			Text:          strings.Join([]string{result.Title, result.Content}, "\n"),
+		})
+	}
+	return sources, nil
+}
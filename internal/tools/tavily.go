@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"deep-research/internal/config"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type tavilyClient struct {
+	client     *http.Client
+	endpoint   string
+	apiKey     string
+	numResults int
+}
+
+type tavilySearchRequest struct {
+	Query             string `json:"query"`
+	SearchDepth       string `json:"search_depth"`
+	MaxResults        int    `json:"max_results"`
+	IncludeRawContent bool   `json:"include_raw_content"`
+}
+
+type tavilySearchResponse struct {
+	Results []tavilyIndividualSearchResult `json:"results"`
+}
+
+type tavilyIndividualSearchResult struct {
+	Title         string  `json:"title"`
+	Url           string  `json:"url"`
+	Content       string  `json:"content"`
+	RawContent    string  `json:"raw_content"`
+	PublishedDate string  `json:"published_date"`
+	Score         float64 `json:"score"`
+}
+
+func newTavilyClient(cfg *config.Config, client *http.Client) *tavilyClient {
+	return &tavilyClient{
+		client:     client,
+		endpoint:   cfg.TavilyEndpoint,
+		apiKey:     cfg.TavilyKey,
+		numResults: cfg.SearchNumResults,
+	}
+}
+
+func (t *tavilyClient) Search(ctx context.Context, query string) ([]Source, error) {
+	requestPayload, err := json.Marshal(&tavilySearchRequest{
+		Query:             query,
+		SearchDepth:       "advanced",
+		MaxResults:        t.numResults,
+		IncludeRawContent: true,
+	})
+	if err != nil {
+		return []Source{}, fmt.Errorf("failed to encode search request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.endpoint, bytes.NewBuffer(requestPayload))
+	if err != nil {
+		return []Source{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	var searchResult tavilySearchResponse
+	if err := sendSearchRequest(t.client, req, &searchResult); err != nil {
+		return []Source{}, err
+	}
+
+	var sources []Source
+	for _, result := range searchResult.Results {
+		// Raw content is the full page, fall back to the snippet when missing
+		text := result.RawContent
+		if text == "" {
+			text = result.Content
+		}
+		sources = append(sources, Source{
+			Title:         result.Title,
+			URL:           result.Url,
+			PublishedDate: result.PublishedDate,
+			Text:          text,
+		})
+	}
+	return sources, nil
+}
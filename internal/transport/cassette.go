@@ -0,0 +1,265 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Cassette modes
+const (
+	// CassetteRecord sends requests to the network and records every
+	// interaction to the cassette
+	CassetteRecord = "record"
+	// CassetteReplay answers requests from the cassette without any network
+	// access
+	CassetteReplay = "replay"
+)
+
+// ErrNotRecorded is returned in replay mode for a request that has no
+// recorded interaction left in the cassette.
+var ErrNotRecorded = errors.New("no recorded interaction")
+
+// datePattern matches the dates embedded in prompts, so a cassette recorded
+// on one day still replays on another.
+var datePattern = regexp.MustCompile(`\b(\d{2}/\d{2}/\d{4}|\d{4}-\d{2}-\d{2})\b`)
+
+// secretPattern matches credentials sent in request bodies or query strings,
+// which are never written to a cassette.
+var secretPattern = regexp.MustCompile(`("(?:api_key|apiKey|key)"\s*:\s*)"[^"]*"|\b((?:api_key|apiKey|key)=)[^&]*`)
+
+// redactedHeaders are response headers left out of cassettes.
+var redactedHeaders = map[string]bool{
+	"Set-Cookie":          true,
+	"Authorization":       true,
+	"Openai-Organization": true,
+	"Openai-Project":      true,
+	"Request-Id":          true,
+	"X-Request-Id":        true,
+}
+
+// Cassette is an http.RoundTripper that records HTTP interactions to a JSON
+// file, or replays them from it, so a full research session can run offline
+// and deterministically. Requests are matched by method, URL and body, with
+// credentials and dates masked; identical requests are answered in recorded
+// order, so concurrent requests replay regardless of scheduling.
+type Cassette struct {
+	base http.RoundTripper
+	*tape
+}
+
+// tape holds the interactions of a cassette file. Cassettes created with
+// WithBase share it.
+type tape struct {
+	path string
+	mode string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	// Body holds text bodies and BodyBase64 binary ones, such as PDFs
+	Body       string `json:"body,omitempty"`
+	BodyBase64 string `json:"body_base64,omitempty"`
+}
+
+// NewCassette opens the cassette at path in the given mode. Recording sends
+// requests through base, or http.DefaultTransport when base is nil, and
+// appends to an existing cassette.
+func NewCassette(path, mode string, base http.RoundTripper) (*Cassette, error) {
+	if mode != CassetteRecord && mode != CassetteReplay {
+		return nil, fmt.Errorf("unknown cassette mode %q, expected %s or %s", mode, CassetteRecord, CassetteReplay)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	c := &Cassette{base: base, tape: &tape{path: path, mode: mode}}
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var file cassetteFile
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+		c.interactions = file.Interactions
+	case errors.Is(err, os.ErrNotExist) && mode == CassetteRecord:
+	default:
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	c.used = make([]bool, len(c.interactions))
+	return c, nil
+}
+
+// Base returns the transport requests are recorded from.
+func (c *Cassette) Base() http.RoundTripper {
+	return c.base
+}
+
+// WithBase returns a cassette recording to and replaying from the same file
+// as c, whose requests are recorded from base instead, so a client can apply
+// its own connection policy.
+func (c *Cassette) WithBase(base http.RoundTripper) *Cassette {
+	return &Cassette{base: base, tape: c.tape}
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if c.mode == CassetteReplay {
+		return c.replay(req, recorded)
+	}
+	return c.record(req, recorded)
+}
+
+func (c *Cassette) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	key := matchKey(recorded)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, interaction := range c.interactions {
+		if c.used[i] || matchKey(interaction.Request) != key {
+			continue
+		}
+		c.used[i] = true
+		return interaction.Response.toResponse(req)
+	}
+	return nil, fmt.Errorf("cassette %s: %w for %s %s", c.path, ErrNotRecorded, recorded.Method, recorded.URL)
+}
+
+func (c *Cassette) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		// Network errors are not recorded; replaying them would not be useful
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	response := RecordedResponse{Status: resp.StatusCode, Headers: make(http.Header)}
+	for name, values := range resp.Header {
+		if !redactedHeaders[http.CanonicalHeaderKey(name)] {
+			response.Headers[name] = values
+		}
+	}
+	if utf8.Valid(body) {
+		response.Body = string(body)
+	} else {
+		response.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, Interaction{Request: recorded, Response: response})
+	c.used = append(c.used, true)
+	if err := c.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// save writes the cassette atomically. It must be called with mu held.
+func (c *Cassette) save() error {
+	raw, err := json.MarshalIndent(cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// recordRequest captures req with its credentials redacted, restoring the
+// request body for the real round trip.
+func recordRequest(req *http.Request) (RecordedRequest, error) {
+	recorded := RecordedRequest{
+		Method: req.Method,
+		URL:    secretPattern.ReplaceAllString(req.URL.String(), "${1}${2}REDACTED"),
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return RecordedRequest{}, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	recorded.Body = secretPattern.ReplaceAllString(string(body), `${1}"REDACTED"${2}`)
+	return recorded, nil
+}
+
+// matchKey identifies the requests a recorded interaction can answer.
+func matchKey(req RecordedRequest) string {
+	return strings.Join([]string{req.Method, req.URL, datePattern.ReplaceAllString(req.Body, "DATE")}, "\n")
+}
+
+func (r RecordedResponse) toResponse(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Body)
+	if r.BodyBase64 != "" {
+		var err error
+		body, err = base64.StdEncoding.DecodeString(r.BodyBase64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode recorded body: %w", err)
+		}
+	}
+	header := r.Headers.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
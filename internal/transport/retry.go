@@ -0,0 +1,312 @@
+package transport
+
+import (
+	"context"
+	"deep-research/internal/config"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting a provider while its circuit
+// breaker is open after repeated failures.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// maxRetryAfter caps how long a Retry-After header can make a request wait.
+const maxRetryAfter = 2 * time.Minute
+
+// retryableStatus lists the response statuses worth retrying: rate limits,
+// overloaded servers and transient gateway errors.
+var retryableStatus = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+	529:                            true, // Anthropic overloaded
+}
+
+// Retrying is an http.RoundTripper for a single provider. It retries
+// transient failures with jittered exponential backoff, honoring
+// Retry-After, limits the concurrency and request rate sent to the provider,
+// and stops calling the provider for a while after repeated failures.
+type Retrying struct {
+	name           string
+	base           http.RoundTripper
+	policy         config.RetryPolicy
+	attemptTimeout time.Duration
+
+	// slots limits concurrent requests; nil when unlimited
+	slots chan struct{}
+	// interval is the minimum time between request starts; 0 when unlimited
+	interval time.Duration
+
+	mu        sync.Mutex
+	nextStart time.Time
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// New creates a retrying transport for the provider name, sending requests
+// through base, or http.DefaultTransport when base is nil. A positive
+// attemptTimeout bounds each attempt separately, so backoff does not eat
+// into the time of the next attempt.
+func New(name string, policy config.RetryPolicy, limits config.ProviderLimits, attemptTimeout time.Duration, base http.RoundTripper) *Retrying {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Retrying{
+		name:           name,
+		base:           base,
+		policy:         policy,
+		attemptTimeout: attemptTimeout,
+	}
+	if limits.MaxConcurrent > 0 {
+		t.slots = make(chan struct{}, limits.MaxConcurrent)
+	}
+	if limits.RequestsPerMinute > 0 {
+		t.interval = time.Minute / time.Duration(limits.RequestsPerMinute)
+	}
+	return t
+}
+
+// NewClient returns an HTTP client that sends requests through a retrying
+// transport for the provider name.
+func NewClient(name string, policy config.RetryPolicy, limits config.ProviderLimits, attemptTimeout time.Duration, base http.RoundTripper) *http.Client {
+	return &http.Client{Transport: New(name, policy, limits, attemptTimeout, base)}
+}
+
+func (t *Retrying) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if err := t.allow(); err != nil {
+		return nil, err
+	}
+
+	// Requests whose body cannot be replayed get a single attempt
+	maxRetries := t.policy.MaxRetries
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		maxRetries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req, attempt)
+		if !isRetryable(ctx, resp, err) {
+			// Client errors still show the provider is up; cancellations say
+			// nothing about it
+			t.recordResult(ctx.Err() != nil, true)
+			return resp, err
+		}
+		if attempt >= maxRetries {
+			t.recordResult(false, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: giving up after %d attempts: %w", t.name, attempt+1, err)
+			}
+			return resp, nil
+		}
+
+		delay := t.backoff(attempt, resp)
+		if resp != nil {
+			// Drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, delay); err != nil {
+			t.recordResult(true, false)
+			return nil, err
+		}
+	}
+}
+
+// attempt sends one copy of req once a concurrency slot and a rate limit
+// slot are available.
+func (t *Retrying) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	ctx := req.Context()
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := sync.OnceFunc(func() {
+		if t.slots != nil {
+			<-t.slots
+		}
+	})
+	if err := t.waitForRate(ctx); err != nil {
+		release()
+		return nil, err
+	}
+
+	attemptReq := req
+	cancel := func() {}
+	if t.attemptTimeout > 0 {
+		var attemptCtx context.Context
+		attemptCtx, cancel = context.WithTimeout(ctx, t.attemptTimeout)
+		attemptReq = req.WithContext(attemptCtx)
+	}
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			release()
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		if attemptReq == req {
+			attemptReq = req.Clone(ctx)
+		}
+		attemptReq.Body = body
+	}
+
+	resp, err := t.base.RoundTrip(attemptReq)
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	// The slot and the attempt deadline last until the body is read
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() {
+		cancel()
+		release()
+	}}
+	return resp, nil
+}
+
+// waitForRate blocks until the next request may start under the rate limit.
+func (t *Retrying) waitForRate(ctx context.Context) error {
+	if t.interval == 0 {
+		return nil
+	}
+	t.mu.Lock()
+	now := time.Now()
+	start := t.nextStart
+	if start.Before(now) {
+		start = now
+	}
+	t.nextStart = start.Add(t.interval)
+	t.mu.Unlock()
+	return sleep(ctx, time.Until(start))
+}
+
+// allow fails fast while the circuit breaker is open. Once the cooldown has
+// passed, a single probe request is let through to test the provider.
+func (t *Retrying) allow() error {
+	if t.policy.BreakerThreshold <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failures < t.policy.BreakerThreshold {
+		return nil
+	}
+	if time.Now().Before(t.openUntil) || t.probing {
+		return fmt.Errorf("%s: %w after %d consecutive failures", t.name, ErrCircuitOpen, t.failures)
+	}
+	t.probing = true
+	return nil
+}
+
+// recordResult updates the circuit breaker with the outcome of a request.
+// Abandoned requests only end a probe.
+func (t *Retrying) recordResult(abandoned, ok bool) {
+	if t.policy.BreakerThreshold <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.probing = false
+	if abandoned {
+		return
+	}
+	if ok {
+		t.failures = 0
+		return
+	}
+	t.failures++
+	if t.failures >= t.policy.BreakerThreshold {
+		t.openUntil = time.Now().Add(t.policy.BreakerCooldown)
+	}
+}
+
+// backoff returns the delay before the next attempt: the Retry-After of the
+// response if it has one, otherwise an exponentially growing delay with full
+// jitter.
+func (t *Retrying) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if delay, ok := retryAfter(resp.Header); ok {
+			return min(delay, maxRetryAfter)
+		}
+	}
+	delay := t.policy.BaseDelay << attempt
+	if delay <= 0 || (t.policy.MaxDelay > 0 && delay > t.policy.MaxDelay) {
+		delay = t.policy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay) + 1
+}
+
+// retryAfter parses the Retry-After header, given in seconds or as an HTTP
+// date, or the Retry-After-Ms header some providers send instead.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	if ms, err := strconv.Atoi(header.Get("Retry-After-Ms")); err == nil && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond, true
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// isRetryable reports whether a failed attempt is worth repeating. Errors
+// caused by the caller giving up, or by a replayed cassette missing the
+// request, are not.
+func isRetryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, ErrNotRecorded)
+	}
+	return retryableStatus[resp.StatusCode]
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releasingBody runs release once the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,328 @@
+package transport
+
+import (
+	"context"
+	"deep-research/internal/config"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedServer answers requests with the given statuses in order, then
+// with 200, and records the bodies it received.
+type scriptedServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	headers  []http.Header
+	bodies   []string
+}
+
+func newScriptedServer(t *testing.T, statuses ...int) *scriptedServer {
+	t.Helper()
+	s := &scriptedServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, string(body))
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status = s.statuses[0]
+			s.statuses = s.statuses[1:]
+			if len(s.headers) > 0 {
+				for key, values := range s.headers[0] {
+					w.Header()[key] = values
+				}
+				s.headers = s.headers[1:]
+			}
+		}
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// withHeaders sets the headers of the scripted responses, in order.
+func (s *scriptedServer) withHeaders(headers ...http.Header) *scriptedServer {
+	s.headers = headers
+	return s
+}
+
+func (s *scriptedServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+// get sends a GET request through client, failing the test after a timeout
+// rather than hanging on a long backoff.
+func get(t *testing.T, client *http.Client, url string) (*http.Response, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		want    time.Duration
+		wantOK  bool
+		atLeast bool
+	}{
+		{name: "seconds", header: http.Header{"Retry-After": {"3"}}, want: 3 * time.Second, wantOK: true},
+		{name: "milliseconds", header: http.Header{"Retry-After-Ms": {"250"}, "Retry-After": {"3"}}, want: 250 * time.Millisecond, wantOK: true},
+		{name: "http date", header: http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}, want: 59 * time.Minute, wantOK: true, atLeast: true},
+		{name: "past http date", header: http.Header{"Retry-After": {"Mon, 02 Jan 2006 15:04:05 GMT"}}, want: 0, wantOK: true},
+		{name: "negative seconds", header: http.Header{"Retry-After": {"-1"}}, wantOK: false},
+		{name: "garbage", header: http.Header{"Retry-After": {"soon"}}, wantOK: false},
+		{name: "missing", header: http.Header{}, wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.header)
+			if ok != tt.wantOK {
+				t.Fatalf("retryAfter() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if tt.atLeast && (got < tt.want || got > time.Hour) || !tt.atLeast && got != tt.want {
+				t.Errorf("retryAfter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryingHonorsRetryAfter(t *testing.T) {
+	// The backoff would wait far longer than the test timeout, so only the
+	// Retry-After header can let the retry through in time
+	policy := config.RetryPolicy{MaxRetries: 1, BaseDelay: time.Hour, MaxDelay: time.Hour}
+
+	tests := []struct {
+		name       string
+		retryAfter string
+		minWait    time.Duration
+	}{
+		{name: "seconds", retryAfter: "1", minWait: 900 * time.Millisecond},
+		{name: "http date", retryAfter: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newScriptedServer(t, http.StatusTooManyRequests).withHeaders(http.Header{"Retry-After": {tt.retryAfter}})
+			client := NewClient("test", policy, config.ProviderLimits{}, 0, nil)
+
+			started := time.Now()
+			resp, err := get(t, client, server.URL)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK || len(server.requests()) != 2 {
+				t.Errorf("status %d after %d attempts, want 200 after 2", resp.StatusCode, len(server.requests()))
+			}
+			if waited := time.Since(started); waited < tt.minWait {
+				t.Errorf("retried after %s, want at least %s", waited, tt.minWait)
+			}
+		})
+	}
+}
+
+func TestRetryingStatuses(t *testing.T) {
+	policy := config.RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantStatus   int
+		wantAttempts int
+	}{
+		{name: "server errors retried until success", statuses: []int{503, 502, 500}, wantStatus: 200, wantAttempts: 4},
+		{name: "anthropic overloaded retried", statuses: []int{529}, wantStatus: 200, wantAttempts: 2},
+		{name: "gives up after max retries", statuses: []int{503, 503, 503, 503, 503}, wantStatus: 503, wantAttempts: 4},
+		{name: "bad request not retried", statuses: []int{400}, wantStatus: 400, wantAttempts: 1},
+		{name: "unauthorized not retried", statuses: []int{401}, wantStatus: 401, wantAttempts: 1},
+		{name: "not found not retried", statuses: []int{404}, wantStatus: 404, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newScriptedServer(t, tt.statuses...)
+			client := NewClient("test", policy, config.ProviderLimits{}, 0, nil)
+
+			resp, err := get(t, client, server.URL)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := len(server.requests()); got != tt.wantAttempts {
+				t.Errorf("made %d attempts, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryingReplaysRequestBody(t *testing.T) {
+	server := newScriptedServer(t, 500, 503)
+	client := NewClient("test", config.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}, config.ProviderLimits{}, 0, nil)
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"query":"solar"}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	bodies := server.requests()
+	if len(bodies) != 3 {
+		t.Fatalf("made %d attempts, want 3", len(bodies))
+	}
+	for i, body := range bodies {
+		if body != `{"query":"solar"}` {
+			t.Errorf("attempt %d sent body %q, want the original body", i+1, body)
+		}
+	}
+}
+
+func TestRetryingSendsUnreplayableBodyOnce(t *testing.T) {
+	server := newScriptedServer(t, 503)
+	client := NewClient("test", config.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}, config.ProviderLimits{}, 0, nil)
+
+	// A body without GetBody cannot be rewound for a retry
+	req, err := http.NewRequest(http.MethodPost, server.URL, io.NopCloser(strings.NewReader("once")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || len(server.requests()) != 1 {
+		t.Errorf("status %d after %d attempts, want 503 after 1", resp.StatusCode, len(server.requests()))
+	}
+}
+
+func TestRetryingCircuitBreaker(t *testing.T) {
+	server := newScriptedServer(t, 500, 500, 500)
+	policy := config.RetryPolicy{BreakerThreshold: 2, BreakerCooldown: 50 * time.Millisecond}
+	client := NewClient("test", policy, config.ProviderLimits{}, 0, nil)
+
+	for range 2 {
+		if _, err := get(t, client, server.URL); err != nil {
+			t.Fatalf("request failed before the breaker opened: %v", err)
+		}
+	}
+	if _, err := get(t, client, server.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error = %v, want ErrCircuitOpen", err)
+	}
+	if got := len(server.requests()); got != 2 {
+		t.Fatalf("server got %d requests, want 2 while the breaker is open", got)
+	}
+
+	// After the cooldown a failing probe opens the breaker again
+	time.Sleep(60 * time.Millisecond)
+	if resp, err := get(t, client, server.URL); err != nil || resp.StatusCode != 500 {
+		t.Fatalf("probe = %v, %v, want the scripted 500", resp, err)
+	}
+	if _, err := get(t, client, server.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error after a failed probe = %v, want ErrCircuitOpen", err)
+	}
+
+	// A successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	for i := range 3 {
+		if resp, err := get(t, client, server.URL); err != nil || resp.StatusCode != 200 {
+			t.Fatalf("request %d after recovery = %v, %v, want 200", i+1, resp, err)
+		}
+	}
+	if got := len(server.requests()); got != 6 {
+		t.Errorf("server got %d requests, want 6", got)
+	}
+}
+
+func TestRetryingStopsBackoffOnCancel(t *testing.T) {
+	server := newScriptedServer(t, 503, 503)
+	client := NewClient("test", config.RetryPolicy{MaxRetries: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}, config.ProviderLimits{}, 0, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now()
+	_, err = client.Do(req)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if waited := time.Since(started); waited > 5*time.Second {
+		t.Errorf("returned after %s, want as soon as the context is cancelled", waited)
+	}
+	if got := len(server.requests()); got != 1 {
+		t.Errorf("made %d attempts, want 1", got)
+	}
+}
+
+func TestRetryingLimitsConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if n <= seen || maxInFlight.CompareAndSwap(seen, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+	client := NewClient("test", config.RetryPolicy{}, config.ProviderLimits{MaxConcurrent: 2}, 0, nil)
+
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := get(t, client, server.URL); err != nil {
+				t.Errorf("request failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := maxInFlight.Load(); got != 2 {
+		t.Errorf("%d requests in flight at once, want 2", got)
+	}
+}
+
+func TestRetryingLimitsRate(t *testing.T) {
+	server := newScriptedServer(t)
+	// One request every 50ms
+	client := NewClient("test", config.RetryPolicy{}, config.ProviderLimits{RequestsPerMinute: 1200}, 0, nil)
+
+	started := time.Now()
+	for range 3 {
+		if _, err := get(t, client, server.URL); err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < 100*time.Millisecond {
+		t.Errorf("3 requests took %s, want at least 100ms at 1200 requests per minute", elapsed)
+	}
+}
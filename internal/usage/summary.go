@@ -0,0 +1,110 @@
+package usage
+
+import (
+	"deep-research/internal/config"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Totals aggregates the usage and estimated cost of a group of calls.
+type Totals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CachedTokens     int     `json:"cached_tokens"`
+	CacheWriteTokens int     `json:"cache_write_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	ReasoningTokens  int     `json:"reasoning_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+func (t *Totals) add(r Record, cost float64) {
+	t.Calls++
+	t.PromptTokens += r.PromptTokens
+	t.CachedTokens += r.CachedTokens
+	t.CacheWriteTokens += r.CacheWriteTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.ReasoningTokens += r.ReasoningTokens
+	t.TotalTokens += r.TotalTokens
+	t.CostUSD += cost
+}
+
+// Summary breaks down the usage of a session by stage, search query and model.
+type Summary struct {
+	Total   Totals            `json:"total"`
+	ByStage map[string]Totals `json:"by_stage"`
+	ByQuery map[string]Totals `json:"by_query,omitempty"`
+	ByModel map[string]Totals `json:"by_model"`
+	// UnpricedModels lists models missing from the price table, whose cost is
+	// not included in the totals
+	UnpricedModels []string `json:"unpriced_models,omitempty"`
+}
+
+func summarize(records []Record, prices map[string]config.ModelPrice) Summary {
+	summary := Summary{
+		ByStage: make(map[string]Totals),
+		ByQuery: make(map[string]Totals),
+		ByModel: make(map[string]Totals),
+	}
+	unpriced := make(map[string]bool)
+
+	for _, r := range records {
+		var c float64
+		if price, ok := priceFor(prices, r.Model); ok {
+			c = cost(price, r)
+		} else {
+			unpriced[r.Model] = true
+		}
+
+		summary.Total.add(r, c)
+		addTo(summary.ByStage, r.Stage, r, c)
+		addTo(summary.ByModel, r.Model, r, c)
+		if r.Query != "" {
+			addTo(summary.ByQuery, r.Query, r, c)
+		}
+	}
+
+	for model := range unpriced {
+		summary.UnpricedModels = append(summary.UnpricedModels, model)
+	}
+	sort.Strings(summary.UnpricedModels)
+	return summary
+}
+
+func addTo(groups map[string]Totals, key string, r Record, cost float64) {
+	totals := groups[key]
+	totals.add(r, cost)
+	groups[key] = totals
+}
+
+// String renders the summary as a plain text table.
+func (s Summary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage: %d calls, %d tokens (%d prompt, %d completion, %d reasoning), estimated cost $%.4f\n",
+		s.Total.Calls, s.Total.TotalTokens, s.Total.PromptTokens, s.Total.CompletionTokens, s.Total.ReasoningTokens, s.Total.CostUSD)
+
+	writeGroup(&b, "By stage", s.ByStage)
+	writeGroup(&b, "By model", s.ByModel)
+	writeGroup(&b, "By search query", s.ByQuery)
+
+	if len(s.UnpricedModels) > 0 {
+		fmt.Fprintf(&b, "No price configured for %s; their cost is not included\n", strings.Join(s.UnpricedModels, ", "))
+	}
+	return b.String()
+}
+
+func writeGroup(b *strings.Builder, title string, groups map[string]Totals) {
+	if len(groups) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s:\n", title)
+	for _, key := range sortedKeys(groups) {
+		t := groups[key]
+		label := key
+		if label == "" {
+			label = "(none)"
+		}
+		fmt.Fprintf(b, "  %-40s %4d calls %9d tokens  $%.4f\n", label, t.Calls, t.TotalTokens, t.CostUSD)
+	}
+}
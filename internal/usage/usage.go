@@ -0,0 +1,153 @@
+package usage
+
+import (
+	"context"
+	"deep-research/internal/config"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Record is the token usage of a single model call.
+type Record struct {
+	Stage            string    `json:"stage,omitempty"`
+	Query            string    `json:"query,omitempty"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CachedTokens     int       `json:"cached_tokens,omitempty"`
+	CacheWriteTokens int       `json:"cache_write_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens"`
+	ReasoningTokens  int       `json:"reasoning_tokens,omitempty"`
+	TotalTokens      int       `json:"total_tokens"`
+	Time             time.Time `json:"time"`
+}
+
+// Tracker collects usage records. It is safe for concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	records []Record
+	prices  map[string]config.ModelPrice
+}
+
+// NewTracker creates a tracker that continues from a copy of records and
+// prices them with prices.
+func NewTracker(records []Record, prices map[string]config.ModelPrice) *Tracker {
+	return &Tracker{records: append(make([]Record, 0, len(records)), records...), prices: prices}
+}
+
+func (t *Tracker) Add(record Record) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = append(t.records, record)
+}
+
+// Records returns a copy of the records collected so far.
+func (t *Tracker) Records() []Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append(make([]Record, 0, len(t.records)), t.records...)
+}
+
+// Summary totals the records collected so far.
+func (t *Tracker) Summary() Summary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return summarize(t.records, t.prices)
+}
+
+type trackerKey struct{}
+type stageKey struct{}
+type queryKey struct{}
+
+// WithTracker returns a context that records model usage to tracker.
+func WithTracker(ctx context.Context, tracker *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, tracker)
+}
+
+// WithStage attributes usage recorded with the returned context to stage.
+func WithStage(ctx context.Context, stage string) context.Context {
+	return context.WithValue(ctx, stageKey{}, stage)
+}
+
+// WithQuery attributes usage recorded with the returned context to a search query.
+func WithQuery(ctx context.Context, query string) context.Context {
+	return context.WithValue(ctx, queryKey{}, query)
+}
+
+// Add records the usage of a call to model with the tracker attached to ctx,
+// if any. cacheWriteTokens are the prompt tokens written to the provider's
+// prompt cache, which openai.Usage does not report.
+func Add(ctx context.Context, model string, u openai.Usage, cacheWriteTokens int) {
+	tracker, ok := ctx.Value(trackerKey{}).(*Tracker)
+	if !ok || tracker == nil {
+		return
+	}
+
+	record := Record{
+		Model:            model,
+		PromptTokens:     u.PromptTokens,
+		CacheWriteTokens: cacheWriteTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		Time:             time.Now().UTC(),
+	}
+	if u.PromptTokensDetails != nil {
+		record.CachedTokens = u.PromptTokensDetails.CachedTokens
+	}
+	if u.CompletionTokensDetails != nil {
+		record.ReasoningTokens = u.CompletionTokensDetails.ReasoningTokens
+	}
+	record.Stage, _ = ctx.Value(stageKey{}).(string)
+	record.Query, _ = ctx.Value(queryKey{}).(string)
+	tracker.Add(record)
+}
+
+// priceFor looks up the price of model. Dated or suffixed model names such as
+// gpt-5-2025-08-07 fall back to the longest matching price table entry.
+func priceFor(prices map[string]config.ModelPrice, model string) (config.ModelPrice, bool) {
+	if price, ok := prices[model]; ok {
+		return price, true
+	}
+
+	best := ""
+	for name := range prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return config.ModelPrice{}, false
+	}
+	return prices[best], true
+}
+
+// cost returns the price in USD of a record. Cached and cache write tokens
+// are part of the prompt tokens but priced at their own rates, or at the
+// input price when the model has none.
+func cost(price config.ModelPrice, r Record) float64 {
+	cachedPrice := price.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = price.Input
+	}
+	cacheWritePrice := price.CacheWriteInput
+	if cacheWritePrice == 0 {
+		cacheWritePrice = price.Input
+	}
+	uncached := r.PromptTokens - r.CachedTokens - r.CacheWriteTokens
+	return (float64(uncached)*price.Input +
+		float64(r.CachedTokens)*cachedPrice +
+		float64(r.CacheWriteTokens)*cacheWritePrice +
+		float64(r.CompletionTokens)*price.Output) / 1_000_000
+}
+
+func sortedKeys(m map[string]Totals) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
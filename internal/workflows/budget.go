@@ -0,0 +1,114 @@
+package workflows
+
+import (
+	"deep-research/internal/config"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ResearchUsage is the part of the research budget consumed so far.
+type ResearchUsage struct {
+	SearchCalls int `json:"search_calls"`
+	Iterations  int `json:"iterations"`
+	Tokens      int `json:"tokens"`
+}
+
+// Exhausted reports whether any limit of budget has been reached, and which.
+// elapsed is the wall-clock time spent researching.
+func (u ResearchUsage) Exhausted(budget config.ResearchBudget, elapsed time.Duration) (string, bool) {
+	switch {
+	case budget.MaxSearchCalls > 0 && u.SearchCalls >= budget.MaxSearchCalls:
+		return fmt.Sprintf("search call limit of %d reached", budget.MaxSearchCalls), true
+	case budget.MaxIterations > 0 && u.Iterations >= budget.MaxIterations:
+		return fmt.Sprintf("iteration limit of %d reached", budget.MaxIterations), true
+	case budget.MaxTokens > 0 && u.Tokens >= budget.MaxTokens:
+		return fmt.Sprintf("token limit of %d reached", budget.MaxTokens), true
+	case budget.MaxDuration > 0 && elapsed >= budget.MaxDuration:
+		return fmt.Sprintf("time limit of %s reached", budget.MaxDuration), true
+	}
+	return "", false
+}
+
+// SharedBudget accounts the research budget consumed by every research agent
+// of a session. Each agent is held to an equal share of the search, iteration
+// and token limits, and the limits themselves cap the session as a whole. It
+// is safe for concurrent use.
+type SharedBudget struct {
+	mu     sync.Mutex
+	budget config.ResearchBudget
+	share  config.ResearchBudget
+	used   ResearchUsage
+}
+
+// NewSharedBudget creates a budget shared by agents research agents, with
+// used already consumed, e.g. before the session was resumed.
+func NewSharedBudget(budget config.ResearchBudget, agents int, used ResearchUsage) *SharedBudget {
+	agents = max(agents, 1)
+	share := budget
+	share.MaxSearchCalls = splitLimit(budget.MaxSearchCalls, agents)
+	share.MaxIterations = splitLimit(budget.MaxIterations, agents)
+	share.MaxTokens = splitLimit(budget.MaxTokens, agents)
+	return &SharedBudget{budget: budget, share: share, used: used}
+}
+
+// splitLimit divides limit evenly across agents, rounding up so the shares
+// add up to at least the limit. A limit of 0 stays unlimited.
+func splitLimit(limit, agents int) int {
+	if limit <= 0 {
+		return 0
+	}
+	return (limit + agents - 1) / agents
+}
+
+// Used returns the budget consumed so far.
+func (b *SharedBudget) Used() ResearchUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Share returns the limits each agent is held to.
+func (b *SharedBudget) Share() config.ResearchBudget {
+	return b.share
+}
+
+// StartIteration reserves a research loop iteration for an agent that has
+// consumed agent so far. It reports whether the session or the agent has
+// reached any limit instead, and which. elapsed is the wall-clock time spent
+// researching.
+func (b *SharedBudget) StartIteration(agent ResearchUsage, elapsed time.Duration) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if reason, exhausted := b.used.Exhausted(b.budget, elapsed); exhausted {
+		return reason, true
+	}
+	if reason, exhausted := agent.Exhausted(b.share, 0); exhausted {
+		return "agent " + reason, true
+	}
+	b.used.Iterations++
+	return "", false
+}
+
+// ReserveSearch reserves a search call for an agent that has consumed agent
+// so far, reporting false once the agent has used its share of the search
+// calls or the session has reached the search call limit.
+func (b *SharedBudget) ReserveSearch(agent ResearchUsage) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.share.MaxSearchCalls > 0 && agent.SearchCalls >= b.share.MaxSearchCalls {
+		return false
+	}
+	if b.budget.MaxSearchCalls > 0 && b.used.SearchCalls >= b.budget.MaxSearchCalls {
+		return false
+	}
+	b.used.SearchCalls++
+	return true
+}
+
+// AddTokens records tokens used by an agent.
+func (b *SharedBudget) AddTokens(tokens int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used.Tokens += tokens
+}
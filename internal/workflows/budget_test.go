@@ -0,0 +1,85 @@
+package workflows
+
+import (
+	"deep-research/internal/config"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSharedBudgetHoldsAcrossAgents(t *testing.T) {
+	searchBudget := NewSharedBudget(config.ResearchBudget{MaxSearchCalls: 7}, 4, ResearchUsage{})
+	iterationBudget := NewSharedBudget(config.ResearchBudget{MaxIterations: 5}, 4, ResearchUsage{Iterations: 1})
+
+	var searches, iterations atomic.Int32
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var searcher, iterator ResearchUsage
+			for range 10 {
+				if searchBudget.ReserveSearch(searcher) {
+					searcher.SearchCalls++
+					searches.Add(1)
+				}
+				if _, exhausted := iterationBudget.StartIteration(iterator, 0); !exhausted {
+					iterator.Iterations++
+					iterations.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := searches.Load(); got != 7 {
+		t.Errorf("reserved %d searches, want 7", got)
+	}
+	if got := iterations.Load(); got != 4 {
+		t.Errorf("started %d iterations, want 4 on top of the 1 already used", got)
+	}
+	if got := searchBudget.Share().MaxSearchCalls; got != 2 {
+		t.Errorf("Share().MaxSearchCalls = %d, want 2", got)
+	}
+}
+
+func TestSharedBudgetHoldsAgentToItsShare(t *testing.T) {
+	budget := NewSharedBudget(config.ResearchBudget{MaxSearchCalls: 6, MaxTokens: 3000}, 3, ResearchUsage{})
+
+	var greedy ResearchUsage
+	for range 5 {
+		if budget.ReserveSearch(greedy) {
+			greedy.SearchCalls++
+		}
+	}
+	if greedy.SearchCalls != 2 {
+		t.Errorf("greedy agent reserved %d searches, want its share of 2", greedy.SearchCalls)
+	}
+	if reason, exhausted := budget.StartIteration(greedy, 0); !exhausted || reason != "agent search call limit of 2 reached" {
+		t.Errorf("StartIteration() = %q, %v, want the agent search call limit", reason, exhausted)
+	}
+
+	greedy = ResearchUsage{Tokens: 1000}
+	if reason, exhausted := budget.StartIteration(greedy, 0); !exhausted || reason != "agent token limit of 1000 reached" {
+		t.Errorf("StartIteration() = %q, %v, want the agent token limit", reason, exhausted)
+	}
+
+	// The other agents still get their share
+	if _, exhausted := budget.StartIteration(ResearchUsage{}, 0); exhausted {
+		t.Error("StartIteration() refused an agent that used nothing")
+	}
+	for range 2 {
+		var other ResearchUsage
+		for range 5 {
+			if budget.ReserveSearch(other) {
+				other.SearchCalls++
+			}
+		}
+		if other.SearchCalls != 2 {
+			t.Errorf("other agent reserved %d searches, want its share of 2", other.SearchCalls)
+		}
+	}
+	if got := budget.Used().SearchCalls; got != 6 {
+		t.Errorf("session used %d searches, want 6", got)
+	}
+}
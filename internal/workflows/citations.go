@@ -0,0 +1,262 @@
+package workflows
+
+import (
+	"deep-research/internal/tools"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReportSource is a unique source offered to the report writer, identified by
+// the ID the writer uses to cite it. Notes that share a URL share a source.
+type ReportSource struct {
+	ID     int            `json:"id"`
+	Source tools.Source   `json:"source"`
+	Notes  []ResearchNote `json:"notes"`
+}
+
+// Citation ties an inline [n] marker in the report to one of the sources
+// offered to the report writer.
+type Citation struct {
+	Marker   int `json:"marker" jsonschema:"title=marker,description=the number n used for the inline [n] marker in the report"`
+	SourceID int `json:"source_id" jsonschema:"title=source id,description=the id of the source in <FINDINGS> that the marker refers to"`
+}
+
+// CitedSource is an entry in the report bibliography.
+type CitedSource struct {
+	Number int          `json:"number"`
+	Source tools.Source `json:"source"`
+}
+
+// ResearchReport is the final report with its citations resolved against the
+// sources fetched during web research.
+type ResearchReport struct {
+	// Body is the Markdown report with sequential [n] markers
+	Body string `json:"body"`
+	// Sources lists the cited sources ordered by citation number
+	Sources []CitedSource `json:"sources"`
+}
+
+// String renders the report as Markdown with a trailing Sources section.
+func (r ResearchReport) String() string {
+	if len(r.Sources) == 0 {
+		return r.Body
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.TrimRight(r.Body, "\n"))
+	sb.WriteString("\n\n## Sources\n\n")
+	for _, cited := range r.Sources {
+		title := cited.Source.Title
+		if title == "" {
+			title = cited.Source.URL
+		}
+		fmt.Fprintf(&sb, "%d. [%s](%s)", cited.Number, title, cited.Source.URL)
+		if cited.Source.PublishedDate != "" {
+			fmt.Fprintf(&sb, ", published %s", cited.Source.PublishedDate)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// groupNotesBySource assigns a sequential ID to every unique source URL and
+// groups the notes taken from it.
+func groupNotesBySource(notes []ResearchNote) []ReportSource {
+	var sources []ReportSource
+	byURL := make(map[string]int)
+	for _, note := range notes {
+		if idx, ok := byURL[note.Source.URL]; ok && note.Source.URL != "" {
+			sources[idx].Notes = append(sources[idx].Notes, note)
+			continue
+		}
+		byURL[note.Source.URL] = len(sources)
+		sources = append(sources, ReportSource{
+			ID:     len(sources) + 1,
+			Source: note.Source,
+			Notes:  []ResearchNote{note},
+		})
+	}
+	return sources
+}
+
+// citationMarkerPattern matches inline markers such as [1] or [2, 3].
+var citationMarkerPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// resolveCitations validates the inline markers in body against citations
+// and sources. Only markers the writer listed in citations are rewritten:
+// they are renumbered sequentially in order of first use, numbers whose
+// source was not fetched are dropped from the marker, and runs of adjacent
+// markers are sorted and deduplicated. A marker left empty is removed with
+// the whitespace before it. Bracketed numbers that are not citations, such as
+// arr[0] or [2024], and anything inside code spans or fenced blocks are left
+// untouched. The returned slice lists the cited numbers that could not be
+// resolved to a fetched source.
+func resolveCitations(body string, citations []Citation, sources []ReportSource) (ResearchReport, []int) {
+	sourceByID := make(map[int]tools.Source, len(sources))
+	for _, source := range sources {
+		sourceByID[source.ID] = source.Source
+	}
+	cited := make(map[int]bool, len(citations))
+	sourceIDByMarker := make(map[int]int, len(citations))
+	for _, citation := range citations {
+		cited[citation.Marker] = true
+		if _, ok := sourceByID[citation.SourceID]; ok {
+			sourceIDByMarker[citation.Marker] = citation.SourceID
+		}
+	}
+
+	var report ResearchReport
+	numberBySourceID := make(map[int]int)
+	unresolved := make(map[int]bool)
+	code := codeRanges(body)
+
+	// A run is a sequence of adjacent citation markers rewritten as one
+	type run struct {
+		start, end int
+		numbers    []int
+	}
+	var runs []run
+	for _, loc := range citationMarkerPattern.FindAllStringSubmatchIndex(body, -1) {
+		// Skip Markdown links whose text happens to be numeric, e.g. [1](https://...)
+		if loc[1] < len(body) && body[loc[1]] == '(' {
+			continue
+		}
+		if inRanges(code, loc[0]) {
+			continue
+		}
+
+		var markers []int
+		isCitation := false
+		for _, part := range strings.Split(body[loc[2]:loc[3]], ",") {
+			marker, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			markers = append(markers, marker)
+			isCitation = isCitation || cited[marker]
+		}
+		if !isCitation {
+			continue
+		}
+
+		var numbers []int
+		for _, marker := range markers {
+			sourceID, ok := sourceIDByMarker[marker]
+			if !ok {
+				unresolved[marker] = true
+				continue
+			}
+			number, ok := numberBySourceID[sourceID]
+			if !ok {
+				number = len(report.Sources) + 1
+				numberBySourceID[sourceID] = number
+				report.Sources = append(report.Sources, CitedSource{Number: number, Source: sourceByID[sourceID]})
+			}
+			numbers = append(numbers, number)
+		}
+
+		if n := len(runs); n > 0 && runs[n-1].end == loc[0] {
+			runs[n-1].end = loc[1]
+			runs[n-1].numbers = append(runs[n-1].numbers, numbers...)
+			continue
+		}
+		runs = append(runs, run{start: loc[0], end: loc[1], numbers: numbers})
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, r := range runs {
+		sort.Ints(r.numbers)
+		numbers := slices.Compact(r.numbers)
+		if len(numbers) == 0 {
+			// Drop the space before a removed marker, e.g. "Claim [9]." -> "Claim."
+			sb.WriteString(strings.TrimRight(body[last:r.start], " \t"))
+		} else {
+			sb.WriteString(body[last:r.start])
+		}
+		for _, number := range numbers {
+			fmt.Fprintf(&sb, "[%d]", number)
+		}
+		last = r.end
+	}
+	sb.WriteString(body[last:])
+	report.Body = sb.String()
+
+	unresolvedMarkers := make([]int, 0, len(unresolved))
+	for marker := range unresolved {
+		unresolvedMarkers = append(unresolvedMarkers, marker)
+	}
+	sort.Ints(unresolvedMarkers)
+
+	return report, unresolvedMarkers
+}
+
+// codeRanges returns the byte ranges of fenced code blocks and inline code
+// spans in the Markdown body, in order.
+func codeRanges(body string) [][2]int {
+	var ranges [][2]int
+	fence := ""
+	fenceStart := 0
+	for offset := 0; offset < len(body); {
+		end := strings.IndexByte(body[offset:], '\n')
+		if end < 0 {
+			end = len(body)
+		} else {
+			end += offset + 1
+		}
+		line := strings.TrimLeft(body[offset:end], " ")
+		switch {
+		case fence != "":
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				ranges = append(ranges, [2]int{fenceStart, end})
+				fence = ""
+			}
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			fence = line[:3]
+			fenceStart = offset
+		default:
+			ranges = append(ranges, codeSpans(body[offset:end], offset)...)
+		}
+		offset = end
+	}
+	if fence != "" {
+		ranges = append(ranges, [2]int{fenceStart, len(body)})
+	}
+	return ranges
+}
+
+// codeSpans returns the byte ranges of the backtick code spans in line,
+// shifted by offset.
+func codeSpans(line string, offset int) [][2]int {
+	var ranges [][2]int
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			i++
+			continue
+		}
+		run := i
+		for run < len(line) && line[run] == '`' {
+			run++
+		}
+		ticks := line[i:run]
+		closing := strings.Index(line[run:], ticks)
+		if closing < 0 {
+			i = run
+			continue
+		}
+		end := run + closing + len(ticks)
+		ranges = append(ranges, [2]int{offset + i, offset + end})
+		i = end
+	}
+	return ranges
+}
+
+// inRanges reports whether pos falls inside one of the ordered ranges.
+func inRanges(ranges [][2]int, pos int) bool {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i][1] > pos })
+	return i < len(ranges) && ranges[i][0] <= pos
+}
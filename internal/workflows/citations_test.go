@@ -0,0 +1,84 @@
+package workflows
+
+import (
+	"deep-research/internal/tools"
+	"reflect"
+	"testing"
+)
+
+func TestResolveCitations(t *testing.T) {
+	sources := []ReportSource{
+		{ID: 1, Source: tools.Source{URL: "https://a.example"}},
+		{ID: 2, Source: tools.Source{URL: "https://b.example"}},
+	}
+	citations := []Citation{
+		{Marker: 1, SourceID: 2},
+		{Marker: 2, SourceID: 1},
+		{Marker: 3, SourceID: 9},
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		want       string
+		wantURLs   []string
+		unresolved []int
+	}{
+		{
+			name:     "renumbers in order of first use",
+			body:     "First [2]. Second [1]. Both [1, 2].",
+			want:     "First [1]. Second [2]. Both [1][2].",
+			wantURLs: []string{"https://a.example", "https://b.example"},
+		},
+		{
+			name:       "drops cited markers with unknown sources",
+			body:       "Claim [3]. Other [1, 3]. Again\t[3] here.",
+			want:       "Claim. Other [1]. Again here.",
+			wantURLs:   []string{"https://b.example"},
+			unresolved: []int{3},
+		},
+		{
+			name:       "sorts and dedupes adjacent markers",
+			body:       "First [2]. Run [1][2][1]. Group [2, 1, 2][3].",
+			want:       "First [1]. Run [1][2]. Group [1][2].",
+			wantURLs:   []string{"https://a.example", "https://b.example"},
+			unresolved: []int{3},
+		},
+		{
+			name:     "keeps bracketed numbers that are not citations",
+			body:     "Use arr[0] as of [2024] [1].",
+			want:     "Use arr[0] as of [2024] [1].",
+			wantURLs: []string{"https://b.example"},
+		},
+		{
+			name:     "skips code spans and fences",
+			body:     "Call `xs[2]` here [2].\n\n```go\nx := ys[1]\n```\n",
+			want:     "Call `xs[2]` here [1].\n\n```go\nx := ys[1]\n```\n",
+			wantURLs: []string{"https://a.example"},
+		},
+		{
+			name: "skips numeric link text",
+			body: "See [1](https://c.example).",
+			want: "See [1](https://c.example).",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, unresolved := resolveCitations(tt.body, citations, sources)
+			if report.Body != tt.want {
+				t.Errorf("body = %q, want %q", report.Body, tt.want)
+			}
+			var urls []string
+			for _, cited := range report.Sources {
+				urls = append(urls, cited.Source.URL)
+			}
+			if !reflect.DeepEqual(urls, tt.wantURLs) {
+				t.Errorf("sources = %v, want %v", urls, tt.wantURLs)
+			}
+			if len(unresolved) != len(tt.unresolved) || (len(unresolved) > 0 && !reflect.DeepEqual(unresolved, tt.unresolved)) {
+				t.Errorf("unresolved = %v, want %v", unresolved, tt.unresolved)
+			}
+		})
+	}
+}
@@ -2,90 +2,18 @@ package workflows
 
 import (
 	"context"
+	"deep-research/internal/config"
+	"deep-research/internal/llm"
 	"fmt"
 	"log/slog"
 	"time"
 
-	"github.com/instructor-ai/instructor-go/pkg/instructor"
 	"github.com/sashabaranov/go-openai"
 )
 
-var clarifyWithUserPrompt string = `
-<ROLE>
-You are tasked with analyzing a user's message history to determine whether further clarification is required before beginning research.
-</ROLE>
-
-<DATE>
-For context, today's date is {{ .Date }}.
-</DATE>
-
-<INSTRUCTIONS>
-- Review the provided message history.
-- Only ask clarifying questions when essential details are missing (e.g., unclear scope, missing parameters).
-- Avoid repeating questions already asked in the message history unless new ambiguity arises.
-- Request clarification for:
-  * Undefined acronyms or abbreviations
-  * Terms not found in standard dictionaries or field glossaries
-  * Any references that could affect understanding user intent
-- Do not ask about information already provided.
-- When asking questions:
-  * Be concise and well-structured
-  * Use bullet points or numbered lists when appropriate
-  * Stay focused and avoid redundancy
-
-<MINIMUM_INFORMATION>
-Proceed with research only when scope, context, parameters, and terminology are clear and unambiguous. Otherwise, seek clarification.
-</MINIMUM_INFORMATION>
-
-<MESSAGES>
-[
-{{range $index, $message := .Messages}}
-{"role": "{{$message.Role}}", "content": "{{$message.Content}}"}
-{{end}}
-]
-</MESSAGES>
-
-<OUTPUT_FORMAT>
-Respond with JSON following this schema:
-{
-    "need_clarification": boolean,
-    "question": "string",
-    "verification": "string"
-}
-
-When clarification needed:
-- Set need_clarification: true
-- Provide clear questions in question field
-- Leave verification empty
-
-When no clarification needed:
-- Set need_clarification: false
-- Leave question empty
-- Provide verification message that:
-  * Confirms sufficient information received
-  * Summarizes understanding
-  * States research will begin
-</OUTPUT_FORMAT>
-
-<EXAMPLES>
-Clarification needed:
-{
-    "need_clarification": true,
-    "question": "Please clarify the meaning of [term] and specify [missing detail]",
-    "verification": ""
-}
-
-No clarification needed:
-{
-    "need_clarification": false,
-    "question": "",
-    "verification": "Information complete for [scope]. Will research [specific topic/parameters] as requested."
-}
-</EXAMPLES>
-`
-
 type ClarifyWithUserWorkflow struct {
-	client   *instructor.InstructorOpenAI
+	client   llm.Provider
+	stage    config.StageConfig
 	logger   *slog.Logger
 	messages *[]openai.ChatCompletionMessage
 }
@@ -96,9 +24,10 @@ type ClarifyWithUserOutputSchema struct {
 	Verification      string `json:"verification" jsonschema:"title=verification,description=the verification message to confirm sufficient information received,example=Information complete for [scope]. Will research [specific topic/parameters] as requested."`
 }
 
-func NewClarifyWithUser(messages *[]openai.ChatCompletionMessage, client *instructor.InstructorOpenAI, logger *slog.Logger) ChatSessionWorkflow {
+func NewClarifyWithUser(messages *[]openai.ChatCompletionMessage, client llm.Provider, stage config.StageConfig, logger *slog.Logger) ChatSessionWorkflow {
 	return &ClarifyWithUserWorkflow{
 		client:   client,
+		stage:    stage,
 		logger:   logger,
 		messages: messages,
 	}
@@ -115,21 +44,18 @@ func (cwu *ClarifyWithUserWorkflow) Execute(ctx context.Context) (any, bool, err
 		Date:     time.Now().Format("02/01/2006"),
 		Messages: *cwu.messages,
 	}
-	prompt, err := PromptBuilder("clarify_with_user", clarifyWithUserPrompt, data)
+	prompt, err := RenderPrompt(ctx, PromptClarifyWithUser, data)
 	if err != nil {
 		return "", false, err
 	}
 
 	var ClarifyWithUserResponse ClarifyWithUserOutputSchema
-	resp, err := cwu.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: openai.GPT5,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
+	resp, err := cwu.client.CreateStructuredCompletion(ctx, NewChatCompletionRequest(cwu.stage, []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: prompt,
 		},
-	}, &ClarifyWithUserResponse)
+	}), &ClarifyWithUserResponse)
 	_ = resp
 	if err != nil {
 		return "", false, fmt.Errorf("failed to create chat completion: %w", err)
@@ -3,8 +3,12 @@ package workflows
 import (
 	"bytes"
 	"context"
+	"deep-research/internal/config"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"text/template"
+	"unicode/utf8"
 
 	"github.com/sashabaranov/go-openai"
 )
@@ -26,13 +30,24 @@ type TemplateData struct {
 	// RawResearchNote contains the raw research note from the web search tool
 	RawResearchNote string `json:"raw_research_note"`
 
-	// CompressedResearchNote contains the compressed research note from the web search tool
-	CompressedResearchNotes []string `json:"compressed_research_notes"`
+	// CompressedResearchNote contains the compressed research notes and their sources
+	CompressedResearchNotes []ResearchNote `json:"compressed_research_notes"`
+	// Sources contains the unique sources behind the research notes, numbered for citation
+	Sources []ReportSource `json:"sources"`
+
+	// MaxSearchCalls is the enforced search call budget of the web research loop
+	MaxSearchCalls int `json:"max_search_calls"`
+
+	// LocalSearch reports whether the research agent can search the local document collection
+	LocalSearch bool `json:"local_search"`
+
+	// MaxSubtopics is the number of subtopics the supervisor may split the brief into
+	MaxSubtopics int `json:"max_subtopics"`
 }
 
 func PromptBuilder(templateName, templateStr string, data any) (string, error) {
 	// Parse the template
-	tmpl, err := template.New(templateName).Parse(templateStr)
+	tmpl, err := newPromptTemplate(templateName, templateStr)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -48,6 +63,50 @@ func PromptBuilder(templateName, templateStr string, data any) (string, error) {
 	return buf.String(), nil
 }
 
+// promptFuncs are the helper functions available to prompt templates, so
+// text from users and web pages is rendered unambiguously:
+//
+//	json     encodes a value as JSON, e.g. a quoted and escaped string
+//	xml      escapes text for use between XML tags
+//	truncate shortens text to at most n characters: {{$note.KeyExcerpts | truncate 2000 | xml}}
+var promptFuncs = template.FuncMap{
+	"json":     jsonString,
+	"xml":      xmlEscape,
+	"truncate": truncate,
+}
+
+// newPromptTemplate parses a prompt template with the prompt helper functions.
+func newPromptTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(promptFuncs).Parse(text)
+}
+
+// jsonString encodes v as JSON. <, > and & are escaped as well, so encoded
+// text cannot close the XML tag around it.
+func jsonString(v any) (string, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// xmlEscaper escapes only what could open or close a tag. Quotes and
+// newlines are kept, so pages and notes reach the model as readable text.
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func xmlEscape(text string) string {
+	return xmlEscaper.Replace(text)
+}
+
+// truncate keeps the first n characters of text, marking the cut with an
+// ellipsis.
+func truncate(n int, text string) string {
+	if n < 0 || utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n]) + "…"
+}
+
 func BuildConversationHistory(systemPrompt *string, pastMessages *[]openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
 	conversationHistory := make([]openai.ChatCompletionMessage, 0, len(*pastMessages)+1)
 
@@ -60,3 +119,18 @@ func BuildConversationHistory(systemPrompt *string, pastMessages *[]openai.ChatC
 
 	return append(conversationHistory, *pastMessages...)
 }
+
+// NewChatCompletionRequest builds a chat completion request using the model
+// settings configured for a pipeline stage.
+func NewChatCompletionRequest(stage config.StageConfig, messages []openai.ChatCompletionMessage) openai.ChatCompletionRequest {
+	req := openai.ChatCompletionRequest{
+		Model:           stage.Model,
+		Messages:        messages,
+		Temperature:     stage.Temperature,
+		ReasoningEffort: stage.ReasoningEffort,
+	}
+	if stage.MaxTokens > 0 {
+		req.MaxCompletionTokens = stage.MaxTokens
+	}
+	return req
+}
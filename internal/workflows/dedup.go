@@ -0,0 +1,253 @@
+package workflows
+
+import (
+	"context"
+	"deep-research/internal/tools"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// shingleSize is the number of consecutive words hashed together
+	shingleSize = 3
+	// minFingerprintWords is the shortest text worth fingerprinting; short
+	// snippets of different pages look too much alike
+	minFingerprintWords = 50
+	// maxNearDuplicateDistance is the number of differing simhash bits up to
+	// which two texts are considered near duplicates
+	maxNearDuplicateDistance = 3
+)
+
+// SourceIndex remembers the sources summarized during a session, so the
+// same page found by different searches, or mirrored under another URL, is
+// summarized only once. It is shared by the sub-researchers of a session and
+// is safe for concurrent use.
+//
+// A source claimed by Filter stays pending until its claimant calls Done
+// once its note is stored, or Remove when summarizing it failed. Other
+// callers finding a pending source wait for it with Wait and filter it again,
+// taking it over if it was removed.
+type SourceIndex struct {
+	mu   sync.Mutex
+	urls map[string]*indexEntry
+	// fingerprinted lists the entries with a fingerprint, for near duplicate
+	// matching
+	fingerprinted []*indexEntry
+}
+
+// indexEntry is a source in the index.
+type indexEntry struct {
+	// url is the canonical URL, empty for sources without one
+	url         string
+	fingerprint uint64
+	stored      bool
+	// settled is closed once the source is stored or removed
+	settled chan struct{}
+}
+
+// NewSourceIndex creates an index of the sources behind notes, so a resumed
+// session keeps skipping the sources it already summarized.
+func NewSourceIndex(notes []ResearchNote) *SourceIndex {
+	index := &SourceIndex{urls: make(map[string]*indexEntry)}
+	for _, note := range notes {
+		index.add(note.Source.URL, note.Fingerprint, true)
+	}
+	return index
+}
+
+// Filter splits sources into those not seen before, which the caller claims
+// and must settle with Done or Remove, those already stored, and those
+// pending in another caller, or earlier in sources. Duplicates are matched by
+// canonical URL, or by near-identical text.
+func (x *SourceIndex) Filter(sources []tools.Source) (fresh, seen, pending []tools.Source) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, source := range sources {
+		fingerprint := simhash(source.Text)
+		entry := x.find(source.URL, fingerprint)
+		switch {
+		case entry == nil:
+			x.add(source.URL, fingerprint, false)
+			fresh = append(fresh, source)
+		case entry.stored:
+			seen = append(seen, source)
+		default:
+			pending = append(pending, source)
+		}
+	}
+	return fresh, seen, pending
+}
+
+// Wait blocks until none of sources is pending. Callers must settle their
+// own claims first, so two callers never wait on each other.
+func (x *SourceIndex) Wait(ctx context.Context, sources []tools.Source) error {
+	x.mu.Lock()
+	var settled []chan struct{}
+	for _, source := range sources {
+		if entry := x.find(source.URL, simhash(source.Text)); entry != nil && !entry.stored {
+			settled = append(settled, entry.settled)
+		}
+	}
+	x.mu.Unlock()
+
+	for _, ch := range settled {
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Done marks claimed sources as stored, so later callers skip them.
+func (x *SourceIndex) Done(sources ...tools.Source) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, source := range sources {
+		if entry := x.entry(source); entry != nil {
+			entry.store()
+		}
+	}
+}
+
+// Add records sources as stored without filtering them.
+func (x *SourceIndex) Add(sources ...tools.Source) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, source := range sources {
+		if entry := x.entry(source); entry != nil {
+			entry.store()
+			continue
+		}
+		x.add(source.URL, simhash(source.Text), true)
+	}
+}
+
+// Remove forgets sources, so they are summarized if found again. It releases
+// the claims of Filter on sources whose summarization failed, and callers
+// waiting on them take them over.
+func (x *SourceIndex) Remove(sources ...tools.Source) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	removed := make(map[*indexEntry]bool, len(sources))
+	for _, source := range sources {
+		entry := x.entry(source)
+		if entry == nil || removed[entry] {
+			continue
+		}
+		removed[entry] = true
+		if entry.url != "" {
+			delete(x.urls, entry.url)
+		}
+		if !entry.stored {
+			close(entry.settled)
+		}
+	}
+	kept := x.fingerprinted[:0]
+	for _, entry := range x.fingerprinted {
+		if !removed[entry] {
+			kept = append(kept, entry)
+		}
+	}
+	x.fingerprinted = kept
+}
+
+// add records a source. Sources without a URL are only recorded by their
+// fingerprint, so they are not all mistaken for one another.
+func (x *SourceIndex) add(rawURL string, fingerprint uint64, stored bool) {
+	entry := &indexEntry{fingerprint: fingerprint, settled: make(chan struct{})}
+	if stored {
+		entry.store()
+	}
+	if strings.TrimSpace(rawURL) != "" {
+		entry.url = tools.CanonicalURL(rawURL)
+		x.urls[entry.url] = entry
+	}
+	if fingerprint != 0 {
+		x.fingerprinted = append(x.fingerprinted, entry)
+	}
+}
+
+// find returns the entry source duplicates, if any.
+func (x *SourceIndex) find(rawURL string, fingerprint uint64) *indexEntry {
+	if strings.TrimSpace(rawURL) != "" {
+		if entry, ok := x.urls[tools.CanonicalURL(rawURL)]; ok {
+			return entry
+		}
+	}
+	if fingerprint == 0 {
+		return nil
+	}
+	for _, entry := range x.fingerprinted {
+		if bits.OnesCount64(entry.fingerprint^fingerprint) <= maxNearDuplicateDistance {
+			return entry
+		}
+	}
+	return nil
+}
+
+// entry returns the entry recorded for source itself: the entry of its URL,
+// or for sources without a URL, the entry of its exact fingerprint.
+func (x *SourceIndex) entry(source tools.Source) *indexEntry {
+	if strings.TrimSpace(source.URL) != "" {
+		return x.urls[tools.CanonicalURL(source.URL)]
+	}
+	fingerprint := simhash(source.Text)
+	if fingerprint == 0 {
+		return nil
+	}
+	for _, entry := range x.fingerprinted {
+		if entry.url == "" && entry.fingerprint == fingerprint {
+			return entry
+		}
+	}
+	return nil
+}
+
+// store marks the entry as stored, waking the callers waiting on it.
+func (e *indexEntry) store() {
+	if !e.stored {
+		e.stored = true
+		close(e.settled)
+	}
+}
+
+// simhash returns a 64-bit fingerprint of text built from its word
+// shingles, such that similar texts have fingerprints differing in few bits.
+// It returns 0 for texts too short to fingerprint reliably.
+func simhash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) < minFingerprintWords {
+		return 0
+	}
+
+	var weights [64]int
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
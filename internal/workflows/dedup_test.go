@@ -0,0 +1,114 @@
+package workflows
+
+import (
+	"context"
+	"deep-research/internal/tools"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// longText returns a text long enough to be fingerprinted, about topic.
+func longText(topic string) string {
+	var words []string
+	for i := range minFingerprintWords + 10 {
+		words = append(words, fmt.Sprintf("%s%d", topic, i))
+	}
+	return strings.Join(words, " ")
+}
+
+func TestSourceIndexFilter(t *testing.T) {
+	index := NewSourceIndex([]ResearchNote{{Source: tools.Source{URL: "https://example.com/solar"}}})
+
+	fresh, seen, pending := index.Filter([]tools.Source{
+		{URL: "https://www.example.com/solar/", Text: "same page, other URL form"},
+		{URL: "", Text: "a snippet without a URL"},
+		{URL: "", Text: "another snippet without a URL"},
+		{URL: "https://mirror.example.org/wind", Text: longText("wind")},
+		{URL: "https://example.net/wind-copy", Text: longText("wind")},
+		{URL: "", Text: longText("wind")},
+	})
+
+	if len(fresh) != 3 || fresh[0].Text != "a snippet without a URL" || fresh[1].Text != "another snippet without a URL" || fresh[2].URL != "https://mirror.example.org/wind" {
+		t.Errorf("fresh = %+v", fresh)
+	}
+	if len(seen) != 1 {
+		t.Errorf("seen %d sources, want the canonical URL match", len(seen))
+	}
+	if len(pending) != 2 {
+		t.Errorf("%d sources pending, want both near duplicates of the claimed wind page", len(pending))
+	}
+
+	fresh, _, _ = index.Filter([]tools.Source{{URL: "", Text: "a third snippet"}})
+	if len(fresh) != 1 {
+		t.Error("a source without a URL was filtered as a duplicate of other URL-less sources")
+	}
+}
+
+func TestSourceIndexRemove(t *testing.T) {
+	index := NewSourceIndex(nil)
+	solar := tools.Source{URL: "", Text: longText("solar")}
+	wind := tools.Source{URL: "", Text: longText("wind")}
+	index.Add(solar, wind)
+
+	index.Remove(solar)
+
+	fresh, seen, _ := index.Filter([]tools.Source{solar, wind})
+	if len(fresh) != 1 || fresh[0].Text != solar.Text || len(seen) != 1 {
+		t.Errorf("after removing one URL-less source, fresh = %d and seen = %d, want 1 and 1", len(fresh), len(seen))
+	}
+}
+
+func TestSourceIndexPending(t *testing.T) {
+	solar := tools.Source{URL: "https://example.com/solar", Text: longText("solar")}
+	wind := tools.Source{URL: "https://example.com/wind", Text: longText("wind")}
+	mirror := tools.Source{URL: "https://mirror.example.org/wind", Text: longText("wind")}
+
+	index := NewSourceIndex(nil)
+	fresh, _, _ := index.Filter([]tools.Source{solar, wind})
+	if len(fresh) != 2 {
+		t.Fatalf("claimed %d sources, want 2", len(fresh))
+	}
+	_, seen, pending := index.Filter([]tools.Source{solar, mirror})
+	if len(seen) != 0 || len(pending) != 2 {
+		t.Fatalf("seen = %d and pending = %d while summarizing, want 0 and 2", len(seen), len(pending))
+	}
+
+	waited := make(chan error, 1)
+	go func() { waited <- index.Wait(context.Background(), pending) }()
+	index.Done(solar)
+	select {
+	case <-waited:
+		t.Fatal("Wait() returned while a source was still pending")
+	case <-time.After(20 * time.Millisecond):
+	}
+	// The claim on wind is released as its summary failed
+	index.Remove(wind)
+	if err := <-waited; err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	fresh, seen, pending = index.Filter(pending)
+	if len(fresh) != 1 || fresh[0].URL != mirror.URL || len(seen) != 1 || seen[0].URL != solar.URL || len(pending) != 0 {
+		t.Errorf("after settling, fresh = %v, seen = %v and pending = %v, want the mirror taken over and solar seen", fresh, seen, pending)
+	}
+}
+
+func TestSourceIndexWaitCancelled(t *testing.T) {
+	solar := tools.Source{URL: "https://example.com/solar"}
+	index := NewSourceIndex(nil)
+	index.Filter([]tools.Source{solar})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := index.Wait(ctx, []tools.Source{solar}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() error = %v, want context.Canceled", err)
+	}
+
+	index.Add(solar)
+	if err := index.Wait(context.Background(), []tools.Source{solar}); err != nil {
+		t.Errorf("Wait() on a stored source error = %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package workflows
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+// Progress event types emitted while researching.
+const (
+	EventStageEntered      = "stage_entered"
+	EventSubtopicsPlanned  = "subtopics_planned"
+	EventSearchIssued      = "search_issued"
+	EventResultsSummarized = "results_summarized"
+	EventURLFetched        = "url_fetched"
+	EventReflection        = "reflection"
+	EventBudgetExhausted   = "budget_exhausted"
+	EventReportStarted     = "report_started"
+	EventReportFinished    = "report_finished"
+)
+
+// ProgressEvent describes a notable step taken during research.
+type ProgressEvent struct {
+	Type    string         `json:"type"`
+	Stage   string         `json:"stage,omitempty"`
+	Message string         `json:"message"`
+	Data    map[string]any `json:"data,omitempty"`
+	Time    time.Time      `json:"time"`
+}
+
+// ProgressReporter receives progress events. It may be called concurrently.
+type ProgressReporter func(ProgressEvent)
+
+type progressReporterKey struct{}
+
+// WithProgressReporter returns a context that delivers progress events
+// emitted by workflows to reporter.
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, reporter)
+}
+
+// WithProgressData returns a context whose progress events carry key set to
+// value in their data before reaching the reporter attached to ctx.
+func WithProgressData(ctx context.Context, key string, value any) context.Context {
+	reporter, ok := ctx.Value(progressReporterKey{}).(ProgressReporter)
+	if !ok || reporter == nil {
+		return ctx
+	}
+	return WithProgressReporter(ctx, func(event ProgressEvent) {
+		data := make(map[string]any, len(event.Data)+1)
+		maps.Copy(data, event.Data)
+		data[key] = value
+		event.Data = data
+		reporter(event)
+	})
+}
+
+// EmitProgress sends event to the reporter attached to ctx, if any.
+func EmitProgress(ctx context.Context, event ProgressEvent) {
+	reporter, ok := ctx.Value(progressReporterKey{}).(ProgressReporter)
+	if !ok || reporter == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	reporter(event)
+}
@@ -0,0 +1,354 @@
+package workflows
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"text/template/parse"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Prompt names. A prompt is overridden by the file <name>.tmpl in the prompts
+// directory.
+const (
+	PromptClarifyWithUser    = "clarify_with_user"
+	PromptResearchBrief      = "research_brief_generation"
+	PromptResearchSupervisor = "research_supervisor"
+	PromptWebResearch        = "web_research"
+	PromptSummarizeResearch  = "summarize_research"
+	PromptResearchReport     = "research_report_generation"
+)
+
+var promptNames = []string{
+	PromptClarifyWithUser,
+	PromptResearchBrief,
+	PromptResearchSupervisor,
+	PromptWebResearch,
+	PromptSummarizeResearch,
+	PromptResearchReport,
+}
+
+//go:embed prompts/*.tmpl
+var defaultPromptFiles embed.FS
+
+// Prompts holds the prompt templates used by the workflows: the embedded
+// defaults, some of which may be overridden from a directory.
+type Prompts struct {
+	sources map[string]string
+	// Version identifies the prompt texts, so sessions can tell which prompts
+	// produced them
+	Version string
+	// Overridden lists the prompts loaded from the prompts directory
+	Overridden []string
+}
+
+// loadDefaultPrompts loads the embedded prompts on first use rather than at
+// init, so invalid prompts fail the call instead of the whole program.
+var loadDefaultPrompts = sync.OnceValues(func() (*Prompts, error) {
+	return LoadPrompts("")
+})
+
+// DefaultPrompts returns the embedded prompts. It panics if they are
+// invalid, which the tests rule out.
+func DefaultPrompts() *Prompts {
+	p, err := loadDefaultPrompts()
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded prompts: %v", err))
+	}
+	return p
+}
+
+// LoadPrompts loads the embedded prompts, overridden by the <name>.tmpl files
+// in dir when dir is not empty. Every prompt is checked to parse, to only
+// reference fields of TemplateData and to render. Files in dir that do not
+// match a prompt name are rejected, so a misnamed override is not silently
+// ignored.
+func LoadPrompts(dir string) (*Prompts, error) {
+	p := &Prompts{sources: make(map[string]string, len(promptNames))}
+	for _, name := range promptNames {
+		raw, err := defaultPromptFiles.ReadFile("prompts/" + name + ".tmpl")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded prompt %s: %w", name, err)
+		}
+		p.sources[name] = string(raw)
+	}
+
+	if dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompts directory: %w", err)
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ".tmpl")
+			if !ok || entry.IsDir() {
+				continue
+			}
+			if _, known := p.sources[name]; !known {
+				return nil, fmt.Errorf("unknown prompt %s in %s, expected one of %s", entry.Name(), dir, strings.Join(promptNames, ", "))
+			}
+			raw, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read prompt %s: %w", name, err)
+			}
+			p.sources[name] = string(raw)
+			p.Overridden = append(p.Overridden, name)
+		}
+	}
+
+	hash := sha256.New()
+	for _, name := range promptNames {
+		if err := validatePrompt(name, p.sources[name]); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(hash, "%s\x00%s\x00", name, p.sources[name])
+	}
+	p.Version = hex.EncodeToString(hash.Sum(nil))[:12]
+	return p, nil
+}
+
+// Render builds the named prompt from data.
+func (p *Prompts) Render(name string, data TemplateData) (string, error) {
+	source, ok := p.sources[name]
+	if !ok {
+		return "", fmt.Errorf("unknown prompt %s", name)
+	}
+	return PromptBuilder(name, source, data)
+}
+
+type promptsKey struct{}
+
+// WithPrompts returns a context whose workflows build their prompts from p.
+func WithPrompts(ctx context.Context, p *Prompts) context.Context {
+	return context.WithValue(ctx, promptsKey{}, p)
+}
+
+// RenderPrompt builds the named prompt with the prompts attached to ctx, or
+// the embedded defaults.
+func RenderPrompt(ctx context.Context, name string, data TemplateData) (string, error) {
+	p, _ := ctx.Value(promptsKey{}).(*Prompts)
+	if p == nil {
+		var err error
+		if p, err = loadDefaultPrompts(); err != nil {
+			return "", fmt.Errorf("invalid embedded prompts: %w", err)
+		}
+	}
+	return p.Render(name, data)
+}
+
+// samplePromptData fills every TemplateData field, so rendering it exercises
+// the branches of a prompt that depend on them.
+var samplePromptData = TemplateData{
+	Date:                    "01/01/2025",
+	Messages:                []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "question"}},
+	ResearchBrief:           "brief",
+	RawResearchNote:         "note",
+	CompressedResearchNotes: []ResearchNote{{Summary: "summary"}},
+	Sources:                 []ReportSource{{ID: 1, Notes: []ResearchNote{{Summary: "summary"}}}},
+	MaxSearchCalls:          1,
+	LocalSearch:             true,
+	MaxSubtopics:            1,
+}
+
+// validatePrompt checks that a prompt template parses, only references
+// fields that exist on TemplateData and renders.
+func validatePrompt(name, source string) error {
+	tmpl, err := newPromptTemplate(name, source)
+	if err != nil {
+		return fmt.Errorf("prompt %s: %w", name, err)
+	}
+	checker := fieldChecker{root: reflect.TypeOf(TemplateData{})}
+	if err := checker.walk(tmpl.Tree.Root, checker.root, map[string]reflect.Type{"$": checker.root}); err != nil {
+		return fmt.Errorf("prompt %s: %w", name, err)
+	}
+	if err := tmpl.Execute(io.Discard, samplePromptData); err != nil {
+		return fmt.Errorf("prompt %s: %w", name, err)
+	}
+	return nil
+}
+
+// fieldChecker follows the field references of a template through the types
+// of the data it is executed with, including inside range and with blocks
+// and through variables. References whose type cannot be known statically,
+// such as function results, are not checked.
+type fieldChecker struct {
+	root reflect.Type
+}
+
+func (c fieldChecker) walk(node parse.Node, dot reflect.Type, vars map[string]reflect.Type) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := c.walk(child, dot, vars); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		_, err := c.pipe(n.Pipe, dot, vars, true)
+		return err
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			_, err := c.pipe(n.Pipe, dot, vars, true)
+			return err
+		}
+	case *parse.IfNode:
+		return c.branch(&n.BranchNode, dot, vars, false, false)
+	case *parse.WithNode:
+		return c.branch(&n.BranchNode, dot, vars, true, false)
+	case *parse.RangeNode:
+		return c.branch(&n.BranchNode, dot, vars, true, true)
+	}
+	return nil
+}
+
+// branch checks an if, with or range block. Variables declared inside it go
+// out of scope at its end.
+func (c fieldChecker) branch(n *parse.BranchNode, dot reflect.Type, vars map[string]reflect.Type, moveDot, isRange bool) error {
+	inner := maps.Clone(vars)
+	typ, err := c.pipe(n.Pipe, dot, inner, !isRange)
+	if err != nil {
+		return err
+	}
+	listDot := dot
+	if moveDot {
+		listDot = typ
+	}
+	if isRange {
+		key, elem := rangeTypes(typ)
+		listDot = elem
+		switch len(n.Pipe.Decl) {
+		case 1:
+			inner[n.Pipe.Decl[0].Ident[0]] = elem
+		case 2:
+			inner[n.Pipe.Decl[0].Ident[0]] = key
+			inner[n.Pipe.Decl[1].Ident[0]] = elem
+		}
+	}
+	if err := c.walk(n.List, listDot, inner); err != nil {
+		return err
+	}
+	return c.walk(n.ElseList, dot, maps.Clone(vars))
+}
+
+// pipe checks a pipeline and returns the type of its result, or nil when it
+// cannot be known. declare records the variables the pipeline declares.
+func (c fieldChecker) pipe(p *parse.PipeNode, dot reflect.Type, vars map[string]reflect.Type, declare bool) (reflect.Type, error) {
+	if p == nil {
+		return nil, nil
+	}
+	var typ reflect.Type
+	for _, cmd := range p.Cmds {
+		var err error
+		if typ, err = c.command(cmd, dot, vars); err != nil {
+			return nil, err
+		}
+	}
+	if declare {
+		for _, v := range p.Decl {
+			vars[v.Ident[0]] = typ
+		}
+	}
+	return typ, nil
+}
+
+// command checks the arguments of a command. Only a command made of a
+// single field or variable reference has a known type.
+func (c fieldChecker) command(cmd *parse.CommandNode, dot reflect.Type, vars map[string]reflect.Type) (reflect.Type, error) {
+	var typ reflect.Type
+	for _, arg := range cmd.Args {
+		var err error
+		if typ, err = c.arg(arg, dot, vars); err != nil {
+			return nil, err
+		}
+	}
+	if len(cmd.Args) != 1 {
+		return nil, nil
+	}
+	return typ, nil
+}
+
+func (c fieldChecker) arg(arg parse.Node, dot reflect.Type, vars map[string]reflect.Type) (reflect.Type, error) {
+	switch n := arg.(type) {
+	case *parse.DotNode:
+		return dot, nil
+	case *parse.FieldNode:
+		return resolveFields(dot, n.Ident)
+	case *parse.VariableNode:
+		return resolveFields(vars[n.Ident[0]], n.Ident[1:])
+	case *parse.ChainNode:
+		typ, err := c.arg(n.Node, dot, vars)
+		if err != nil {
+			return nil, err
+		}
+		return resolveFields(typ, n.Field)
+	case *parse.PipeNode:
+		return c.pipe(n, dot, vars, true)
+	}
+	return nil, nil
+}
+
+// resolveFields follows a chain of field or method names from typ. It fails
+// on a name typ does not have, and gives up on types only known at run time.
+func resolveFields(typ reflect.Type, fields []string) (reflect.Type, error) {
+	for _, field := range fields {
+		if typ == nil {
+			return nil, nil
+		}
+		if method, ok := reflect.PointerTo(typ).MethodByName(field); ok {
+			if method.Type.NumOut() == 0 {
+				return nil, nil
+			}
+			typ = method.Type.Out(0)
+			continue
+		}
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		switch typ.Kind() {
+		case reflect.Struct:
+			f, ok := typ.FieldByName(field)
+			if !ok || !f.IsExported() {
+				return nil, fmt.Errorf("unknown field %s of %s", field, typ)
+			}
+			typ = f.Type
+		case reflect.Map:
+			typ = typ.Elem()
+		case reflect.Interface:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("cannot access field %s of %s", field, typ)
+		}
+	}
+	return typ, nil
+}
+
+// rangeTypes returns the key and element types of ranging over typ.
+func rangeTypes(typ reflect.Type) (key, elem reflect.Type) {
+	if typ == nil {
+		return nil, nil
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		return reflect.TypeFor[int](), typ.Elem()
+	case reflect.Map:
+		return typ.Key(), typ.Elem()
+	case reflect.Int:
+		return typ, typ
+	}
+	return nil, nil
+}
@@ -0,0 +1,72 @@
+package workflows
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultPrompts(t *testing.T) {
+	p, err := LoadPrompts("")
+	if err != nil {
+		t.Fatalf("embedded prompts are invalid: %v", err)
+	}
+	if len(p.Overridden) != 0 || p.Version == "" {
+		t.Errorf("Overridden = %v and Version = %q, want no overrides and a version", p.Overridden, p.Version)
+	}
+	if DefaultPrompts().Version != p.Version {
+		t.Errorf("DefaultPrompts().Version = %q, want %q", DefaultPrompts().Version, p.Version)
+	}
+	for _, name := range promptNames {
+		rendered, err := RenderPrompt(context.Background(), name, samplePromptData)
+		if err != nil || strings.TrimSpace(rendered) == "" {
+			t.Errorf("RenderPrompt(%s) = %q, %v", name, rendered, err)
+		}
+	}
+}
+
+func TestLoadPrompts(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{name: "override", files: map[string]string{PromptWebResearch + ".tmpl": "Research {{.ResearchBrief}}"}},
+		{name: "other files are ignored", files: map[string]string{"README.md": "notes"}},
+		{name: "unknown prompt", files: map[string]string{"web_reserch.tmpl": "text"}, wantErr: "unknown prompt web_reserch.tmpl"},
+		{name: "unknown field", files: map[string]string{PromptWebResearch + ".tmpl": "{{.Brief}}"}, wantErr: "unknown field Brief"},
+		{name: "parse error", files: map[string]string{PromptWebResearch + ".tmpl": "{{if .LocalSearch}}"}, wantErr: "prompt web_research"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			p, err := LoadPrompts(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadPrompts() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadPrompts() error = %v", err)
+			}
+			if p.Version == DefaultPrompts().Version && len(p.Overridden) > 0 {
+				t.Error("an override kept the version of the embedded prompts")
+			}
+			if _, ok := tt.files[PromptWebResearch+".tmpl"]; ok {
+				rendered, err := RenderPrompt(WithPrompts(context.Background(), p), PromptWebResearch, TemplateData{ResearchBrief: "solar"})
+				if err != nil || rendered != "Research solar" {
+					t.Errorf("RenderPrompt() = %q, %v, want the override", rendered, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package workflows
+
+import (
+	"deep-research/internal/tools"
+	"fmt"
+	"strings"
+)
+
+// ResearchNote is the compressed summary of a single source gathered during
+// web research, kept together with the source it was derived from.
+type ResearchNote struct {
+	Source      tools.Source `json:"source"`
+	Summary     string       `json:"summary"`
+	KeyExcerpts string       `json:"key_excerpts"`
+	// Fingerprint is the simhash of the summarized text, used to recognize
+	// near-duplicate sources after the text is discarded
+	Fingerprint uint64 `json:"fingerprint,omitempty"`
+}
+
+// String renders the note in the tagged format used in prompts.
+func (n ResearchNote) String() string {
+	var sb strings.Builder
+	sb.WriteString("<source>\n")
+	fmt.Fprintf(&sb, "Title: %s\nURL: %s\n", n.Source.Title, n.Source.URL)
+	if n.Source.PublishedDate != "" {
+		fmt.Fprintf(&sb, "Published: %s\n", n.Source.PublishedDate)
+	}
+	if n.Source.Author != "" {
+		fmt.Fprintf(&sb, "Author: %s\n", n.Source.Author)
+	}
+	sb.WriteString("</source>\n")
+	fmt.Fprintf(&sb, "<summary>\n%s\n</summary>\n<key_excerpts>\n%s\n</key_excerpts>", n.Summary, n.KeyExcerpts)
+	return sb.String()
+}
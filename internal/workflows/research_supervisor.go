@@ -0,0 +1,105 @@
+package workflows
+
+import (
+	"context"
+	"deep-research/internal/config"
+	"deep-research/internal/llm"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Subtopic is an independent part of the research brief investigated by its
+// own research agent.
+type Subtopic struct {
+	Title string `json:"title" jsonschema:"title=title,description=a short title for the subtopic"`
+	Brief string `json:"brief" jsonschema:"title=brief,description=a standalone research brief for the subtopic"`
+}
+
+type ResearchSupervisorWorkflow struct {
+	client        llm.Provider
+	stage         config.StageConfig
+	logger        *slog.Logger
+	researchBrief *string
+	subtopics     *[]Subtopic
+	maxSubtopics  int
+}
+
+type ResearchSupervisorOutputSchema struct {
+	Subtopics []Subtopic `json:"subtopics" jsonschema:"title=subtopics,description=the independent subtopics of the research brief"`
+}
+
+func NewResearchSupervisor(researchBrief *string, subtopics *[]Subtopic, client llm.Provider, stage config.StageConfig, maxSubtopics int, logger *slog.Logger) ChatSessionWorkflow {
+	return &ResearchSupervisorWorkflow{
+		client:        client,
+		stage:         stage,
+		logger:        logger,
+		researchBrief: researchBrief,
+		subtopics:     subtopics,
+		maxSubtopics:  maxSubtopics,
+	}
+}
+
+// Split the research brief into independent subtopics that are researched
+// concurrently. A brief that does not split cleanly is kept as one subtopic.
+func (rs *ResearchSupervisorWorkflow) Execute(ctx context.Context) (any, bool, error) {
+	rs.logger.Debug("Executing research supervisor workflow")
+
+	if rs.maxSubtopics <= 1 {
+		*rs.subtopics = []Subtopic{{Title: "Research brief", Brief: *rs.researchBrief}}
+		return *rs.subtopics, false, nil
+	}
+
+	data := TemplateData{
+		Date:          time.Now().Format("02/01/2006"),
+		ResearchBrief: *rs.researchBrief,
+		MaxSubtopics:  rs.maxSubtopics,
+	}
+	prompt, err := RenderPrompt(ctx, PromptResearchSupervisor, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var output ResearchSupervisorOutputSchema
+	_, err = rs.client.CreateStructuredCompletion(ctx, NewChatCompletionRequest(rs.stage, []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: prompt,
+		},
+	}), &output)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create chat completion: %w", err)
+	}
+
+	subtopics := make([]Subtopic, 0, len(output.Subtopics))
+	for _, subtopic := range output.Subtopics {
+		if strings.TrimSpace(subtopic.Brief) == "" {
+			continue
+		}
+		subtopics = append(subtopics, subtopic)
+	}
+	if len(subtopics) == 0 {
+		subtopics = []Subtopic{{Title: "Research brief", Brief: *rs.researchBrief}}
+	}
+	if len(subtopics) > rs.maxSubtopics {
+		rs.logger.Warn("Supervisor returned too many subtopics", "count", len(subtopics), "max", rs.maxSubtopics)
+		subtopics = subtopics[:rs.maxSubtopics]
+	}
+
+	*rs.subtopics = subtopics
+
+	titles := make([]string, len(subtopics))
+	for i, subtopic := range subtopics {
+		titles[i] = subtopic.Title
+	}
+	EmitProgress(ctx, ProgressEvent{
+		Type:    EventSubtopicsPlanned,
+		Message: fmt.Sprintf("Split research into %d subtopics: %s", len(subtopics), strings.Join(titles, "; ")),
+		Data:    map[string]any{"subtopics": titles},
+	})
+
+	return subtopics, false, nil
+}
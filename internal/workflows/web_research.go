@@ -2,150 +2,38 @@ package workflows
 
 import (
 	"context"
+	"crypto/sha256"
+	"deep-research/internal/cache"
+	"deep-research/internal/config"
+	"deep-research/internal/llm"
 	"deep-research/internal/tools"
+	"deep-research/internal/usage"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/instructor-ai/instructor-go/pkg/instructor"
 	"github.com/sashabaranov/go-openai"
 )
 
-var webSearchPrompt string = `
-<ROLE>
-You are a research assistant conducting research on the user's input topic.
-</ROLE>
-
-<DATE>
-For context, today's date is {{ .Date }}.
-</DATE>
-
-<TASK>
-Your job is to use the tools provided to gather information and resources that directly address the user's research question. 
-'Resources' refer to evidence-based materials such as articles, official reports, or studies relevant to the user's topic. 
-An 'answer' is considered complete when it is comprehensive, directly addresses the research question, and is supported by at least three distinct, relevant sources, or when further searching yields only information already found.
-</TASK>
-
-<AVAILABLE_TOOLS>
-You have access to two main tools:
-1. **search_tool**: For conducting web searches to gather information
-2. **think_tool**: For reflection and strategic planning during research
-
-**CRITICAL: Use think_tool after each search to reflect on results and plan next steps**
-</AVAILABLE_TOOLS>
-
-<INSTRUCTIONS>
-Think like a human researcher with limited time. Follow these steps:
-
-1. **Read the question carefully** – Determine what specific information the user needs.
-2. **Start with broader searches** – Use broad, comprehensive queries first to gather general information.
-3. **After each search, pause and assess** – Use think_tool to evaluate if you have enough to answer; identify what’s still missing.
-4. **Execute narrower searches as needed** – Use targeted queries to fill specific informational gaps.
-5. **Stop when you can answer confidently** – Provide the answer when criteria are met; avoid unnecessary searching.
-</INSTRUCTIONS>
-
-<DEFINITIONS>
-- **Simple Query**: A question seeking factual, straightforward information on a single aspect or concept.
-- **Complex Query**: A question requiring synthesis of multiple pieces of information, addresses multiple components, or explores nuanced or multifaceted topics.
-</DEFINITIONS>
-
-<HARD_LIMITS>
-**Tool Call Budgets:**
-- **Simple queries**: Use 2-3 search_tool calls maximum.
-- **Complex queries**: Use up to 5 search_tool calls maximum.
-- **Always stop**: After 5 search_tool calls, even if a full answer is not found.
-
-**Stop Immediately When:**
-- You can answer the user's question comprehensively, supported by at least three distinct, relevant sources.
-- Your last two searches each returned similar or redundant information.
-</HARD_LIMITS>
-
-<DECISION CRITERIA>
-After each search and reflection (reflection_tool):
-- If you have found three or more relevant sources covering the question, or
-- If subsequent searches only yield repeated information, or
-- If you can directly and comprehensively answer the research question,
-Then proceed to answer; otherwise, continue searching within tool call limits.
-</DECISION CRITERIA>
-
-<SHOW_YOUR_THINKING>
-After each search tool call, use reflection_tool to analyze the results:
-- What key information did I find?
-- What information is still missing?
-- Do I now have enough to fully answer the question?
-- Should I perform another search or provide my answer based on current findings?
-</SHOW_YOUR_THINKING>
-`
-
-var summarizeWebSeachResultPrompt string = `
-<ROLE>
-You are a summarization agent tasked with condensing the raw content of a webpage into a concise summary that preserves the most important information from the original page.
-Your summary will be used by a downstream research agent, so it is essential to retain key details and facts.
-</ROLE>
-
-<DATE>
-For context, today's date is {{ .Date }}.
-</DATE>
-
-<WEBPAGE_CONTENT>
-{{ .RawResearchNote }}
-</WEBPAGE_CONTENT>
-
-<INSTRUCTIONS>
-Summarize the content to be approximately 25–30% of the original length, unless already concise, while allowing the summary to stand alone as a complete source of information.
-</INSTRUCTIONS>
-
-<GUIDELINES>
-Follow these guidelines:
-1. Identify and preserve the main topic or purpose of the webpage.
-2. Retain central facts, statistics, and data points.
-3. Keep important quotes from credible sources or experts (up to 5 in total).
-4. Maintain chronological order for time-sensitive or historical content.
-5. Preserve any lists or step-by-step instructions present in the content.
-6. Include essential dates, names, and locations.
-7. Summarize lengthy explanations without omitting core messages.
-
-For specific types of content, apply these focus areas:
-- **News articles:** Emphasize who, what, when, where, why, and how.
-- **Scientific content:** Preserve methodology, results, and conclusions.
-- **Opinion pieces:** Maintain key arguments and supporting points.
-- **Product pages:** Retain key features, specifications, and unique selling points.
-</GUIDELINES>
-
-<OUTPUT_FORMAT>
-Your output must be valid JSON matching the following schema:
-{
-   "summary": "<Your summary here, structured with appropriate paragraphs or bullet points as needed>",
-   "key_excerpts": "<Important quote or excerpt one, Important quote or excerpt two, ... (up to 5 quotes/excerpts, comma-separated)>"
-}
-</OUTPUT_FORMAT>
-
-<EXAMPLES>
-Example 1 (news article):
-{
-  "summary": "On July 15, 2023, NASA successfully launched the Artemis II mission from Kennedy Space Center. This marks the first crewed mission to the Moon since Apollo 17 in 1972. The four-person crew, led by Commander Jane Smith, will orbit the Moon for 10 days before returning to Earth. This mission is a crucial step in NASA's plans to establish a permanent human presence on the Moon by 2030.",
-  "key_excerpts": "Artemis II represents a new era in space exploration, said NASA Administrator John Doe. The mission will test critical systems for future long-duration stays on the Moon, explained Lead Engineer Sarah Johnson. We're not just going back to the Moon, we're going forward to the Moon, Commander Jane Smith stated during the pre-launch press conference."
-}
-
-Example 2 (scientific article):
-{
-  "summary": "A new study published in Nature Climate Change reveals that global sea levels are rising faster than previously thought. Researchers analyzed satellite data from 1993 to 2022 and found that the rate of sea-level rise has accelerated by 0.08 mm/year². This increase is mainly due to melting ice sheets in Greenland and Antarctica and may result in sea levels rising by up to 2 meters by 2100, threatening coastal communities globally.",
-  "key_excerpts": "Our findings indicate a clear acceleration in sea-level rise, which has significant implications for coastal planning and adaptation strategies, lead author Dr. Emily Brown stated. The rate of ice sheet melt in Greenland and Antarctica has tripled since the 1990s, the study reports. Without immediate and substantial reductions in greenhouse gas emissions, we are looking at potentially catastrophic sea-level rise by the end of this century, warned co-author Professor Michael Green."
-}
-</EXAMPLES>
-
-<REMINDER>
-Remember, your goal is to create a summary that can be easily understood and utilized by a downstream research agent while preserving the most critical information from the original webpage.
-</REMINDER>
-`
-
 type WebResearchWorkflow struct {
-	client                  *openai.Client
-	structuredOutputClient  *instructor.InstructorOpenAI
+	client                  llm.Provider
+	summarizerClient        llm.Provider
+	searchProvider          tools.SearchProvider
+	localSearch             tools.SearchProvider
+	fetcher                 *tools.URLFetcher
+	cache                   *cache.Cache
+	sources                 *SourceIndex
+	stage                   config.StageConfig
+	summarizerStage         config.StageConfig
 	logger                  *slog.Logger
 	messages                *[]openai.ChatCompletionMessage
-	compressedResearchNotes *[]string
+	compressedResearchNotes *[]ResearchNote
+	budget                  *SharedBudget
+	usage                   *ResearchUsage
 }
 
 type SummarizedResearchOutputSchema struct {
@@ -153,13 +41,25 @@ type SummarizedResearchOutputSchema struct {
 	KeyExcerpts string `json:"key_excerpts"`
 }
 
-func NewWebResearch(messages *[]openai.ChatCompletionMessage, compressedResearchNotes *[]string, client *openai.Client, structuredOutputClient *instructor.InstructorOpenAI, logger *slog.Logger) ChatSessionWorkflow {
+// NewWebResearch creates a research agent that records what it consumes in
+// usage and draws from budget, which is shared with the other agents of the
+// session and holds the agent to its share. The caller reserves each iteration with budget.StartIteration.
+func NewWebResearch(messages *[]openai.ChatCompletionMessage, compressedResearchNotes *[]ResearchNote, usage *ResearchUsage, client llm.Provider, summarizerClient llm.Provider, searchProvider, localSearch tools.SearchProvider, fetcher *tools.URLFetcher, responseCache *cache.Cache, sources *SourceIndex, stage, summarizerStage config.StageConfig, budget *SharedBudget, logger *slog.Logger) ChatSessionWorkflow {
 	return &WebResearchWorkflow{
+		stage:                   stage,
+		summarizerStage:         summarizerStage,
 		client:                  client,
-		structuredOutputClient:  structuredOutputClient,
+		summarizerClient:        summarizerClient,
+		searchProvider:          searchProvider,
+		localSearch:             localSearch,
+		fetcher:                 fetcher,
+		cache:                   responseCache,
+		sources:                 sources,
 		logger:                  logger,
 		messages:                messages,
 		compressedResearchNotes: compressedResearchNotes,
+		budget:                  budget,
+		usage:                   usage,
 	}
 }
 
@@ -168,26 +68,33 @@ func (wr *WebResearchWorkflow) Execute(ctx context.Context) (any, bool, error) {
 
 	// Build data for prompt
 	data := TemplateData{
-		Date:     time.Now().Format("02/01/2006"),
-		Messages: *wr.messages,
+		Date:           time.Now().Format("02/01/2006"),
+		Messages:       *wr.messages,
+		MaxSearchCalls: wr.budget.Share().MaxSearchCalls,
+		LocalSearch:    wr.localSearch != nil,
 	}
-	prompt, err := PromptBuilder("web_research", webSearchPrompt, data)
+	prompt, err := RenderPrompt(ctx, PromptWebResearch, data)
 	if err != nil {
 		return "", false, err
 	}
 
 	conversationHistory := BuildConversationHistory(&prompt, wr.messages)
-	webResearchTools := tools.BuildTools(
-		[]openai.FunctionDefinition{tools.SearchToolDefinition, tools.ReflectionToolDefinition})
-	resp, err := wr.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:             openai.GPT5,
-		Messages:          conversationHistory,
-		Tools:             webResearchTools,
-		ParallelToolCalls: false,
-	})
+	toolDefinitions := []openai.FunctionDefinition{tools.SearchToolDefinition, tools.FetchURLToolDefinition, tools.ReflectionToolDefinition}
+	if wr.localSearch != nil {
+		toolDefinitions = append(toolDefinitions, tools.LocalSearchToolDefinition)
+	}
+	webResearchTools := tools.BuildTools(toolDefinitions)
+	req := NewChatCompletionRequest(wr.stage, conversationHistory)
+	req.Tools = webResearchTools
+	req.ParallelToolCalls = false
+	resp, err := wr.client.CreateChatCompletion(ctx, req)
 	if err != nil {
 		return "", false, fmt.Errorf("failed to create chat completion: %w", err)
 	}
+	wr.addTokens(resp.Usage.TotalTokens)
+	if len(resp.Choices) == 0 {
+		return "", false, fmt.Errorf("failed to create chat completion: %w", llm.ErrNoChoices)
+	}
 	msg := resp.Choices[0].Message
 	*wr.messages = append(*wr.messages, msg)
 
@@ -196,28 +103,39 @@ func (wr *WebResearchWorkflow) Execute(ctx context.Context) (any, bool, error) {
 	}
 
 	for _, toolCall := range msg.ToolCalls {
-		if toolCall.Function.Name == "search_tool" {
-			results, err := tools.SearchTool{}.Execute([]byte(toolCall.Function.Arguments))
-			if err != nil {
-				return "", false, fmt.Errorf("failed to execute search tool: %w", err)
-			}
-			summarizedResults, err := summarizeWebSearchResult(ctx, results, wr.compressedResearchNotes, wr.structuredOutputClient)
+		if toolCall.Function.Name == "search_tool" || toolCall.Function.Name == "local_search" {
+			result, err := wr.search(ctx, toolCall)
 			if err != nil {
-				return "", false, fmt.Errorf("failed to summarize web search results: %w", err)
+				return "", false, err
 			}
 			*wr.messages = append(*wr.messages, openai.ChatCompletionMessage{
 				Role:       openai.ChatMessageRoleTool,
-				Content:    summarizedResults,
+				Content:    result,
+				Name:       toolCall.Function.Name,
+				ToolCallID: toolCall.ID,
+			})
+		}
+		if toolCall.Function.Name == "fetch_url" {
+			result := wr.fetchURL(ctx, toolCall.Function.Arguments)
+			*wr.messages = append(*wr.messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    result,
 				Name:       toolCall.Function.Name,
 				ToolCallID: toolCall.ID,
 			})
-			wr.logger.Debug("Called search tool", "result", summarizedResults)
 		}
 		if toolCall.Function.Name == "reflection_tool" {
 			result, err := tools.ReflectionTool{}.Execute([]byte(toolCall.Function.Arguments))
 			if err != nil {
 				return "", false, err
 			}
+			var reflection tools.ReflectionTool
+			_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &reflection)
+			EmitProgress(ctx, ProgressEvent{
+				Type:    EventReflection,
+				Message: reflection.Reflection,
+				Data:    map[string]any{"reflection": reflection.Reflection},
+			})
 			*wr.messages = append(*wr.messages, openai.ChatCompletionMessage{
 				Role:       openai.ChatMessageRoleTool,
 				Content:    result,
@@ -231,83 +149,298 @@ func (wr *WebResearchWorkflow) Execute(ctx context.Context) (any, bool, error) {
 	return "", true, nil
 }
 
-func summarizeWebSearchResult(ctx context.Context, results []string, compressedResearchNotes *[]string, client *instructor.InstructorOpenAI) (string, error) {
-	if len(results) == 0 {
-		return "", fmt.Errorf("no results to summarize")
+// search runs a search_tool or local_search call and summarizes its results
+// into research notes. Both tools share the search call budget.
+func (wr *WebResearchWorkflow) search(ctx context.Context, toolCall openai.ToolCall) (string, error) {
+	local := toolCall.Function.Name == "local_search"
+	var searchInput tools.SearchTool
+	_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &searchInput)
+	message := fmt.Sprintf("Searching for %q", searchInput.Query)
+	if local {
+		message = fmt.Sprintf("Searching local documents for %q", searchInput.Query)
 	}
+	EmitProgress(ctx, ProgressEvent{
+		Type:    EventSearchIssued,
+		Message: message,
+		Data:    map[string]any{"query": searchInput.Query, "tool": toolCall.Function.Name},
+	})
 
-	// Create channels for work distribution and result collection
-	type workItem struct {
-		index  int
-		result string
+	// Every tool call needs a response, so searches over budget are
+	// refused rather than skipped
+	if !wr.budget.ReserveSearch(*wr.usage) {
+		wr.logger.Info("Refusing search over budget", "query", searchInput.Query, "agent_search_calls", wr.usage.SearchCalls, "search_calls", wr.budget.Used().SearchCalls)
+		return "Search budget exhausted: no further searches can be run. Answer with the information gathered so far.", nil
+	}
+	wr.usage.SearchCalls++
+
+	var results []tools.Source
+	var err error
+	switch {
+	case !local:
+		results, err = tools.SearchTool{}.Execute(ctx, wr.searchProvider, []byte(toolCall.Function.Arguments))
+	case wr.localSearch != nil:
+		results, err = tools.LocalSearchTool{}.Execute(ctx, wr.localSearch, []byte(toolCall.Function.Arguments))
+	default:
+		return "No local document collection is configured. Use search_tool instead.", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to execute %s: %w", toolCall.Function.Name, err)
+	}
+	// Results are deduplicated before documents are downloaded, so a file
+	// found by several searches is read only once. Results another
+	// sub-researcher is summarizing are waited for, then filtered again:
+	// they are either stored by then or taken over here
+	var seen []tools.Source
+	var rendered []string
+	summarized, failed := 0, 0
+	for pending := results; len(pending) > 0; {
+		var fresh, stored, dropped []tools.Source
+		fresh, stored, pending = wr.sources.Filter(pending)
+		seen = append(seen, stored...)
+		fresh, dropped = wr.fetcher.ReadDocuments(ctx, fresh)
+		if len(dropped) > 0 {
+			urls := make([]string, len(dropped))
+			for i, source := range dropped {
+				urls[i] = source.URL
+			}
+			wr.logger.Warn("Dropped unreadable search results", "query", searchInput.Query, "urls", urls)
+			wr.sources.Remove(dropped...)
+		}
+		if len(fresh) > 0 {
+			batch, err := summarizeWebSearchResult(usage.WithQuery(ctx, searchInput.Query), fresh, wr.compressedResearchNotes, wr.summarizerClient, wr.summarizerStage, wr.cache)
+			wr.addTokens(batch.Tokens)
+			// Results that failed to summarize may be found and summarized again
+			failedSources := make([]tools.Source, len(batch.Failed))
+			for i, f := range batch.Failed {
+				if ctx.Err() == nil {
+					wr.logger.Warn("Failed to summarize search result", "query", searchInput.Query, "url", f.Source.URL, "error", f.Err)
+				}
+				failedSources[i] = f.Source
+			}
+			wr.sources.Done(batch.Summarized...)
+			wr.sources.Remove(failedSources...)
+			if err != nil {
+				return "", fmt.Errorf("failed to summarize web search results: %w", err)
+			}
+			rendered = append(rendered, batch.Rendered)
+			summarized += len(batch.Summarized)
+			failed += len(batch.Failed)
+		}
+		if err := wr.sources.Wait(ctx, pending); err != nil {
+			return "", fmt.Errorf("failed to wait for search results summarized elsewhere: %w", err)
+		}
+	}
+	if len(seen) > 0 {
+		wr.logger.Info("Skipped previously seen search results", "query", searchInput.Query, "count", len(seen))
+	}
+	if len(rendered) == 0 && len(seen) == 0 {
+		return "The search returned no readable results. Try a different query.", nil
+	}
+	if len(rendered) == 0 {
+		return "All results of this search were already seen in earlier searches, and their notes were collected then:\n" +
+			listSources(seen) + "\nTry a different query to find new sources.", nil
 	}
 
-	type resultItem struct {
-		index   int
-		summary string
-		err     error
+	summarizedResults := strings.Join(rendered, "\n")
+	if len(seen) > 0 {
+		summarizedResults += "\n\nThese results were already seen in earlier searches and were not summarized again:\n" + listSources(seen)
 	}
+	EmitProgress(ctx, ProgressEvent{
+		Type:    EventResultsSummarized,
+		Message: fmt.Sprintf("Summarized %d results for %q", summarized, searchInput.Query),
+		Data:    map[string]any{"query": searchInput.Query, "count": summarized, "failed": failed},
+	})
+	wr.logger.Debug("Called search tool", "tool", toolCall.Function.Name, "result", summarizedResults)
+	return summarizedResults, nil
+}
 
-	workChan := make(chan workItem, len(results))
-	resultChan := make(chan resultItem, len(results))
+// fetchURL reads the page requested by a fetch_url tool call and summarizes it
+// into a research note. Pages that cannot be read are reported back to the
+// model instead of failing the iteration, since broken links are common.
+func (wr *WebResearchWorkflow) fetchURL(ctx context.Context, arguments string) string {
+	var fetchInput tools.FetchURLTool
+	_ = json.Unmarshal([]byte(arguments), &fetchInput)
+	EmitProgress(ctx, ProgressEvent{
+		Type:    EventURLFetched,
+		Message: fmt.Sprintf("Reading %s", fetchInput.URL),
+		Data:    map[string]any{"url": fetchInput.URL},
+	})
 
-	// Limit to 5 concurrent workers
-	numWorkers := min(len(results), 5)
+	source, err := tools.FetchURLTool{}.Execute(ctx, wr.fetcher, []byte(arguments))
+	if err != nil {
+		wr.logger.Warn("Failed to fetch URL", "url", fetchInput.URL, "error", err)
+		return fmt.Sprintf("Failed to fetch %s: %v", fetchInput.URL, err)
+	}
 
-	// Start workers
-	for i := 0; i < numWorkers; i++ {
-		go func() {
-			for work := range workChan {
-				data := TemplateData{
-					RawResearchNote: work.result,
-				}
-				prompt, err := PromptBuilder("summarize_research", summarizeWebSeachResultPrompt, data)
-				if err != nil {
-					resultChan <- resultItem{index: work.index, err: fmt.Errorf("failed to build prompt: %w", err)}
-					continue
-				}
+	batch, err := summarizeWebSearchResult(ctx, []tools.Source{source}, wr.compressedResearchNotes, wr.summarizerClient, wr.summarizerStage, wr.cache)
+	wr.addTokens(batch.Tokens)
+	if err != nil {
+		wr.logger.Warn("Failed to summarize fetched page", "url", source.URL, "error", err)
+		return fmt.Sprintf("Failed to summarize %s: %v", source.URL, err)
+	}
+	// A page read in full is not summarized again when later searches find it
+	wr.sources.Add(source)
+	wr.logger.Debug("Called fetch tool", "url", source.URL, "result", batch.Rendered)
+	return batch.Rendered
+}
 
-				var summarizedResearchNote SummarizedResearchOutputSchema
-				resp, err := client.CreateChatCompletion(
-					ctx, openai.ChatCompletionRequest{
-						Model: openai.GPT4o,
-						Messages: []openai.ChatCompletionMessage{
-							{
-								Role:    openai.ChatMessageRoleUser,
-								Content: prompt,
-							},
-						},
-					}, &summarizedResearchNote)
-				if err != nil {
-					resultChan <- resultItem{
-						index: work.index,
-						err:   fmt.Errorf("failed to summarize: %w", err),
-					}
-				}
-				_ = resp
-				summary := fmt.Sprintf("<summary>\n%s\n</summary>\n<key_excerpts>\n%s\n</key_excerpts>",
-					summarizedResearchNote.Summary, summarizedResearchNote.KeyExcerpts)
-				resultChan <- resultItem{index: work.index, summary: summary}
+// addTokens charges tokens to the agent and to the shared budget.
+func (wr *WebResearchWorkflow) addTokens(tokens int) {
+	wr.usage.Tokens += tokens
+	wr.budget.AddTokens(tokens)
+}
+
+// listSources renders sources as a bulleted list of titles and URLs.
+func listSources(sources []tools.Source) string {
+	var b strings.Builder
+	for _, source := range sources {
+		fmt.Fprintf(&b, "- %s (%s)\n", source.Title, source.URL)
+	}
+	return b.String()
+}
+
+// maxSummaryWorkers caps the number of documents summarized concurrently.
+const maxSummaryWorkers = 5
+
+// summaryBatch is the outcome of summarizing a batch of search results.
+type summaryBatch struct {
+	// Rendered holds the notes of the summarized results followed by a list
+	// of the results that could not be summarized, for the research agent
+	Rendered string
+	Tokens   int
+	// Summarized lists the results whose notes were stored
+	Summarized []tools.Source
+	Failed     []failedSource
+}
+
+// failedSource is a search result that could not be summarized.
+type failedSource struct {
+	Source tools.Source
+	Err    error
+}
+
+// summarizeWebSearchResult summarizes every search result into a research
+// note. Results that fail to summarize are skipped and reported in the
+// batch, so one bad document does not discard the others. It fails only when
+// no result could be summarized or ctx is cancelled; the notes summarized
+// before a cancellation are kept. Summaries are reused from responseCache
+// when the same document was summarized by the same model before.
+func summarizeWebSearchResult(ctx context.Context, results []tools.Source, compressedResearchNotes *[]ResearchNote, client llm.Provider, stage config.StageConfig, responseCache *cache.Cache) (summaryBatch, error) {
+	if len(results) == 0 {
+		return summaryBatch{}, fmt.Errorf("no results to summarize")
+	}
+
+	notes := make([]ResearchNote, len(results))
+	tokens := make([]int, len(results))
+	errs := make([]error, len(results))
+	for i := range errs {
+		// Results never handed to a worker are reported as cancelled
+		errs[i] = context.Canceled
+	}
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range min(len(results), maxSummaryWorkers) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				notes[i], tokens[i], errs[i] = summarizeSource(ctx, results[i], client, stage, responseCache)
 			}
 		}()
 	}
+feed:
+	for i := range results {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	var batch summaryBatch
+	summarized := make([]ResearchNote, 0, len(results))
+	for i := range results {
+		batch.Tokens += tokens[i]
+		if errs[i] != nil {
+			batch.Failed = append(batch.Failed, failedSource{Source: results[i], Err: errs[i]})
+			continue
+		}
+		batch.Summarized = append(batch.Summarized, results[i])
+		summarized = append(summarized, notes[i])
+	}
+	*compressedResearchNotes = append(*compressedResearchNotes, summarized...)
 
-	go func() {
-		defer close(workChan)
-		for i, result := range results {
-			workChan <- workItem{index: i, result: result}
+	if err := ctx.Err(); err != nil {
+		return batch, fmt.Errorf("summarization interrupted: %w", err)
+	}
+	if len(summarized) == 0 {
+		failures := make([]error, len(batch.Failed))
+		for i, failed := range batch.Failed {
+			failures[i] = failed.Err
 		}
-	}()
+		return batch, fmt.Errorf("failed to summarize any of %d results: %w", len(results), errors.Join(failures...))
+	}
 
-	summarizedResearchNotes := make([]string, len(results))
-	for i := 0; i < len(results); i++ {
-		result := <-resultChan
-		if result.err != nil {
-			return "", result.err
+	renderedNotes := make([]string, len(summarized))
+	for i, note := range summarized {
+		renderedNotes[i] = note.String()
+	}
+	batch.Rendered = strings.Join(renderedNotes, "\n")
+	if len(batch.Failed) > 0 {
+		var b strings.Builder
+		b.WriteString("\n\nThese results could not be summarized and were skipped:\n")
+		for _, failed := range batch.Failed {
+			fmt.Fprintf(&b, "- %s (%s): %v\n", failed.Source.Title, failed.Source.URL, failed.Err)
 		}
-		summarizedResearchNotes[result.index] = result.summary
+		batch.Rendered += b.String()
+	}
+	return batch, nil
+}
+
+// summarizeSource summarizes a single search result into a research note
+// and returns the number of tokens used.
+func summarizeSource(ctx context.Context, result tools.Source, client llm.Provider, stage config.StageConfig, responseCache *cache.Cache) (ResearchNote, int, error) {
+	// The raw text is not needed once the source is summarized
+	source := result
+	source.Text = ""
+	note := ResearchNote{Source: source, Fingerprint: simhash(result.Text)}
+
+	// The text is part of the key since a fetched page is longer than the
+	// search snippet of the same URL
+	key := cache.Key("summary", stage.Provider, stage.Model, tools.CanonicalURL(result.URL), fmt.Sprintf("%x", sha256.Sum256([]byte(result.Text))))
+	var summarized SummarizedResearchOutputSchema
+	if responseCache.Get(key, &summarized) {
+		note.Summary, note.KeyExcerpts = summarized.Summary, summarized.KeyExcerpts
+		return note, 0, nil
+	}
+
+	data := TemplateData{
+		RawResearchNote: result.Text,
+	}
+	prompt, err := RenderPrompt(ctx, PromptSummarizeResearch, data)
+	if err != nil {
+		return ResearchNote{}, 0, fmt.Errorf("failed to build prompt: %w", err)
+	}
+
+	resp, err := client.CreateStructuredCompletion(
+		ctx, NewChatCompletionRequest(stage, []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		}), &summarized)
+	if err != nil {
+		return ResearchNote{}, resp.Usage.TotalTokens, fmt.Errorf("failed to summarize: %w", err)
+	}
+	if strings.TrimSpace(summarized.Summary) == "" {
+		return ResearchNote{}, resp.Usage.TotalTokens, fmt.Errorf("failed to summarize: empty summary")
 	}
 
-	*compressedResearchNotes = append(*compressedResearchNotes, summarizedResearchNotes...)
-	return strings.Join(summarizedResearchNotes, "\n"), nil
+	// A failed store only costs a repeated summary later
+	_ = responseCache.Set(key, summarized)
+	note.Summary, note.KeyExcerpts = summarized.Summary, summarized.KeyExcerpts
+	return note, resp.Usage.TotalTokens, nil
 }